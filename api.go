@@ -0,0 +1,343 @@
+package main
+
+// Request and response bodies of the REST API. Their JSON shapes are the
+// contract described by /api/v1/openapi.json and frozen in
+// contracts/v1.openapi.json; ndb-precheck contract fails until a change is
+// recorded there, and refuses to record one existing callers cannot ignore.
+
+// ErrorResponse is the body of every JSON error response
+type ErrorResponse struct {
+	Error string `json:"error"`
+	// Candidates lists the matching keys when an entity name is ambiguous
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+// StatusResponse acknowledges an action that has nothing else to return
+type StatusResponse struct {
+	Status string `json:"status"`
+}
+
+// MeResponse describes the caller
+type MeResponse struct {
+	Principal  *Principal `json:"principal"`
+	Role       string     `json:"role"`
+	HostGroups []string   `json:"host_groups"`
+}
+
+// CheckStartedResponse acknowledges a run started by POST /check
+type CheckStartedResponse struct {
+	Status string `json:"status"`
+	RunID  string `json:"run_id"`
+	Total  int    `json:"total"`
+}
+
+// ProgressResponse counts the hosts checked by the latest run
+type ProgressResponse struct {
+	Processed int `json:"processed"`
+	Total     int `json:"total"`
+}
+
+// RunListResponse lists recent runs, newest first
+type RunListResponse struct {
+	Runs []Run `json:"runs"`
+}
+
+// RunResponse wraps a single run
+type RunResponse struct {
+	Run Run `json:"run"`
+}
+
+// RunStateResponse is the latest known state of the hosts a run covers. For
+// a rerun, State is its results merged over the state of the run it
+// re-checked; for other runs it is the run's own results.
+type RunStateResponse struct {
+	RunID    string         `json:"run_id"`
+	ParentID string         `json:"parent_id,omitempty"`
+	State    *BatchResponse `json:"state"`
+}
+
+// RerunRequest selects what a rerun checks again
+type RerunRequest struct {
+	// Mode is failed_hosts, errored_hosts or checks
+	Mode string `json:"mode"`
+	// CheckIDs are the checks to rerun, for mode checks
+	CheckIDs []string `json:"check_ids,omitempty"`
+}
+
+// RerunStartedResponse acknowledges a rerun
+type RerunStartedResponse struct {
+	Status    string   `json:"status"`
+	RunID     string   `json:"run_id"`
+	ParentID  string   `json:"parent_id"`
+	Hostnames []string `json:"hostnames"`
+	Total     int      `json:"total"`
+}
+
+// HostRemediation is the remediation script of one host
+type HostRemediation struct {
+	Host string `json:"host"`
+	// Checks counts the failed checks the script addresses
+	Checks int    `json:"checks"`
+	Script string `json:"script"`
+}
+
+// RemediationScriptsResponse holds the remediation scripts of a run
+type RemediationScriptsResponse struct {
+	RunID   string            `json:"run_id"`
+	Profile string            `json:"profile"`
+	Hosts   []HostRemediation `json:"hosts"`
+	// OmittedHosts are in the run but not targetable by the caller
+	OmittedHosts []string `json:"omitted_hosts"`
+}
+
+// RemediationSelection names one failed check to remediate
+type RemediationSelection struct {
+	// Entity is host, host\instance or host\instance\database
+	Entity  string `json:"entity"`
+	CheckID string `json:"check_id"`
+}
+
+// CreateRemediationRequest asks to apply the remediation of failed checks
+type CreateRemediationRequest struct {
+	Reason string                 `json:"reason"`
+	Checks []RemediationSelection `json:"checks"`
+}
+
+// ReviewRemediationRequest approves or rejects a remediation request
+type ReviewRemediationRequest struct {
+	Comment string `json:"comment"`
+}
+
+// RemediationResponse wraps a remediation request
+type RemediationResponse struct {
+	Remediation *RemediationRequest `json:"remediation"`
+}
+
+// ProfileListResponse lists the loaded rule profiles
+type ProfileListResponse struct {
+	Profiles []*Profile `json:"profiles"`
+	Default  string     `json:"default"`
+}
+
+// StateResponse lists the latest known state of entities
+type StateResponse struct {
+	Entities []EntityState `json:"entities"`
+	Total    int           `json:"total"`
+}
+
+// TrendsResponse holds one point per bucket
+type TrendsResponse struct {
+	Bucket string       `json:"bucket"`
+	Level  string       `json:"level"`
+	Points []TrendPoint `json:"points"`
+}
+
+// SummaryResponse summarises the latest results for the dashboard
+type SummaryResponse struct {
+	Summary Summary `json:"summary"`
+}
+
+// Summary counts entities, readiness, failures by severity and checks
+type Summary struct {
+	EntityWise   EntityCounts                     `json:"entity_wise"`
+	Readiness    ReadinessSummary                 `json:"readiness"`
+	SeverityWise map[Severity]map[CheckStatus]int `json:"severity_wise"`
+	CheckWise    []CheckTypeSummary               `json:"check_wise"`
+}
+
+// EntityCounts counts entities and the failed ones per level
+type EntityCounts struct {
+	TotalVMs        int `json:"total_vms"`
+	FailedVMs       int `json:"failed_vms"`
+	TotalInstances  int `json:"total_instances"`
+	FailedInstances int `json:"failed_instances"`
+	TotalDatabases  int `json:"total_databases"`
+	FailedDatabases int `json:"failed_databases"`
+}
+
+// ReadinessSummary counts entities per readiness tier
+type ReadinessSummary struct {
+	VMs       map[string]int   `json:"vms"`
+	Instances map[string]int   `json:"instances"`
+	Databases map[string]int   `json:"databases"`
+	Groups    []GroupReadiness `json:"groups"`
+}
+
+// CheckTypeSummary counts check outcomes for one entity type
+type CheckTypeSummary struct {
+	Type       string                 `json:"type"`
+	Categories []CheckCategorySummary `json:"categories"`
+}
+
+// CheckCategorySummary counts check outcomes in one category
+type CheckCategorySummary struct {
+	CategoryName string              `json:"category_name"`
+	Passed       int                 `json:"passed"`
+	Failed       int                 `json:"failed"`
+	Warnings     int                 `json:"warnings"`
+	Check        []CheckCountSummary `json:"check"`
+}
+
+// CheckCountSummary counts the outcomes of one check
+type CheckCountSummary struct {
+	CheckName string `json:"check_name"`
+	Passed    int    `json:"passed"`
+	Failed    int    `json:"failed"`
+}
+
+// FitmentStatus is an entity's pass/fail status and fitment in listings
+type FitmentStatus struct {
+	// Status is Passed or Failed
+	Status     string           `json:"status"`
+	Score      int              `json:"score"`
+	Readiness  string           `json:"readiness"`
+	BySeverity map[Severity]int `json:"by_severity"`
+}
+
+func fitmentStatus(failed bool, f Fitment) FitmentStatus {
+	return FitmentStatus{Status: statusLabel(failed), Score: f.Score, Readiness: f.Readiness, BySeverity: f.BySeverity}
+}
+
+// VMListItem is a VM in the drill-down listing
+type VMListItem struct {
+	EntityName           string             `json:"entity_name"`
+	Type                 string             `json:"type"`
+	OverallFitmentStatus FitmentStatus      `json:"overall_fitment_status"`
+	InstancesCount       int                `json:"instances_count"`
+	DatabasesCount       int                `json:"databases_count"`
+	Instances            []InstanceListItem `json:"instances"`
+}
+
+// InstanceListItem is an instance in the drill-down listing
+type InstanceListItem struct {
+	EntityName           string             `json:"entity_name"`
+	Type                 string             `json:"type"`
+	OverallFitmentStatus FitmentStatus      `json:"overall_fitment_status"`
+	DatabasesCount       int                `json:"databases_count"`
+	Databases            []DatabaseListItem `json:"databases"`
+}
+
+// DatabaseListItem is a database in the drill-down listing
+type DatabaseListItem struct {
+	EntityName           string        `json:"entity_name"`
+	Type                 string        `json:"type"`
+	OverallFitmentStatus FitmentStatus `json:"overall_fitment_status"`
+	// ParentInstance is set in the database listing only
+	ParentInstance string `json:"parent_instance,omitempty"`
+}
+
+// VMListResponse is a page of the VM listing
+type VMListResponse struct {
+	VMs        []VMListItem `json:"vms"`
+	Total      int          `json:"total"`
+	NextCursor string       `json:"next_cursor"`
+}
+
+// InstanceListResponse is a page of the instance listing
+type InstanceListResponse struct {
+	Instances  []InstanceListItem `json:"instances"`
+	Total      int                `json:"total"`
+	NextCursor string             `json:"next_cursor"`
+}
+
+// DatabaseListResponse is a page of the database listing
+type DatabaseListResponse struct {
+	Databases  []DatabaseListItem `json:"databases"`
+	Total      int                `json:"total"`
+	NextCursor string             `json:"next_cursor"`
+}
+
+// EntityRef identifies an entity of a run
+type EntityRef struct {
+	Key  string `json:"key"`
+	Type string `json:"type"`
+	Host string `json:"host"`
+}
+
+// EntityDetail is every check of one entity in a run
+type EntityDetail struct {
+	RunID          string        `json:"run_id"`
+	Profile        string        `json:"profile"`
+	ProfileVersion string        `json:"profile_version"`
+	Entity         EntityRef     `json:"entity"`
+	Status         string        `json:"status"`
+	Fitment        Fitment       `json:"fitment"`
+	Checks         []CheckDetail `json:"checks"`
+	Host           *HostDetail   `json:"host"`
+	// Instances and Databases are the keys of the entity's children
+	Instances []string `json:"instances,omitempty"`
+	Databases []string `json:"databases,omitempty"`
+	// Instance is a database's instance
+	Instance string `json:"instance,omitempty"`
+}
+
+// WebhookListResponse lists the configured webhooks
+type WebhookListResponse struct {
+	Webhooks []WebhookConfig `json:"webhooks"`
+}
+
+// WebhookDeliveriesResponse lists webhook deliveries, newest first
+type WebhookDeliveriesResponse struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+}
+
+// WebhookDeliveryResponse reports a test delivery
+type WebhookDeliveryResponse struct {
+	Delivery WebhookDelivery `json:"delivery"`
+}
+
+// DigestSentResponse acknowledges a digest sent by mail
+type DigestSentResponse struct {
+	Status     string `json:"status"`
+	Recipients int    `json:"recipients"`
+}
+
+// AuditResponse lists audit entries, newest first
+type AuditResponse struct {
+	Entries []AuditEntry `json:"entries"`
+	// Total counts every matching entry, beyond the limit
+	Total int `json:"total"`
+}
+
+// CredentialRequest sets a credential's username and password
+type CredentialRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// CredentialListResponse lists credentials without their passwords
+type CredentialListResponse struct {
+	Credentials []CredentialInfo `json:"credentials"`
+}
+
+// CredentialResponse wraps a credential without its password
+type CredentialResponse struct {
+	Credential CredentialInfo `json:"credential"`
+}
+
+// WaiverRequest creates a waiver
+type WaiverRequest struct {
+	CheckID       string `json:"check_id"`
+	Host          string `json:"host"`
+	Instance      string `json:"instance"`
+	Database      string `json:"database"`
+	Justification string `json:"justification"`
+	// ExpiresAt is RFC 3339 or a date (YYYY-MM-DD, expiring at its start, UTC)
+	ExpiresAt string `json:"expires_at"`
+}
+
+// WaiverStatus is a waiver and whether it still applies
+type WaiverStatus struct {
+	Waiver Waiver `json:"waiver"`
+	Active bool   `json:"active"`
+}
+
+// WaiverListResponse lists every waiver
+type WaiverListResponse struct {
+	Waivers []WaiverStatus `json:"waivers"`
+}
+
+// WaiverResponse wraps a waiver
+type WaiverResponse struct {
+	Waiver Waiver `json:"waiver"`
+}
@@ -0,0 +1,479 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Remediation request statuses
+const (
+	RemediationPending   = "pending"
+	RemediationRejected  = "rejected"
+	RemediationApplying  = "applying"
+	RemediationVerifying = "verifying"
+	RemediationCompleted = "completed"
+	RemediationFailed    = "failed"
+)
+
+// Audit actions for automated remediation
+const (
+	AuditRemediationRequest = "remediation.request"
+	AuditRemediationApprove = "remediation.approve"
+	AuditRemediationReject  = "remediation.reject"
+	AuditRemediationApply   = "remediation.apply"
+	AuditRemediationVerify  = "remediation.verify"
+)
+
+const defaultRemediationTimeout = 10 * time.Minute
+
+// RemediationConfig opts in to applying remediation scripts through the
+// executors. Scripts are only generated for download when disabled.
+type RemediationConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Timeout bounds applying the script on one host; 10m if zero
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// RemediationItem is one failed check selected for automated remediation
+type RemediationItem struct {
+	Host    string      `json:"host"`
+	Entity  string      `json:"entity"`
+	CheckID string      `json:"check_id"`
+	Check   string      `json:"check"`
+	Status  CheckStatus `json:"status"`
+	// VerifiedStatus and VerifiedMessage come from re-running the check
+	// after the fix was applied
+	VerifiedStatus  CheckStatus `json:"verified_status,omitempty"`
+	VerifiedMessage string      `json:"verified_message,omitempty"`
+}
+
+// RemediationEvent records one step of a remediation request
+type RemediationEvent struct {
+	Time   string `json:"time"`
+	Actor  string `json:"actor"`
+	Action string `json:"action"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// RemediationRequest asks to apply the remediation of selected checks from a
+// run. It needs approval by a second user before anything runs on a host.
+type RemediationRequest struct {
+	ID          string             `json:"id"`
+	RunID       string             `json:"run_id"`
+	Status      string             `json:"status"`
+	RequestedBy string             `json:"requested_by"`
+	Reason      string             `json:"reason,omitempty"`
+	ReviewedBy  string             `json:"reviewed_by,omitempty"`
+	Items       []RemediationItem  `json:"items"`
+	Output      map[string]string  `json:"output,omitempty"`
+	Events      []RemediationEvent `json:"events"`
+}
+
+// clone deep-copies the request; callers hold runsMu
+func (r *RemediationRequest) clone() *RemediationRequest {
+	out := *r
+	out.Items = append([]RemediationItem(nil), r.Items...)
+	out.Events = append([]RemediationEvent(nil), r.Events...)
+	if r.Output != nil {
+		out.Output = make(map[string]string, len(r.Output))
+		for k, v := range r.Output {
+			out.Output[k] = v
+		}
+	}
+	return &out
+}
+
+// hosts returns the distinct hosts of the request's items, in order
+func (r *RemediationRequest) hosts() []string {
+	var hosts []string
+	for _, item := range r.Items {
+		if _, found := findFold(hosts, item.Host); !found {
+			hosts = append(hosts, item.Host)
+		}
+	}
+	return hosts
+}
+
+func (r *RemediationRequest) checkIDs() []string {
+	ids := make([]string, 0, len(r.Items))
+	for _, item := range r.Items {
+		ids = append(ids, item.CheckID)
+	}
+	return ids
+}
+
+// record appends an event to the request; callers hold runsMu
+func (r *RemediationRequest) record(actor, action, detail string) {
+	r.Events = append(r.Events, RemediationEvent{
+		Time:   time.Now().UTC().Format(time.RFC3339),
+		Actor:  actor,
+		Action: action,
+		Detail: detail,
+	})
+}
+
+// findRemediation returns the remediation request with id and its run
+func findRemediation(id string) (*Run, *RemediationRequest) {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	for _, run := range runs {
+		for _, req := range run.Remediations {
+			if req.ID == id {
+				return run, req
+			}
+		}
+	}
+	return nil, nil
+}
+
+// entityChecks returns the checks recorded for entity in r
+func entityChecks(r *BatchResponse, entity string) []CheckResult {
+	if checks, ok := r.VMResults[entity]; ok {
+		return checks
+	}
+	if checks, ok := r.InstanceResults[entity]; ok {
+		return checks
+	}
+	return r.DatabaseResults[entity]
+}
+
+// applyRemediation runs the approved request's fixes host by host, then
+// re-checks each host with the run's profile to verify them. The caller
+// registers it with beginWork.
+func applyRemediation(run *Run, req *RemediationRequest, approver string) {
+	defer inflight.Done()
+	timeout := currentConfig().Remediation.Timeout
+	if timeout <= 0 {
+		timeout = defaultRemediationTimeout
+	}
+	runsMu.Lock()
+	items := append([]RemediationItem(nil), req.Items...)
+	runsMu.Unlock()
+
+	selected := func(host string) func(string, CheckResult) bool {
+		return func(entity string, check CheckResult) bool {
+			for _, item := range items {
+				if item.Host == host && item.Entity == entity && item.CheckID == check.CheckID {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	failed := false
+	for _, host := range req.hosts() {
+		script, _ := remediationScript(run.results, host, selected(host))
+		script = fmt.Sprintf("$remediation = {\n%s\n}\n& $remediation -Apply *>&1 | Out-String -Width 200\n", script)
+
+		ctx, cancel := context.WithTimeout(serviceCtx, timeout)
+		cred, err := resolveHostCredential(ctx, host)
+		var output string
+		if err == nil {
+			output, err = executorFor(host).Exec(ctx, host, cred, script)
+		}
+		cancel()
+
+		outcome, detail := AuditOutcomeSuccess, host
+		if err != nil {
+			failed = true
+			outcome, detail = AuditOutcomeFailure, fmt.Sprintf("%s: %v", host, err)
+			output = strings.TrimSpace(output + "\n" + err.Error())
+			logrus.Errorf("Remediation %s failed on %s: %v", req.ID, host, err)
+		}
+		runsMu.Lock()
+		req.Output[host] = output
+		req.record(approver, AuditRemediationApply, detail)
+		runsMu.Unlock()
+		writeAudit(AuditEntry{
+			Actor:   approver,
+			Action:  AuditRemediationApply,
+			RunID:   run.ID,
+			Hosts:   []string{host},
+			Checks:  req.checkIDs(),
+			Outcome: outcome,
+			Detail:  "remediation " + req.ID,
+		})
+	}
+
+	runsMu.Lock()
+	req.Status = RemediationVerifying
+	runsMu.Unlock()
+
+	// The re-checked hosts are recorded like a run's: in the latest state
+	// and folded into the run's own state, as a rerun of them would be
+	rechecked := &BatchResponse{
+		RunID:           run.ID,
+		Timestamp:       time.Now().Format("2006-01-02 15:04:05"),
+		VMResults:       make(map[string][]CheckResult),
+		InstanceResults: make(map[string][]CheckResult),
+		DatabaseResults: make(map[string][]CheckResult),
+		HostDetails:     make(map[string]*HostDetail),
+	}
+	var recheckedHosts []string
+	profile, ok := currentConfig().profile(run.snapshot().Profile)
+	if ok {
+		rechecked.Profile, rechecked.ProfileVersion = profile.Name, profile.Version
+	}
+	for _, host := range req.hosts() {
+		var err error
+		if !ok {
+			err = fmt.Errorf("profile %q is no longer loaded", run.snapshot().Profile)
+		} else if err = recheckHost(host, profile, rechecked); err == nil {
+			recheckedHosts = append(recheckedHosts, host)
+		}
+
+		verified := 0
+		runsMu.Lock()
+		for i := range req.Items {
+			item := &req.Items[i]
+			if item.Host != host {
+				continue
+			}
+			item.VerifiedStatus, item.VerifiedMessage = StatusError, "check did not run"
+			if err != nil {
+				item.VerifiedMessage = err.Error()
+			}
+			for _, check := range entityChecks(rechecked, item.Entity) {
+				if check.CheckID == item.CheckID {
+					item.VerifiedStatus, item.VerifiedMessage = check.Status, check.Message
+				}
+			}
+			if item.VerifiedStatus == StatusSuccess {
+				verified++
+			} else {
+				failed = true
+			}
+		}
+		detail := fmt.Sprintf("%s: %d fixed", host, verified)
+		req.record("system", AuditRemediationVerify, detail)
+		runsMu.Unlock()
+		writeAudit(AuditEntry{
+			Actor:   "system",
+			Action:  AuditRemediationVerify,
+			RunID:   run.ID,
+			Hosts:   []string{host},
+			Checks:  req.checkIDs(),
+			Outcome: AuditOutcomeSuccess,
+			Detail:  fmt.Sprintf("remediation %s: %s", req.ID, detail),
+		})
+	}
+	if len(recheckedHosts) > 0 {
+		summarize(rechecked)
+		recordState(rechecked)
+		recordRecheck(run, rechecked, recheckedHosts)
+	}
+
+	runsMu.Lock()
+	req.Status = RemediationCompleted
+	if failed {
+		req.Status = RemediationFailed
+	}
+	logrus.Infof("Remediation %s for run %s %s", req.ID, run.ID, req.Status)
+	runsMu.Unlock()
+}
+
+// recheckHost runs hostname's checks again with profile and adds them to
+// results the way a run's worker does, waivers included
+func recheckHost(hostname string, profile *Profile, results *BatchResponse) error {
+	ctx, cancel := context.WithTimeout(serviceCtx, 5*time.Minute)
+	defer cancel()
+	started := time.Now()
+	cred, err := resolveHostCredential(ctx, hostname)
+	if err != nil {
+		return err
+	}
+	result, output, err := executorFor(hostname).Run(ctx, hostname, cred)
+	if err != nil {
+		return err
+	}
+	var sqlFacts []InstanceFacts
+	if sqlCfg := currentConfig().sqlConfigFor(hostname); sqlCfg != nil {
+		sqlFacts = collectSQLFacts(ctx, hostname, sqlCfg)
+	}
+	results.HostDetails[hostname] = newHostDetail(hostname, started, result, output, sqlFacts, nil)
+	profile.evaluate(result, sqlFacts)
+	applyWaivers(hostname, result)
+	var total, passed, failed, errored int
+	processResults(hostname, result, results, &total, &passed, &failed, &errored)
+	return nil
+}
+
+// recordRecheck merges hosts re-checked after a remediation into the run's
+// latest state, replacing what was known about them, as a rerun of those
+// hosts would; the dashboards and digest move along when they show it
+func recordRecheck(run *Run, rechecked *BatchResponse, hostnames []string) {
+	merged, replaced := (&Rerun{Mode: RerunFailedHosts}).fold(run, rechecked, hostnames)
+	replaceLatestResults(replaced, merged)
+	recordFinished(merged, replaced, true)
+}
+
+// ===== API Handlers =====
+
+// handleRequestRemediation asks to apply the remediation of selected failed
+// checks of a finished run. Each selected check is identified by its entity
+// key (host, host\instance or host\instance\database) and check id.
+func handleRequestRemediation(c *gin.Context) {
+	if !currentConfig().Remediation.Enabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Automated remediation is disabled"})
+		return
+	}
+	run := getRun(c.Param("id"))
+	if run == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Run not found"})
+		return
+	}
+	if run.snapshot().FinishedAt == "" || run.results == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Run has not finished"})
+		return
+	}
+
+	var body CreateRemediationRequest
+	if err := c.ShouldBindJSON(&body); err != nil || len(body.Checks) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "checks must list the entity and check_id to remediate"})
+		return
+	}
+
+	principal := currentPrincipal(c)
+	access := accessFor(principal)
+	req := &RemediationRequest{
+		ID:          newID(),
+		RunID:       run.ID,
+		Status:      RemediationPending,
+		RequestedBy: principal.Subject,
+		Reason:      strings.TrimSpace(body.Reason),
+		Output:      make(map[string]string),
+	}
+	for _, sel := range body.Checks {
+		host, _, _ := strings.Cut(sel.Entity, "\\")
+		if !access.canTarget(host) {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Not permitted to remediate %s", host)})
+			return
+		}
+		var found *CheckResult
+		for _, check := range entityChecks(run.results, sel.Entity) {
+			if check.CheckID == sel.CheckID {
+				found = &check
+				break
+			}
+		}
+		if found == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("No check %q on %s in this run", sel.CheckID, sel.Entity)})
+			return
+		}
+		if found.Status != StatusFailed && found.Status != StatusWarning {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Check %q on %s is %s, not failed", sel.CheckID, sel.Entity, found.Status)})
+			return
+		}
+		if !isAutomated(found.Remediation) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Check %q has no automated remediation", sel.CheckID)})
+			return
+		}
+		req.Items = append(req.Items, RemediationItem{
+			Host:    host,
+			Entity:  sel.Entity,
+			CheckID: found.CheckID,
+			Check:   found.Check,
+			Status:  found.Status,
+		})
+	}
+	req.record(req.RequestedBy, AuditRemediationRequest, req.Reason)
+
+	runsMu.Lock()
+	run.Remediations = append(run.Remediations, req)
+	snap := req.clone()
+	runsMu.Unlock()
+
+	auditRequest(c, AuditEntry{
+		Action:  AuditRemediationRequest,
+		RunID:   run.ID,
+		Hosts:   req.hosts(),
+		Checks:  req.checkIDs(),
+		Outcome: AuditOutcomeSuccess,
+		Detail:  "remediation " + req.ID,
+	})
+	c.JSON(http.StatusCreated, RemediationResponse{Remediation: snap})
+}
+
+// handleReviewRemediation approves or rejects a pending remediation request.
+// The reviewer must not be the requester. Approval applies the fixes in the
+// background; progress shows on the run.
+func handleReviewRemediation(approve bool) gin.HandlerFunc {
+	action := AuditRemediationReject
+	if approve {
+		action = AuditRemediationApprove
+	}
+	return func(c *gin.Context) {
+		if !currentConfig().Remediation.Enabled {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Automated remediation is disabled"})
+			return
+		}
+		run, req := findRemediation(c.Param("id"))
+		if req == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Remediation request not found"})
+			return
+		}
+		reviewer := currentPrincipal(c).Subject
+		var body ReviewRemediationRequest
+		_ = c.ShouldBindJSON(&body)
+
+		entry := AuditEntry{
+			Action: action,
+			RunID:  run.ID,
+			Hosts:  req.hosts(),
+			Checks: req.checkIDs(),
+			Detail: "remediation " + req.ID,
+		}
+		if denied := accessFor(currentPrincipal(c)).deniedHosts(req.hosts()); len(denied) > 0 {
+			entry.Outcome = AuditOutcomeDenied
+			auditRequest(c, entry)
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Not permitted to remediate %v", denied)})
+			return
+		}
+		if approve && !beginWork() {
+			rejectShuttingDown(c)
+			return
+		}
+
+		runsMu.Lock()
+		var problem string
+		switch {
+		case req.Status != RemediationPending:
+			problem = "Remediation request is already " + req.Status
+		case strings.EqualFold(req.RequestedBy, reviewer):
+			problem = "Remediation must be reviewed by someone other than the requester"
+		}
+		if problem != "" {
+			runsMu.Unlock()
+			if approve {
+				inflight.Done()
+			}
+			entry.Outcome = AuditOutcomeDenied
+			auditRequest(c, entry)
+			c.JSON(http.StatusConflict, gin.H{"error": problem})
+			return
+		}
+		req.ReviewedBy = reviewer
+		req.Status = RemediationRejected
+		if approve {
+			req.Status = RemediationApplying
+		}
+		req.record(reviewer, action, strings.TrimSpace(body.Comment))
+		snap := req.clone()
+		runsMu.Unlock()
+
+		entry.Outcome = AuditOutcomeSuccess
+		auditRequest(c, entry)
+		if approve {
+			logrus.Infof("Remediation %s approved by %s", req.ID, reviewer)
+			go applyRemediation(run, req, reviewer)
+		}
+		c.JSON(http.StatusOK, RemediationResponse{Remediation: snap})
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestRemediationRecordsRecheck applies a remediation through the ssh
+// executor and checks the verification lands where a run's results do
+func TestRemediationRecordsRecheck(t *testing.T) {
+	keyPath, pub := writeClientKey(t, "")
+	s := startSSHStandIn(t, pub)
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	configYAML := fmt.Sprintf(`executor:
+  default: ssh
+  ssh: {port: %d, insecure_ignore_host_key: true, user: keyuser, private_key: %s}
+history: {path: %[3]s/history.jsonl}
+waivers: {store_path: %[3]s/waivers.json}
+state: {store_path: %[3]s/state.json}
+remediation: {enabled: true}
+`, s.port, keyPath, dir)
+	if err := os.WriteFile(configFile, []byte(configYAML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withConfig(t, cfg)
+	if waiverStore, err = openWaiverStore(cfg.Waivers.StorePath); err != nil {
+		t.Fatal(err)
+	}
+	if stateStore, err = openStateStore(cfg.State.StorePath); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { waiverStore, stateStore = nil, nil })
+	previousResults := latestResults()
+	finished, finishedBefore := latestFinished()
+	t.Cleanup(func() {
+		setLatestResults(previousResults)
+		recordFinished(finished, finishedBefore, false)
+	})
+
+	const host = "127.0.0.1"
+	facts := func(policy, recovery string) *ComprehensiveResult {
+		return &ComprehensiveResult{Success: true, Facts: &HostFacts{
+			VM: map[string]any{"execution_policy": policy, "memory_gb": 16, "disks": disks(50)},
+			Instances: []InstanceFacts{
+				instanceFacts(defaultSQLInstance, "15.0.2000.5", "Developer Edition", "RTM", []DatabaseFacts{
+					sqlDatabaseRow{Name: "Sales", State: "ONLINE", RecoveryModel: recovery, DataMB: 120, LogMB: 8}.facts(),
+				}),
+			},
+		}}
+	}
+	profile, _ := cfg.profile("")
+	before := facts("RemoteSigned", "SIMPLE")
+	profile.evaluate(before, nil)
+	results := &BatchResponse{
+		RunID:           newID(),
+		Profile:         profile.Name,
+		ProfileVersion:  profile.Version,
+		VMResults:       make(map[string][]CheckResult),
+		InstanceResults: make(map[string][]CheckResult),
+		DatabaseResults: make(map[string][]CheckResult),
+		HostDetails:     make(map[string]*HostDetail),
+	}
+	var total, passed, failed, errored int
+	processResults(host, before, results, &total, &passed, &failed, &errored)
+	summarize(results)
+	run := startRun(results, []string{host}, "seed", profile, nil)
+	finishRun(run)
+	recordFinished(results, nil, false)
+	setLatestResults(results)
+
+	// The fix worked; meanwhile the execution policy broke and was waived
+	waiverStore.Add(Waiver{ID: "w1", CheckID: "vm.execution_policy", Host: host,
+		ExpiresAt: time.Now().Add(time.Hour).UTC().Format(time.RFC3339)})
+	after, _ := json.Marshal(facts("Restricted", "FULL"))
+	s.respond(string(after), "", 0)
+
+	sales := host + `\` + defaultSQLInstance + `\Sales`
+	was := checkStatus(results.DatabaseResults[sales], "database.recovery_model")
+	if was == StatusSuccess {
+		t.Fatal("seeded recovery model passes")
+	}
+	req := &RemediationRequest{
+		ID:     newID(),
+		RunID:  run.ID,
+		Status: RemediationApplying,
+		Items:  []RemediationItem{{Host: host, Entity: sales, CheckID: "database.recovery_model", Status: was}},
+		Output: make(map[string]string),
+	}
+	runsMu.Lock()
+	run.Remediations = append(run.Remediations, req)
+	runsMu.Unlock()
+	if !beginWork() {
+		t.Fatal("work refused")
+	}
+	applyRemediation(run, req, "approver")
+
+	if req.Status != RemediationCompleted || req.Items[0].VerifiedStatus != StatusSuccess {
+		t.Fatalf("remediation %s, item verified %s: %s", req.Status, req.Items[0].VerifiedStatus, req.Items[0].VerifiedMessage)
+	}
+	state := run.latestState()
+	if state == results {
+		t.Fatal("run state was not updated")
+	}
+	if got := checkStatus(state.DatabaseResults[sales], "database.recovery_model"); got != StatusSuccess {
+		t.Errorf("run state recovery model = %s, want success", got)
+	}
+	if got := checkStatus(state.VMResults[host], "vm.execution_policy"); got != StatusWaived {
+		t.Errorf("run state execution policy = %s, want waived", got)
+	}
+	if got := checkStatus(results.DatabaseResults[sales], "database.recovery_model"); got != was {
+		t.Errorf("run results changed in place: recovery model = %s", got)
+	}
+	if latestResults() != state {
+		t.Error("dashboards do not show the verified state")
+	}
+	if current, _ := latestFinished(); current != state {
+		t.Error("digest does not report the verified state")
+	}
+
+	stored := stateStore.Query(StateFilter{Host: host}, time.Now())
+	found := false
+	for _, e := range stored {
+		for _, check := range e.Checks {
+			if e.Key == sales && check.CheckID == "database.recovery_model" {
+				found = check.Status == StatusSuccess
+			}
+		}
+	}
+	if !found {
+		t.Errorf("latest state lacks the verified check: %+v", stored)
+	}
+}
+
+// checkStatus returns the status of checkID in checks, or "" if absent
+func checkStatus(checks []CheckResult, checkID string) CheckStatus {
+	for _, check := range checks {
+		if check.CheckID == checkID {
+			return check.Status
+		}
+	}
+	return ""
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Audit actions
+const (
+	AuditRunStart     = "run.start"
+	AuditRunFinish    = "run.finish"
+	AuditRunCancel    = "run.cancel"
+	AuditConfigReload = "config.reload"
+	AuditAccessDenied = "access.denied"
+)
+
+// Audit outcomes
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeDenied  = "denied"
+	AuditOutcomeFailure = "failure"
+)
+
+const defaultAuditPath = "./data/audit.jsonl"
+
+// AuditConfig sets where the append-only audit log is written
+type AuditConfig struct {
+	Path string `yaml:"path"`
+}
+
+// AuditEntry is one line of the audit log
+type AuditEntry struct {
+	Time           string   `json:"time"`
+	Actor          string   `json:"actor"`
+	ActorKind      string   `json:"actor_kind,omitempty"`
+	ClientIP       string   `json:"client_ip,omitempty"`
+	Action         string   `json:"action"`
+	RunID          string   `json:"run_id,omitempty"`
+	Hosts          []string `json:"hosts,omitempty"`
+	Checks         []string `json:"checks,omitempty"`
+	CredentialRefs []string `json:"credential_refs,omitempty"`
+	Outcome        string   `json:"outcome"`
+	Detail         string   `json:"detail,omitempty"`
+}
+
+// ===== Globals =====
+
+var auditMu sync.Mutex
+
+func auditPath() string {
+	if path := currentConfig().Audit.Path; path != "" {
+		return path
+	}
+	return defaultAuditPath
+}
+
+// writeAudit appends entry to the audit log. Entries are only ever appended;
+// nothing in the service rewrites or truncates the file.
+func writeAudit(entry AuditEntry) {
+	if entry.Time == "" {
+		entry.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		logrus.Errorf("Failed to encode audit entry: %v", err)
+		return
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	path := auditPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		logrus.Errorf("Failed to create audit log directory: %v", err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		logrus.Errorf("Failed to open audit log %s: %v", path, err)
+		return
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		logrus.Errorf("Failed to write audit log %s: %v", path, err)
+		return
+	}
+	if err := f.Sync(); err != nil {
+		logrus.Errorf("Failed to sync audit log %s: %v", path, err)
+	}
+}
+
+// auditRequest records entry attributed to the caller of c
+func auditRequest(c *gin.Context, entry AuditEntry) {
+	if p := currentPrincipal(c); p != nil {
+		entry.Actor = p.Subject
+		entry.ActorKind = p.Kind
+	} else {
+		entry.Actor = "unauthenticated"
+	}
+	entry.ClientIP = c.ClientIP()
+	writeAudit(entry)
+}
+
+// AuditFilter selects audit entries; zero fields match everything
+type AuditFilter struct {
+	Actor  string
+	Action string
+	RunID  string
+	Host   string
+	Since  time.Time
+	Until  time.Time
+}
+
+func (f AuditFilter) matches(e AuditEntry) bool {
+	if f.Actor != "" && e.Actor != f.Actor {
+		return false
+	}
+	if f.Action != "" && e.Action != f.Action {
+		return false
+	}
+	if f.RunID != "" && e.RunID != f.RunID {
+		return false
+	}
+	if f.Host != "" {
+		found := false
+		for _, h := range e.Hosts {
+			if strings.EqualFold(h, f.Host) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !f.Since.IsZero() || !f.Until.IsZero() {
+		t, err := time.Parse(time.RFC3339Nano, e.Time)
+		if err != nil {
+			return false
+		}
+		if !f.Since.IsZero() && t.Before(f.Since) {
+			return false
+		}
+		if !f.Until.IsZero() && t.After(f.Until) {
+			return false
+		}
+	}
+	return true
+}
+
+// readAudit calls fn for every entry matching filter, oldest first
+func readAudit(filter AuditFilter, fn func(AuditEntry, []byte)) error {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	f, err := os.Open(auditPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var e AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		if filter.matches(e) {
+			fn(e, scanner.Bytes())
+		}
+	}
+	return scanner.Err()
+}
+
+func parseAuditFilter(c *gin.Context) (AuditFilter, error) {
+	filter := AuditFilter{
+		Actor:  c.Query("actor"),
+		Action: c.Query("action"),
+		RunID:  c.Query("run_id"),
+		Host:   c.Query("host"),
+	}
+	for name, dst := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := c.Query(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+			}
+			*dst = t
+		}
+	}
+	return filter, nil
+}
+
+// ===== API Handlers =====
+
+// handleAuditQuery returns matching audit entries, newest first
+func handleAuditQuery(c *gin.Context) {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+	}
+
+	var entries []AuditEntry
+	err = readAudit(filter, func(e AuditEntry, _ []byte) {
+		entries = append(entries, e)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to read audit log: %v", err)})
+		return
+	}
+
+	total := len(entries)
+	result := make([]AuditEntry, 0, limit)
+	for i := len(entries) - 1; i >= 0 && len(result) < limit; i-- {
+		result = append(result, entries[i])
+	}
+	c.JSON(http.StatusOK, AuditResponse{Entries: result, Total: total})
+}
+
+// handleAuditExport streams matching audit entries as JSON lines, oldest first
+func handleAuditExport(c *gin.Context) {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-%s.jsonl"`, time.Now().UTC().Format("20060102-150405")))
+	c.Status(http.StatusOK)
+	err = readAudit(filter, func(_ AuditEntry, line []byte) {
+		c.Writer.Write(line)
+		c.Writer.Write([]byte("\n"))
+	})
+	if err != nil {
+		logrus.Errorf("Audit export failed: %v", err)
+	}
+}
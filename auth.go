@@ -0,0 +1,516 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+const (
+	sessionCookie     = "ndb_session"
+	oidcStateCookie   = "ndb_oidc_state"
+	principalKey      = "principal"
+	defaultSessionTTL = 8 * time.Hour
+)
+
+// AuthConfig configures API token and OIDC authentication
+type AuthConfig struct {
+	// Disabled turns authentication off entirely; only for local development
+	Disabled      bool          `yaml:"disabled"`
+	Tokens        []APIToken    `yaml:"tokens"`
+	SessionSecret string        `yaml:"session_secret"`
+	SessionTTL    time.Duration `yaml:"session_ttl"`
+	OIDC          OIDCConfig    `yaml:"oidc"`
+}
+
+// APIToken is a bearer token for automation. Either Token (plain) or
+// SHA256 (hex digest of the token) must be set.
+type APIToken struct {
+	Name   string   `yaml:"name"`
+	Token  string   `yaml:"token"`
+	SHA256 string   `yaml:"sha256"`
+	Scopes []string `yaml:"scopes"`
+}
+
+// OIDCConfig configures OpenID Connect login for the UI
+type OIDCConfig struct {
+	Issuer       string   `yaml:"issuer"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url"`
+	Scopes       []string `yaml:"scopes"`
+	GroupsClaim  string   `yaml:"groups_claim"`
+}
+
+// CORSConfig lists the browser origins allowed to call the API
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins"`
+}
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	Subject string   `json:"subject"`
+	Name    string   `json:"name"`
+	Kind    string   `json:"kind"`
+	Groups  []string `json:"groups,omitempty"`
+	Scopes  []string `json:"scopes,omitempty"`
+	Expires int64    `json:"exp,omitempty"`
+}
+
+// ===== Globals =====
+
+// sessionKey signs session and OIDC state cookies; replaced on reload
+var sessionKey atomic.Pointer[[]byte]
+
+// oidcProvider was discovered from oidcIssuer; a reload that changes the
+// issuer discovers the new one on next use
+var oidcMu sync.Mutex
+var oidcProvider *oidc.Provider
+var oidcIssuer string
+
+func (a AuthConfig) oidcEnabled() bool {
+	return a.OIDC.Issuer != ""
+}
+
+func (a AuthConfig) validate() error {
+	for _, t := range a.Tokens {
+		if t.Name == "" {
+			return fmt.Errorf("auth tokens need a name")
+		}
+		if (t.Token == "") == (t.SHA256 == "") {
+			return fmt.Errorf("auth token %q needs exactly one of token or sha256", t.Name)
+		}
+		if t.SHA256 != "" {
+			if b, err := hex.DecodeString(t.SHA256); err != nil || len(b) != sha256.Size {
+				return fmt.Errorf("auth token %q sha256 must be a hex SHA-256 digest", t.Name)
+			}
+		}
+	}
+	if a.oidcEnabled() && (a.OIDC.ClientID == "" || a.OIDC.RedirectURL == "") {
+		return fmt.Errorf("auth.oidc needs client_id and redirect_url")
+	}
+	return nil
+}
+
+// initAuth prepares the session signing key and warns about insecure setups
+func initAuth() {
+	cfg := currentConfig().Auth
+	if cfg.Disabled {
+		logrus.Warn("Authentication is DISABLED: every API endpoint is open to anyone who can reach the service")
+		return
+	}
+	if len(cfg.Tokens) == 0 && !cfg.oidcEnabled() {
+		logrus.Warn("No API tokens or OIDC provider configured: all API requests will be rejected")
+	}
+
+	key := []byte(cfg.SessionSecret)
+	if cfg.SessionSecret == "" {
+		key = make([]byte, 32)
+		rand.Read(key)
+		if cfg.oidcEnabled() {
+			logrus.Warn("auth.session_secret not set: UI sessions will not survive a restart")
+		}
+	}
+	sessionKey.Store(&key)
+}
+
+// corsMiddleware applies the CORS policy of the running configuration,
+// rebuilding it when the configuration is reloaded
+func corsMiddleware() gin.HandlerFunc {
+	var mu sync.Mutex
+	var builtFor *Config
+	var policy gin.HandlerFunc
+	return func(c *gin.Context) {
+		cfg := currentConfig()
+		mu.Lock()
+		if builtFor != cfg {
+			builtFor, policy = cfg, corsPolicy(cfg.CORS.AllowedOrigins)
+		}
+		apply := policy
+		mu.Unlock()
+		apply(c)
+	}
+}
+
+// corsPolicy builds the CORS policy for origins. With no origins configured
+// only same-origin browser requests work.
+func corsPolicy(origins []string) gin.HandlerFunc {
+	if len(origins) == 0 {
+		return func(c *gin.Context) {}
+	}
+
+	cfg := cors.Config{
+		AllowMethods:  []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowHeaders:  []string{"Origin", "Content-Type", "Authorization"},
+		ExposeHeaders: []string{"Content-Length"},
+		MaxAge:        12 * time.Hour,
+	}
+	for _, o := range origins {
+		if o == "*" {
+			// Credentials are never combined with a wildcard origin.
+			cfg.AllowAllOrigins = true
+			return cors.New(cfg)
+		}
+	}
+	cfg.AllowOrigins = origins
+	cfg.AllowCredentials = true
+	return cors.New(cfg)
+}
+
+// requireAuth rejects requests without a valid API token or UI session
+func requireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if currentConfig().Auth.Disabled {
+			c.Set(principalKey, &Principal{Subject: "anonymous", Name: "anonymous", Kind: "anonymous"})
+			c.Next()
+			return
+		}
+
+		if p := authenticateRequest(c.Request); p != nil {
+			c.Set(principalKey, p)
+			c.Next()
+			return
+		}
+
+		if currentConfig().Auth.oidcEnabled() {
+			c.Header("WWW-Authenticate", `Bearer realm="ndb-precheck", login="/auth/login"`)
+		} else {
+			c.Header("WWW-Authenticate", `Bearer realm="ndb-precheck"`)
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+	}
+}
+
+func authenticateRequest(r *http.Request) *Principal {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return authenticateToken(strings.TrimSpace(strings.TrimPrefix(h, "Bearer ")))
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		if p, err := decodeSession(cookie.Value); err == nil {
+			return p
+		}
+	}
+	return nil
+}
+
+// authenticateToken matches a bearer token against the configured tokens
+func authenticateToken(token string) *Principal {
+	if token == "" {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(token))
+	for _, t := range currentConfig().Auth.Tokens {
+		var match bool
+		if t.SHA256 != "" {
+			want, _ := hex.DecodeString(t.SHA256)
+			match = subtle.ConstantTimeCompare(sum[:], want) == 1
+		} else {
+			plain := sha256.Sum256([]byte(t.Token))
+			match = subtle.ConstantTimeCompare(sum[:], plain[:]) == 1
+		}
+		if match {
+			return &Principal{Subject: "token:" + t.Name, Name: t.Name, Kind: "token", Scopes: t.Scopes}
+		}
+	}
+	return nil
+}
+
+// currentPrincipal returns the caller set by requireAuth
+func currentPrincipal(c *gin.Context) *Principal {
+	if v, ok := c.Get(principalKey); ok {
+		return v.(*Principal)
+	}
+	return nil
+}
+
+// ===== Sessions =====
+
+func signValue(payload []byte) string {
+	mac := hmac.New(sha256.New, *sessionKey.Load())
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func verifyValue(value string) ([]byte, error) {
+	data, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return nil, errors.New("malformed value")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return nil, err
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, *sessionKey.Load())
+	mac.Write(payload)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return nil, errors.New("bad signature")
+	}
+	return payload, nil
+}
+
+func encodeSession(p *Principal) (string, error) {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	return signValue(payload), nil
+}
+
+func decodeSession(value string) (*Principal, error) {
+	payload, err := verifyValue(value)
+	if err != nil {
+		return nil, err
+	}
+	var p Principal
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, err
+	}
+	if time.Now().Unix() > p.Expires {
+		return nil, errors.New("session expired")
+	}
+	return &p, nil
+}
+
+func secureCookies() bool {
+	return strings.HasPrefix(currentConfig().Auth.OIDC.RedirectURL, "https://")
+}
+
+// ===== OIDC =====
+
+// getOIDCProvider discovers the provider on first use so a slow IdP doesn't
+// block startup, and again after a reload changes the issuer
+func getOIDCProvider(ctx context.Context) (*oidc.Provider, error) {
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	issuer := currentConfig().Auth.OIDC.Issuer
+	if oidcProvider != nil && oidcIssuer == issuer {
+		return oidcProvider, nil
+	}
+	p, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed for %s: %v", issuer, err)
+	}
+	oidcProvider, oidcIssuer = p, issuer
+	return p, nil
+}
+
+func oauth2Config(p *oidc.Provider) *oauth2.Config {
+	cfg := currentConfig().Auth.OIDC
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+	}
+	return &oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		Endpoint:     p.Endpoint(),
+		Scopes:       scopes,
+	}
+}
+
+// safeRedirect only allows local paths as post-login destinations
+func safeRedirect(next string) string {
+	if next == "" || !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") {
+		return "/"
+	}
+	return next
+}
+
+// handleLogin starts the OIDC authorization code flow
+func handleLogin(c *gin.Context) {
+	if !currentConfig().Auth.oidcEnabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OIDC login is not configured"})
+		return
+	}
+	provider, err := getOIDCProvider(c.Request.Context())
+	if err != nil {
+		logrus.Error(err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		return
+	}
+
+	state := newID() + newID()
+	nonce := newID() + newID()
+	next := safeRedirect(c.Query("next"))
+	payload, _ := json.Marshal(map[string]string{"state": state, "nonce": nonce, "next": next})
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    signValue(payload),
+		Path:     "/auth",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   secureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	c.Redirect(http.StatusFound, oauth2Config(provider).AuthCodeURL(state, oidc.Nonce(nonce)))
+}
+
+// handleCallback completes the OIDC flow and issues the session cookie
+func handleCallback(c *gin.Context) {
+	provider, err := getOIDCProvider(c.Request.Context())
+	if err != nil {
+		logrus.Error(err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider unavailable"})
+		return
+	}
+
+	cookie, err := c.Request.Cookie(oidcStateCookie)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Login session expired, please retry"})
+		return
+	}
+	raw, err := verifyValue(cookie.Value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid login state"})
+		return
+	}
+	var flow map[string]string
+	if err := json.Unmarshal(raw, &flow); err != nil || flow["state"] != c.Query("state") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid login state"})
+		return
+	}
+	if e := c.Query("error"); e != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed: " + e})
+		return
+	}
+
+	token, err := oauth2Config(provider).Exchange(c.Request.Context(), c.Query("code"))
+	if err != nil {
+		logrus.Errorf("OIDC code exchange failed: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed"})
+		return
+	}
+	rawID, ok := token.Extra("id_token").(string)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed: no id_token returned"})
+		return
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: currentConfig().Auth.OIDC.ClientID}).Verify(c.Request.Context(), rawID)
+	if err != nil || idToken.Nonce != flow["nonce"] {
+		logrus.Errorf("OIDC id_token verification failed: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed"})
+		return
+	}
+
+	principal, err := principalFromIDToken(idToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	value, err := encodeSession(principal)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+
+	http.SetCookie(c.Writer, &http.Cookie{Name: oidcStateCookie, Path: "/auth", MaxAge: -1})
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    value,
+		Path:     "/",
+		Expires:  time.Unix(principal.Expires, 0),
+		HttpOnly: true,
+		Secure:   secureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+	logrus.Infof("User %s logged in via OIDC", principal.Subject)
+	c.Redirect(http.StatusFound, safeRedirect(flow["next"]))
+}
+
+func principalFromIDToken(idToken *oidc.IDToken) (*Principal, error) {
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to read token claims: %v", err)
+	}
+
+	name, _ := claims["preferred_username"].(string)
+	if name == "" {
+		name, _ = claims["email"].(string)
+	}
+	if name == "" {
+		name = idToken.Subject
+	}
+
+	groupsClaim := currentConfig().Auth.OIDC.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+	var groups []string
+	if list, ok := claims[groupsClaim].([]interface{}); ok {
+		for _, g := range list {
+			if s, ok := g.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+	}
+
+	ttl := currentConfig().Auth.SessionTTL
+	if ttl <= 0 {
+		ttl = defaultSessionTTL
+	}
+	return &Principal{
+		Subject: "oidc:" + idToken.Subject,
+		Name:    name,
+		Kind:    "oidc",
+		Groups:  groups,
+		Expires: time.Now().Add(ttl).Unix(),
+	}, nil
+}
+
+// handleLogout clears the UI session
+func handleLogout(c *gin.Context) {
+	http.SetCookie(c.Writer, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+	c.Redirect(http.StatusFound, "/")
+}
+
+// handleMe returns the authenticated caller and its effective permissions
+func handleMe(c *gin.Context) {
+	p := currentPrincipal(c)
+	access := accessFor(p)
+	groups := make([]string, 0, len(access.HostGroups))
+	for g := range access.HostGroups {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+	c.JSON(http.StatusOK, MeResponse{Principal: p, Role: access.Role.String(), HostGroups: groups})
+}
+
+// requireUISession sends browsers without a session to the OIDC login page
+// before serving the SPA. Without OIDC the UI shell is served as before.
+func requireUISession(c *gin.Context) bool {
+	if auth := currentConfig().Auth; auth.Disabled || !auth.oidcEnabled() {
+		return true
+	}
+	if authenticateRequest(c.Request) != nil {
+		return true
+	}
+	c.Redirect(http.StatusFound, "/auth/login?next="+url.QueryEscape(c.Request.URL.RequestURI()))
+	return false
+}
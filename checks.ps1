@@ -0,0 +1,107 @@
+# Fact collection executed on the target host.
+# Runs remotely via Invoke-Command (script.ps1) or directly over WinRM/SSH by
+# the service. It only gathers facts; the service's rule engine decides what
+# passes, so rules can change without redistributing this script.
+param($ComputerName)
+
+$vmFacts = @{}
+$instances = @()
+
+# VM facts - PowerShell execution policy
+try {
+    $vmFacts.execution_policy = (Get-ExecutionPolicy).ToString()
+}
+catch {
+    $vmFacts.execution_policy_error = $_.Exception.Message
+}
+
+# VM facts - operating system, CPU and memory
+try {
+    $os = Get-CimInstance -ClassName Win32_OperatingSystem
+    $cs = Get-CimInstance -ClassName Win32_ComputerSystem
+    $vmFacts.os_caption = $os.Caption
+    $vmFacts.os_version = $os.Version
+    $vmFacts.cpu_count = [int]$cs.NumberOfLogicalProcessors
+    $vmFacts.memory_gb = [math]::Round($cs.TotalPhysicalMemory / 1GB, 1)
+}
+catch {
+    $vmFacts.os_error = $_.Exception.Message
+}
+
+# VM facts - local fixed disks
+try {
+    $vmFacts.disks = @(Get-CimInstance -ClassName Win32_LogicalDisk -Filter "DriveType = 3" | ForEach-Object {
+        @{
+            drive = $_.DeviceID
+            size_gb = [math]::Round($_.Size / 1GB, 1)
+            free_gb = [math]::Round($_.FreeSpace / 1GB, 1)
+        }
+    })
+}
+catch {
+    $vmFacts.disks_error = $_.Exception.Message
+}
+
+# Instance and database facts - SQL Server services, plus metadata when the
+# SqlServer module is available on the target
+$services = @(Get-Service -Name 'MSSQLSERVER', 'MSSQL$*' -ErrorAction SilentlyContinue)
+$canQuery = [bool](Get-Command -Name Invoke-Sqlcmd -ErrorAction SilentlyContinue)
+
+foreach ($service in $services) {
+    $name = if ($service.Name -eq 'MSSQLSERVER') { 'MSSQLSERVER' } else { $service.Name.Substring(6) }
+    $instance = @{
+        name = $name
+        facts = @{ service_status = $service.Status.ToString() }
+        databases = @()
+    }
+
+    if ($canQuery -and $service.Status -eq 'Running') {
+        $serverInstance = if ($name -eq 'MSSQLSERVER') { '.' } else { ".\$name" }
+        try {
+            $props = Invoke-Sqlcmd -ServerInstance $serverInstance -Database master -ErrorAction Stop -Query "SELECT
+                CAST(SERVERPROPERTY('ProductVersion') AS nvarchar(128)) AS version,
+                CAST(SERVERPROPERTY('Edition') AS nvarchar(128)) AS edition,
+                CAST(SERVERPROPERTY('ProductLevel') AS nvarchar(128)) AS product_level"
+            $dbs = @(Invoke-Sqlcmd -ServerInstance $serverInstance -Database master -ErrorAction Stop -Query "SELECT
+                d.name, d.state_desc, d.recovery_model_desc,
+                CAST(COALESCE(SUM(CASE WHEN mf.type = 0 THEN mf.size END), 0) * 8 / 1024.0 AS float) AS data_mb,
+                CAST(COALESCE(SUM(CASE WHEN mf.type = 1 THEN mf.size END), 0) * 8 / 1024.0 AS float) AS log_mb
+                FROM sys.databases d
+                LEFT JOIN sys.master_files mf ON mf.database_id = d.database_id
+                WHERE d.database_id > 4
+                GROUP BY d.name, d.state_desc, d.recovery_model_desc")
+
+            $instance.facts.connected = $true
+            $instance.facts.version = $props.version
+            $instance.facts.major_version = [int]($props.version.Split('.')[0])
+            $instance.facts.edition = $props.edition
+            $instance.facts.product_level = $props.product_level
+            $instance.facts.user_database_count = $dbs.Count
+            $instance.databases = @($dbs | ForEach-Object {
+                @{
+                    name = $_.name
+                    facts = @{
+                        state = $_.state_desc
+                        recovery_model = $_.recovery_model_desc
+                        data_mb = [double]$_.data_mb
+                        log_mb = [double]$_.log_mb
+                    }
+                }
+            })
+        }
+        catch {
+            $instance.facts.connected = $false
+            $instance.facts.error = $_.Exception.Message
+        }
+    }
+
+    $instances += $instance
+}
+
+return @{
+    Facts = @{
+        vm = $vmFacts
+        instances = $instances
+    }
+    Success = $true
+}
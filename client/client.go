@@ -0,0 +1,117 @@
+// Package client calls the NDB PreCheck Service REST API with typed
+// requests and responses against the frozen /api/v1 contract. The types and
+// methods in zz_generated.go are generated from contracts/v1.openapi.json;
+// run go generate after changing the API.
+//
+//	c := client.New("https://precheck.example.com", token)
+//	started, err := c.StartCheck(ctx, client.CheckRequest{Hostnames: "sql01,sql02"})
+package client
+
+//go:generate sh -c "cd .. && go run . contract -update"
+//go:generate go run ./gen -spec ../contracts/v1.openapi.json -out zz_generated.go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client calls the service at BaseURL with an API token
+type Client struct {
+	// BaseURL is the service root, without /api
+	BaseURL string
+	// Token is sent as a bearer token; empty when auth is disabled
+	Token      string
+	HTTPClient *http.Client
+}
+
+// New returns a client for the service at baseURL
+func New(baseURL, token string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), Token: token, HTTPClient: http.DefaultClient}
+}
+
+// APIError is an error response from the service
+type APIError struct {
+	StatusCode int
+	Message    string
+	// Candidates lists the matching keys when an entity name is ambiguous
+	Candidates []string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("ndb-precheck: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// RemediationScript returns the remediation script of one host of a run
+func (c *Client) RemediationScript(ctx context.Context, runID, host string) (string, error) {
+	script, err := c.doRaw(ctx, http.MethodGet, fmt.Sprintf("/runs/%s/remediation", url.PathEscape(runID)), url.Values{"host": {host}}, nil)
+	return string(script), err
+}
+
+// do sends body as JSON and decodes a JSON response into out, if not nil
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	data, err := c.doRaw(ctx, method, path, query, body)
+	if err != nil || out == nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("ndb-precheck: decode %s %s: %v", method, path, err)
+	}
+	return nil
+}
+
+// doRaw sends body as JSON and returns the response body
+func (c *Client) doRaw(ctx context.Context, method, path string, query url.Values, body any) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	u := c.BaseURL + "/api/v1" + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		var e ErrorResponse
+		if json.Unmarshal(data, &e) == nil && e.Error != "" {
+			apiErr.Message, apiErr.Candidates = e.Error, e.Candidates
+		} else {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		return nil, apiErr
+	}
+	return data, nil
+}
@@ -0,0 +1,341 @@
+// Command gen writes the Go client's types and methods from the service's
+// OpenAPI document. It understands the subset of OpenAPI the service emits.
+//
+//	go run ./gen -spec openapi.json -out zz_generated.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Enum                 []string           `json:"enum"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	Items                *schema            `json:"items"`
+	AdditionalProperties *schema            `json:"additionalProperties"`
+	AllOf                []*schema          `json:"allOf"`
+	GoName               string             `json:"x-go-name"`
+	GoKeyType            string             `json:"x-go-key-type"`
+	Order                int                `json:"x-order"`
+}
+
+// components are the named schemas, to tell structs from other named types
+var components map[string]*schema
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Schema      *schema `json:"schema"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type operation struct {
+	OperationID string      `json:"operationId"`
+	Summary     string      `json:"summary"`
+	Description string      `json:"description"`
+	Parameters  []parameter `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]mediaType `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]mediaType `json:"content"`
+	} `json:"responses"`
+}
+
+type document struct {
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+func main() {
+	specPath := flag.String("spec", "openapi.json", "OpenAPI document to read")
+	outPath := flag.String("out", "zz_generated.go", "Go file to write")
+	flag.Parse()
+
+	data, err := os.ReadFile(*specPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		log.Fatalf("parse %s: %v", *specPath, err)
+	}
+
+	components = doc.Components.Schemas
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by go run ./gen; DO NOT EDIT.\n\npackage client\n\n")
+	fmt.Fprintf(&b, "import (\n\"context\"\n\"fmt\"\n\"net/http\"\n\"net/url\"\n\"strconv\"\n)\n\n")
+	writeTypes(&b, doc.Components.Schemas)
+	writeOperations(&b, doc.Paths)
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		os.WriteFile(*outPath, b.Bytes(), 0o644)
+		log.Fatalf("format generated code: %v", err)
+	}
+	if err := os.WriteFile(*outPath, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func writeTypes(b *bytes.Buffer, schemas map[string]*schema) {
+	names := make([]string, 0, len(schemas))
+	for name := range schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := schemas[name]
+		switch {
+		case len(s.Enum) > 0:
+			fmt.Fprintf(b, "type %s string\n\nconst (\n", name)
+			for _, v := range s.Enum {
+				fmt.Fprintf(b, "%s%s %s = %q\n", name, exportedName(strings.ToLower(v)), name, v)
+			}
+			fmt.Fprintf(b, ")\n\n")
+		case s.Type == "object" && s.Properties != nil:
+			fmt.Fprintf(b, "type %s struct {\n", name)
+			writeFields(b, s)
+			fmt.Fprintf(b, "}\n\n")
+		default:
+			fmt.Fprintf(b, "type %s %s\n\n", name, goType(s))
+		}
+	}
+}
+
+func writeFields(b *bytes.Buffer, s *schema) {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return s.Properties[names[i]].Order < s.Properties[names[j]].Order })
+	required := make(map[string]bool)
+	for _, name := range s.Required {
+		required[name] = true
+	}
+	for _, name := range names {
+		p := s.Properties[name]
+		field := p.GoName
+		if field == "" {
+			field = exportedName(name)
+		}
+		typ, tag := goType(p), name
+		if !required[name] {
+			tag += ",omitempty"
+			// omitempty only drops optional structs behind a pointer
+			if c, ok := components[typ]; ok && c.Properties != nil {
+				typ = "*" + typ
+			}
+		}
+		fmt.Fprintf(b, "%s %s `json:%q`\n", field, typ, tag)
+	}
+}
+
+func goType(s *schema) string {
+	if s == nil {
+		return "any"
+	}
+	if s.Ref != "" {
+		return s.Ref[strings.LastIndex(s.Ref, "/")+1:]
+	}
+	if len(s.AllOf) == 1 {
+		return goType(s.AllOf[0])
+	}
+	switch s.Type {
+	case "string":
+		if s.Format == "byte" {
+			return "[]byte"
+		}
+		return "string"
+	case "boolean":
+		return "bool"
+	case "integer":
+		if s.Format == "int64" {
+			return "int64"
+		}
+		return "int"
+	case "number":
+		return "float64"
+	case "array":
+		return "[]" + goType(s.Items)
+	case "object":
+		if s.AdditionalProperties != nil {
+			key := "string"
+			if s.GoKeyType != "" {
+				key = s.GoKeyType
+			}
+			return "map[" + key + "]" + goType(s.AdditionalProperties)
+		}
+		if s.Properties == nil {
+			return "map[string]any"
+		}
+	}
+	return "any"
+}
+
+type op struct {
+	method, path string
+	*operation
+}
+
+func writeOperations(b *bytes.Buffer, paths map[string]map[string]*operation) {
+	var ops []op
+	for path, item := range paths {
+		for method, o := range item {
+			ops = append(ops, op{strings.ToUpper(method), path, o})
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].OperationID < ops[j].OperationID })
+	for _, o := range ops {
+		writeOperation(b, o)
+	}
+}
+
+func writeOperation(b *bytes.Buffer, o op) {
+	name := exportedName(o.OperationID)
+	var pathArgs []string
+	var query []parameter
+	for _, p := range o.Parameters {
+		if p.In == "path" {
+			pathArgs = append(pathArgs, p.Name)
+		} else if p.In == "query" {
+			query = append(query, p)
+		}
+	}
+
+	if len(query) > 0 {
+		fmt.Fprintf(b, "// %sParams are the query parameters of %s\ntype %sParams struct {\n", name, name, name)
+		for _, p := range query {
+			if p.Description != "" {
+				fmt.Fprintf(b, "// %s\n", p.Description)
+			}
+			fmt.Fprintf(b, "%s %s\n", exportedName(p.Name), goType(p.Schema))
+		}
+		fmt.Fprintf(b, "}\n\nfunc (p *%sParams) values() url.Values {\nq := url.Values{}\nif p == nil {\nreturn q\n}\n", name)
+		for _, p := range query {
+			field := exportedName(p.Name)
+			if goType(p.Schema) == "int" {
+				fmt.Fprintf(b, "if p.%s != 0 {\nq.Set(%q, strconv.Itoa(p.%s))\n}\n", field, p.Name, field)
+			} else {
+				fmt.Fprintf(b, "if p.%s != \"\" {\nq.Set(%q, p.%s)\n}\n", field, p.Name, field)
+			}
+		}
+		fmt.Fprintf(b, "return q\n}\n\n")
+	}
+
+	args := []string{"ctx context.Context"}
+	for _, a := range pathArgs {
+		args = append(args, lowerName(a)+" string")
+	}
+	bodyType := ""
+	if o.RequestBody != nil {
+		bodyType = goType(o.RequestBody.Content["application/json"].Schema)
+		args = append(args, "body "+bodyType)
+	}
+	if len(query) > 0 {
+		args = append(args, "params *"+name+"Params")
+	}
+
+	respType, raw := "", false
+	for status, r := range o.Responses {
+		if !strings.HasPrefix(status, "2") {
+			continue
+		}
+		if m, ok := r.Content["application/json"]; ok {
+			respType = goType(m.Schema)
+		} else if len(r.Content) > 0 {
+			raw = true
+		}
+	}
+
+	path := fmt.Sprintf("%q", o.path)
+	if len(pathArgs) > 0 {
+		format := o.path
+		var values []string
+		for _, a := range pathArgs {
+			format = strings.Replace(format, "{"+a+"}", "%s", 1)
+			values = append(values, "url.PathEscape("+lowerName(a)+")")
+		}
+		path = fmt.Sprintf("fmt.Sprintf(%q, %s)", format, strings.Join(values, ", "))
+	}
+	queryArg := "nil"
+	if len(query) > 0 {
+		queryArg = "params.values()"
+	}
+	bodyArg := "nil"
+	if bodyType != "" {
+		bodyArg = "body"
+	}
+
+	doc := o.Summary + "."
+	if o.Description != "" {
+		doc += " " + o.Description
+	}
+	fmt.Fprintf(b, "// %s calls %s %s: %s\n", name, o.method, o.path, doc)
+	switch {
+	case respType != "":
+		fmt.Fprintf(b, "func (c *Client) %s(%s) (*%s, error) {\nvar out %s\nif err := c.do(ctx, http.Method%s, %s, %s, %s, &out); err != nil {\nreturn nil, err\n}\nreturn &out, nil\n}\n\n",
+			name, strings.Join(args, ", "), respType, respType, methodConst(o.method), path, queryArg, bodyArg)
+	case raw:
+		fmt.Fprintf(b, "func (c *Client) %s(%s) ([]byte, error) {\nreturn c.doRaw(ctx, http.Method%s, %s, %s, %s)\n}\n\n",
+			name, strings.Join(args, ", "), methodConst(o.method), path, queryArg, bodyArg)
+	default:
+		fmt.Fprintf(b, "func (c *Client) %s(%s) error {\nreturn c.do(ctx, http.Method%s, %s, %s, %s, nil)\n}\n\n",
+			name, strings.Join(args, ", "), methodConst(o.method), path, queryArg, bodyArg)
+	}
+}
+
+func methodConst(method string) string {
+	return string(method[0]) + strings.ToLower(method[1:])
+}
+
+// initialisms are written in upper case in Go names
+var initialisms = map[string]bool{"id": true, "ids": true, "url": true, "vm": true, "vms": true, "ms": true, "ip": true, "sql": true}
+
+// exportedName turns check_id, getVMDetail or SUCCESS into CheckID,
+// GetVMDetail and Success
+func exportedName(s string) string {
+	var words []string
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		words = append(words, part)
+	}
+	var b strings.Builder
+	for _, w := range words {
+		switch lower := strings.ToLower(w); {
+		case lower == "ids" || lower == "vms":
+			b.WriteString(strings.ToUpper(lower[:len(lower)-1]) + "s")
+		case initialisms[lower]:
+			b.WriteString(strings.ToUpper(lower))
+		default:
+			b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+		}
+	}
+	return b.String()
+}
+
+func lowerName(s string) string {
+	name := exportedName(s)
+	if name == "ID" {
+		return "id"
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
@@ -0,0 +1,1269 @@
+// Code generated by go run ./gen; DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+type AuditEntry struct {
+	Time           string   `json:"time"`
+	Actor          string   `json:"actor"`
+	ActorKind      string   `json:"actor_kind,omitempty"`
+	ClientIP       string   `json:"client_ip,omitempty"`
+	Action         string   `json:"action"`
+	RunID          string   `json:"run_id,omitempty"`
+	Hosts          []string `json:"hosts,omitempty"`
+	Checks         []string `json:"checks,omitempty"`
+	CredentialRefs []string `json:"credential_refs,omitempty"`
+	Outcome        string   `json:"outcome"`
+	Detail         string   `json:"detail,omitempty"`
+}
+
+type AuditResponse struct {
+	Entries []AuditEntry `json:"entries"`
+	Total   int          `json:"total"`
+}
+
+type BatchResponse struct {
+	RunID           string                   `json:"run_id"`
+	Profile         string                   `json:"profile"`
+	ProfileVersion  string                   `json:"profile_version"`
+	Timestamp       string                   `json:"timestamp"`
+	Total           int                      `json:"total"`
+	Passed          int                      `json:"passed"`
+	Failed          int                      `json:"failed"`
+	VMResults       map[string][]CheckResult `json:"VMResults"`
+	InstanceResults map[string][]CheckResult `json:"InstanceResults"`
+	DatabaseResults map[string][]CheckResult `json:"DatabaseResults"`
+	Summary         SummaryStats             `json:"Summary"`
+}
+
+type CheckCategorySummary struct {
+	CategoryName string              `json:"category_name"`
+	Passed       int                 `json:"passed"`
+	Failed       int                 `json:"failed"`
+	Warnings     int                 `json:"warnings"`
+	Check        []CheckCountSummary `json:"check"`
+}
+
+type CheckCountSummary struct {
+	CheckName string `json:"check_name"`
+	Passed    int    `json:"passed"`
+	Failed    int    `json:"failed"`
+}
+
+type CheckDetail struct {
+	CheckID     string      `json:"CheckID,omitempty"`
+	Check       string      `json:"Check"`
+	Status      CheckStatus `json:"Status"`
+	Message     string      `json:"Message"`
+	Severity    Severity    `json:"Severity"`
+	Remediation string      `json:"Remediation,omitempty"`
+	Rule        *RuleInfo   `json:"rule,omitempty"`
+	CheckedAt   string      `json:"checked_at,omitempty"`
+}
+
+type CheckRequest struct {
+	Hostnames string `json:"hostnames"`
+	Profile   string `json:"profile"`
+}
+
+type CheckResult struct {
+	CheckID     string      `json:"CheckID,omitempty"`
+	Check       string      `json:"Check"`
+	Status      CheckStatus `json:"Status"`
+	Message     string      `json:"Message"`
+	Severity    Severity    `json:"Severity"`
+	Remediation string      `json:"Remediation,omitempty"`
+}
+
+type CheckStartedResponse struct {
+	Status string `json:"status"`
+	RunID  string `json:"run_id"`
+	Total  int    `json:"total"`
+}
+
+type CheckState struct {
+	CheckID     string      `json:"CheckID,omitempty"`
+	Check       string      `json:"Check"`
+	Status      CheckStatus `json:"Status"`
+	Message     string      `json:"Message"`
+	Severity    Severity    `json:"Severity"`
+	Remediation string      `json:"Remediation,omitempty"`
+	CheckedAt   string      `json:"checked_at"`
+	RunID       string      `json:"run_id"`
+}
+
+type CheckStatus string
+
+const (
+	CheckStatusSuccess CheckStatus = "SUCCESS"
+	CheckStatusInfo    CheckStatus = "INFO"
+	CheckStatusWarning CheckStatus = "WARNING"
+	CheckStatusFailed  CheckStatus = "FAILED"
+	CheckStatusError   CheckStatus = "ERROR"
+	CheckStatusWaived  CheckStatus = "WAIVED"
+)
+
+type CheckTypeSummary struct {
+	Type       string                 `json:"type"`
+	Categories []CheckCategorySummary `json:"categories"`
+}
+
+type CreateRemediationRequest struct {
+	Reason string                 `json:"reason"`
+	Checks []RemediationSelection `json:"checks"`
+}
+
+type CredentialInfo struct {
+	Name      string `json:"name"`
+	Username  string `json:"username"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type CredentialListResponse struct {
+	Credentials []CredentialInfo `json:"credentials"`
+}
+
+type CredentialRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type CredentialResponse struct {
+	Credential CredentialInfo `json:"credential"`
+}
+
+type DatabaseFacts struct {
+	Name  string         `json:"name"`
+	Facts map[string]any `json:"facts"`
+}
+
+type DatabaseListItem struct {
+	EntityName           string        `json:"entity_name"`
+	Type                 string        `json:"type"`
+	OverallFitmentStatus FitmentStatus `json:"overall_fitment_status"`
+	ParentInstance       string        `json:"parent_instance,omitempty"`
+}
+
+type DatabaseListResponse struct {
+	Databases  []DatabaseListItem `json:"databases"`
+	Total      int                `json:"total"`
+	NextCursor string             `json:"next_cursor"`
+}
+
+type DigestSentResponse struct {
+	Status     string `json:"status"`
+	Recipients int    `json:"recipients"`
+}
+
+type EntityCounts struct {
+	TotalVMs        int `json:"total_vms"`
+	FailedVMs       int `json:"failed_vms"`
+	TotalInstances  int `json:"total_instances"`
+	FailedInstances int `json:"failed_instances"`
+	TotalDatabases  int `json:"total_databases"`
+	FailedDatabases int `json:"failed_databases"`
+}
+
+type EntityDetail struct {
+	RunID          string        `json:"run_id"`
+	Profile        string        `json:"profile"`
+	ProfileVersion string        `json:"profile_version"`
+	Entity         EntityRef     `json:"entity"`
+	Status         string        `json:"status"`
+	Fitment        Fitment       `json:"fitment"`
+	Checks         []CheckDetail `json:"checks"`
+	Host           HostDetail    `json:"host"`
+	Instances      []string      `json:"instances,omitempty"`
+	Databases      []string      `json:"databases,omitempty"`
+	Instance       string        `json:"instance,omitempty"`
+}
+
+type EntityRef struct {
+	Key  string `json:"key"`
+	Type string `json:"type"`
+	Host string `json:"host"`
+}
+
+type EntityState struct {
+	Key           string       `json:"key"`
+	Type          string       `json:"type"`
+	Host          string       `json:"host"`
+	LastCheckedAt string       `json:"last_checked_at"`
+	LastRunID     string       `json:"last_run_id"`
+	Checks        []CheckState `json:"checks"`
+}
+
+type ErrorResponse struct {
+	Error      string   `json:"error"`
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+type Fitment struct {
+	Score      int              `json:"score"`
+	Readiness  string           `json:"readiness"`
+	BySeverity map[Severity]int `json:"by_severity"`
+}
+
+type FitmentStatus struct {
+	Status     string           `json:"status"`
+	Score      int              `json:"score"`
+	Readiness  string           `json:"readiness"`
+	BySeverity map[Severity]int `json:"by_severity"`
+}
+
+type GroupReadiness struct {
+	Group        string         `json:"group"`
+	VMs          int            `json:"vms"`
+	AverageScore int            `json:"average_score"`
+	Readiness    string         `json:"readiness"`
+	Tiers        map[string]int `json:"tiers"`
+}
+
+type HostDetail struct {
+	Executor   string     `json:"executor"`
+	StartedAt  string     `json:"started_at"`
+	FinishedAt string     `json:"finished_at"`
+	DurationMS int64      `json:"duration_ms"`
+	Error      string     `json:"error,omitempty"`
+	Facts      *HostFacts `json:"facts,omitempty"`
+	RawOutput  string     `json:"raw_output,omitempty"`
+}
+
+type HostFacts struct {
+	VM        map[string]any  `json:"vm"`
+	Instances []InstanceFacts `json:"instances"`
+}
+
+type HostRemediation struct {
+	Host   string `json:"host"`
+	Checks int    `json:"checks"`
+	Script string `json:"script"`
+}
+
+type InstanceFacts struct {
+	Name      string          `json:"name"`
+	Facts     map[string]any  `json:"facts"`
+	Databases []DatabaseFacts `json:"databases"`
+}
+
+type InstanceListItem struct {
+	EntityName           string             `json:"entity_name"`
+	Type                 string             `json:"type"`
+	OverallFitmentStatus FitmentStatus      `json:"overall_fitment_status"`
+	DatabasesCount       int                `json:"databases_count"`
+	Databases            []DatabaseListItem `json:"databases"`
+}
+
+type InstanceListResponse struct {
+	Instances  []InstanceListItem `json:"instances"`
+	Total      int                `json:"total"`
+	NextCursor string             `json:"next_cursor"`
+}
+
+type MeResponse struct {
+	Principal  Principal `json:"principal"`
+	Role       string    `json:"role"`
+	HostGroups []string  `json:"host_groups"`
+}
+
+type Principal struct {
+	Subject string   `json:"subject"`
+	Name    string   `json:"name"`
+	Kind    string   `json:"kind"`
+	Groups  []string `json:"groups,omitempty"`
+	Scopes  []string `json:"scopes,omitempty"`
+	Expires int64    `json:"exp,omitempty"`
+}
+
+type Profile struct {
+	Name           string                 `json:"name"`
+	Version        string                 `json:"version"`
+	NDBVersion     string                 `json:"ndb_version,omitempty"`
+	Workflow       string                 `json:"workflow,omitempty"`
+	Description    string                 `json:"description,omitempty"`
+	Thresholds     map[string]any         `json:"thresholds"`
+	Rules          []Rule                 `json:"rules"`
+	Checks         map[string]ProfileRule `json:"checks,omitempty"`
+	SeverityPolicy SeverityPolicy         `json:"severity_policy"`
+}
+
+type ProfileListResponse struct {
+	Profiles []Profile `json:"profiles"`
+	Default  string    `json:"default"`
+}
+
+type ProfileRule struct {
+	Enabled  bool     `json:"enabled,omitempty"`
+	Severity Severity `json:"severity,omitempty"`
+}
+
+type ProgressResponse struct {
+	Processed int `json:"processed"`
+	Total     int `json:"total"`
+}
+
+type ReadinessSummary struct {
+	VMs       map[string]int   `json:"vms"`
+	Instances map[string]int   `json:"instances"`
+	Databases map[string]int   `json:"databases"`
+	Groups    []GroupReadiness `json:"groups"`
+}
+
+type Remediation struct {
+	Description string `json:"description"`
+	Guard       string `json:"guard,omitempty"`
+	Script      string `json:"script,omitempty"`
+}
+
+type RemediationEvent struct {
+	Time   string `json:"time"`
+	Actor  string `json:"actor"`
+	Action string `json:"action"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type RemediationItem struct {
+	Host            string      `json:"host"`
+	Entity          string      `json:"entity"`
+	CheckID         string      `json:"check_id"`
+	Check           string      `json:"check"`
+	Status          CheckStatus `json:"status"`
+	VerifiedStatus  CheckStatus `json:"verified_status,omitempty"`
+	VerifiedMessage string      `json:"verified_message,omitempty"`
+}
+
+type RemediationRequest struct {
+	ID          string             `json:"id"`
+	RunID       string             `json:"run_id"`
+	Status      string             `json:"status"`
+	RequestedBy string             `json:"requested_by"`
+	Reason      string             `json:"reason,omitempty"`
+	ReviewedBy  string             `json:"reviewed_by,omitempty"`
+	Items       []RemediationItem  `json:"items"`
+	Output      map[string]string  `json:"output,omitempty"`
+	Events      []RemediationEvent `json:"events"`
+}
+
+type RemediationResponse struct {
+	Remediation RemediationRequest `json:"remediation"`
+}
+
+type RemediationScriptsResponse struct {
+	RunID        string            `json:"run_id"`
+	Profile      string            `json:"profile"`
+	Hosts        []HostRemediation `json:"hosts"`
+	OmittedHosts []string          `json:"omitted_hosts"`
+}
+
+type RemediationSelection struct {
+	Entity  string `json:"entity"`
+	CheckID string `json:"check_id"`
+}
+
+type RerunRequest struct {
+	Mode     string   `json:"mode"`
+	CheckIDs []string `json:"check_ids,omitempty"`
+}
+
+type RerunStartedResponse struct {
+	Status    string   `json:"status"`
+	RunID     string   `json:"run_id"`
+	ParentID  string   `json:"parent_id"`
+	Hostnames []string `json:"hostnames"`
+	Total     int      `json:"total"`
+}
+
+type ReviewRemediationRequest struct {
+	Comment string `json:"comment"`
+}
+
+type Rule struct {
+	ID          string       `json:"id"`
+	Check       string       `json:"check"`
+	Scope       string       `json:"scope"`
+	When        string       `json:"when,omitempty"`
+	Pass        string       `json:"pass"`
+	Message     string       `json:"message,omitempty"`
+	Severity    Severity     `json:"severity,omitempty"`
+	Remediation *Remediation `json:"remediation,omitempty"`
+}
+
+type RuleInfo struct {
+	ID          string   `json:"id"`
+	Scope       string   `json:"scope"`
+	When        string   `json:"when,omitempty"`
+	Pass        string   `json:"pass"`
+	Severity    Severity `json:"severity"`
+	Remediation string   `json:"remediation,omitempty"`
+	Automated   bool     `json:"automated"`
+}
+
+type Run struct {
+	ID             string               `json:"id"`
+	Status         string               `json:"status"`
+	StartedBy      string               `json:"started_by"`
+	Hostnames      []string             `json:"hostnames"`
+	StartedAt      string               `json:"started_at"`
+	FinishedAt     string               `json:"finished_at,omitempty"`
+	CancelledBy    string               `json:"cancelled_by,omitempty"`
+	Profile        string               `json:"profile"`
+	ProfileVersion string               `json:"profile_version"`
+	ParentID       string               `json:"parent_id,omitempty"`
+	RerunMode      string               `json:"rerun_mode,omitempty"`
+	CheckIDs       []string             `json:"check_ids,omitempty"`
+	Remediations   []RemediationRequest `json:"remediations,omitempty"`
+}
+
+type RunListResponse struct {
+	Runs []Run `json:"runs"`
+}
+
+type RunResponse struct {
+	Run Run `json:"run"`
+}
+
+type RunStateResponse struct {
+	RunID    string        `json:"run_id"`
+	ParentID string        `json:"parent_id,omitempty"`
+	State    BatchResponse `json:"state"`
+}
+
+type Severity string
+
+const (
+	SeverityCritical Severity = "CRITICAL"
+	SeverityHigh     Severity = "HIGH"
+	SeverityMedium   Severity = "MEDIUM"
+	SeverityLow      Severity = "LOW"
+	SeverityInfo     Severity = "INFO"
+)
+
+type SeverityPolicy map[Severity]CheckStatus
+
+type StateResponse struct {
+	Entities []EntityState `json:"entities"`
+	Total    int           `json:"total"`
+}
+
+type StatusResponse struct {
+	Status string `json:"status"`
+}
+
+type Summary struct {
+	EntityWise   EntityCounts                     `json:"entity_wise"`
+	Readiness    ReadinessSummary                 `json:"readiness"`
+	SeverityWise map[Severity]map[CheckStatus]int `json:"severity_wise"`
+	CheckWise    []CheckTypeSummary               `json:"check_wise"`
+}
+
+type SummaryResponse struct {
+	Summary Summary `json:"summary"`
+}
+
+type SummaryStats struct {
+	TotalServers   int `json:"TotalServers"`
+	TotalInstances int `json:"TotalInstances"`
+	TotalDatabases int `json:"TotalDatabases"`
+	TotalChecks    int `json:"TotalChecks"`
+	PassedChecks   int `json:"PassedChecks"`
+	FailedChecks   int `json:"FailedChecks"`
+	ErrorChecks    int `json:"ErrorChecks"`
+	WarningChecks  int `json:"WarningChecks"`
+	InfoChecks     int `json:"InfoChecks"`
+	WaivedChecks   int `json:"WaivedChecks"`
+}
+
+type TrendPoint struct {
+	Bucket       string         `json:"bucket"`
+	Runs         int            `json:"runs"`
+	Entities     int            `json:"entities"`
+	Passed       int            `json:"passed"`
+	Failed       int            `json:"failed"`
+	Errors       int            `json:"errors"`
+	Warnings     int            `json:"warnings"`
+	Waived       int            `json:"waived"`
+	AverageScore int            `json:"average_score"`
+	Readiness    map[string]int `json:"readiness"`
+}
+
+type TrendsResponse struct {
+	Bucket string       `json:"bucket"`
+	Level  string       `json:"level"`
+	Points []TrendPoint `json:"points"`
+}
+
+type VMListItem struct {
+	EntityName           string             `json:"entity_name"`
+	Type                 string             `json:"type"`
+	OverallFitmentStatus FitmentStatus      `json:"overall_fitment_status"`
+	InstancesCount       int                `json:"instances_count"`
+	DatabasesCount       int                `json:"databases_count"`
+	Instances            []InstanceListItem `json:"instances"`
+}
+
+type VMListResponse struct {
+	VMs        []VMListItem `json:"vms"`
+	Total      int          `json:"total"`
+	NextCursor string       `json:"next_cursor"`
+}
+
+type Waiver struct {
+	ID            string `json:"id"`
+	CheckID       string `json:"check_id"`
+	Host          string `json:"host"`
+	Instance      string `json:"instance,omitempty"`
+	Database      string `json:"database,omitempty"`
+	Justification string `json:"justification"`
+	ApprovedBy    string `json:"approved_by"`
+	CreatedAt     string `json:"created_at"`
+	ExpiresAt     string `json:"expires_at"`
+}
+
+type WaiverListResponse struct {
+	Waivers []WaiverStatus `json:"waivers"`
+}
+
+type WaiverRequest struct {
+	CheckID       string `json:"check_id"`
+	Host          string `json:"host"`
+	Instance      string `json:"instance"`
+	Database      string `json:"database"`
+	Justification string `json:"justification"`
+	ExpiresAt     string `json:"expires_at"`
+}
+
+type WaiverResponse struct {
+	Waiver Waiver `json:"waiver"`
+}
+
+type WaiverStatus struct {
+	Waiver Waiver `json:"waiver"`
+	Active bool   `json:"active"`
+}
+
+type WebhookConfig struct {
+	Name    string   `json:"name"`
+	URL     string   `json:"url"`
+	Events  []string `json:"events"`
+	Timeout int64    `json:"timeout"`
+}
+
+type WebhookDeliveriesResponse struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+}
+
+type WebhookDelivery struct {
+	ID             string `json:"id"`
+	Webhook        string `json:"webhook"`
+	Event          string `json:"event"`
+	RunID          string `json:"run_id,omitempty"`
+	Status         string `json:"status"`
+	Attempts       int    `json:"attempts"`
+	LastStatusCode int    `json:"last_status_code,omitempty"`
+	LastError      string `json:"last_error,omitempty"`
+	CreatedAt      string `json:"created_at"`
+	UpdatedAt      string `json:"updated_at"`
+}
+
+type WebhookDeliveryResponse struct {
+	Delivery WebhookDelivery `json:"delivery"`
+}
+
+type WebhookListResponse struct {
+	Webhooks []WebhookConfig `json:"webhooks"`
+}
+
+// ApproveRemediation calls POST /remediations/{id}/approve: Approve and apply a remediation request. Requires the admin role.
+func (c *Client) ApproveRemediation(ctx context.Context, id string, body ReviewRemediationRequest) (*RemediationResponse, error) {
+	var out RemediationResponse
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/remediations/%s/approve", url.PathEscape(id)), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CancelRun calls POST /runs/{id}/cancel: Cancel a running run. Requires the operator role.
+func (c *Client) CancelRun(ctx context.Context, id string) (*RunResponse, error) {
+	var out RunResponse
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/runs/%s/cancel", url.PathEscape(id)), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateWaiver calls POST /waivers: Waive a check until a date. Requires the admin role.
+func (c *Client) CreateWaiver(ctx context.Context, body WaiverRequest) (*WaiverResponse, error) {
+	var out WaiverResponse
+	if err := c.do(ctx, http.MethodPost, "/waivers", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteCredential calls DELETE /credentials/{name}: Delete a credential. Requires the admin role.
+func (c *Client) DeleteCredential(ctx context.Context, name string) (*StatusResponse, error) {
+	var out StatusResponse
+	if err := c.do(ctx, http.MethodDelete, fmt.Sprintf("/credentials/%s", url.PathEscape(name)), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteWaiver calls DELETE /waivers/{id}: Revoke a waiver. Requires the admin role.
+func (c *Client) DeleteWaiver(ctx context.Context, id string) (*StatusResponse, error) {
+	var out StatusResponse
+	if err := c.do(ctx, http.MethodDelete, fmt.Sprintf("/waivers/%s", url.PathEscape(id)), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ExportAuditParams are the query parameters of ExportAudit
+type ExportAuditParams struct {
+	Actor  string
+	Action string
+	RunID  string
+	Host   string
+	// RFC 3339
+	Since string
+	// RFC 3339
+	Until string
+}
+
+func (p *ExportAuditParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Actor != "" {
+		q.Set("actor", p.Actor)
+	}
+	if p.Action != "" {
+		q.Set("action", p.Action)
+	}
+	if p.RunID != "" {
+		q.Set("run_id", p.RunID)
+	}
+	if p.Host != "" {
+		q.Set("host", p.Host)
+	}
+	if p.Since != "" {
+		q.Set("since", p.Since)
+	}
+	if p.Until != "" {
+		q.Set("until", p.Until)
+	}
+	return q
+}
+
+// ExportAudit calls GET /audit/export: Audit entries as JSON lines, oldest first. Requires the admin role.
+func (c *Client) ExportAudit(ctx context.Context, params *ExportAuditParams) ([]byte, error) {
+	return c.doRaw(ctx, http.MethodGet, "/audit/export", params.values(), nil)
+}
+
+// GetConfig calls GET /config: The running configuration as YAML, secrets masked. Requires the admin role.
+func (c *Client) GetConfig(ctx context.Context) ([]byte, error) {
+	return c.doRaw(ctx, http.MethodGet, "/config", nil, nil)
+}
+
+// GetDatabaseDetail calls GET /runs/{id}/databases/{name}: Every check of a database in a run; name is host\instance\database or a unique database name. Requires the viewer role.
+func (c *Client) GetDatabaseDetail(ctx context.Context, id string, name string) (*EntityDetail, error) {
+	var out EntityDetail
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/runs/%s/databases/%s", url.PathEscape(id), url.PathEscape(name)), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetInstanceDetail calls GET /runs/{id}/instances/{name}: Every check of an instance in a run; name is host\instance or a unique instance name. Requires the viewer role.
+func (c *Client) GetInstanceDetail(ctx context.Context, id string, name string) (*EntityDetail, error) {
+	var out EntityDetail
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/runs/%s/instances/%s", url.PathEscape(id), url.PathEscape(name)), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetMe calls GET /me: Describe the caller.
+func (c *Client) GetMe(ctx context.Context) (*MeResponse, error) {
+	var out MeResponse
+	if err := c.do(ctx, http.MethodGet, "/me", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetProgress calls GET /progress: Progress of the latest run. Requires the viewer role.
+func (c *Client) GetProgress(ctx context.Context) (*ProgressResponse, error) {
+	var out ProgressResponse
+	if err := c.do(ctx, http.MethodGet, "/progress", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetRemediationScriptsParams are the query parameters of GetRemediationScripts
+type GetRemediationScriptsParams struct {
+	// Return this host's script as a .ps1 download
+	Host string
+}
+
+func (p *GetRemediationScriptsParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Host != "" {
+		q.Set("host", p.Host)
+	}
+	return q
+}
+
+// GetRemediationScripts calls GET /runs/{id}/remediation: Remediation scripts of a finished run; with host, that host's script as text. Requires the operator role.
+func (c *Client) GetRemediationScripts(ctx context.Context, id string, params *GetRemediationScriptsParams) (*RemediationScriptsResponse, error) {
+	var out RemediationScriptsResponse
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/runs/%s/remediation", url.PathEscape(id)), params.values(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetRun calls GET /runs/{id}: Get a run. Requires the viewer role.
+func (c *Client) GetRun(ctx context.Context, id string) (*RunResponse, error) {
+	var out RunResponse
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/runs/%s", url.PathEscape(id)), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetRunState calls GET /runs/{id}/state: Latest known state of a run's hosts; a rerun's results merged into its parent's. Requires the viewer role.
+func (c *Client) GetRunState(ctx context.Context, id string) (*RunStateResponse, error) {
+	var out RunStateResponse
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/runs/%s/state", url.PathEscape(id)), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSummary calls GET /summary: Summary of the latest results. Requires the viewer role.
+func (c *Client) GetSummary(ctx context.Context) (*SummaryResponse, error) {
+	var out SummaryResponse
+	if err := c.do(ctx, http.MethodGet, "/summary", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetTrendsParams are the query parameters of GetTrends
+type GetTrendsParams struct {
+	Group string
+	Tag   string
+	// Comma-separated check ids
+	CheckID string
+	// vm, instance or database
+	Level string
+	// day or week
+	Bucket string
+	// RFC 3339 or YYYY-MM-DD
+	Since string
+	// RFC 3339 or YYYY-MM-DD
+	Until string
+}
+
+func (p *GetTrendsParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Group != "" {
+		q.Set("group", p.Group)
+	}
+	if p.Tag != "" {
+		q.Set("tag", p.Tag)
+	}
+	if p.CheckID != "" {
+		q.Set("check_id", p.CheckID)
+	}
+	if p.Level != "" {
+		q.Set("level", p.Level)
+	}
+	if p.Bucket != "" {
+		q.Set("bucket", p.Bucket)
+	}
+	if p.Since != "" {
+		q.Set("since", p.Since)
+	}
+	if p.Until != "" {
+		q.Set("until", p.Until)
+	}
+	return q
+}
+
+// GetTrends calls GET /trends: Check counts and readiness over time. Requires the viewer role.
+func (c *Client) GetTrends(ctx context.Context, params *GetTrendsParams) (*TrendsResponse, error) {
+	var out TrendsResponse
+	if err := c.do(ctx, http.MethodGet, "/trends", params.values(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetVMDetail calls GET /runs/{id}/vms/{host}: Every check of a VM in a run, with its raw facts. Requires the viewer role.
+func (c *Client) GetVMDetail(ctx context.Context, id string, host string) (*EntityDetail, error) {
+	var out EntityDetail
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/runs/%s/vms/%s", url.PathEscape(id), url.PathEscape(host)), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListCredentials calls GET /credentials: List credentials without passwords. Requires the admin role.
+func (c *Client) ListCredentials(ctx context.Context) (*CredentialListResponse, error) {
+	var out CredentialListResponse
+	if err := c.do(ctx, http.MethodGet, "/credentials", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListDatabasesParams are the query parameters of ListDatabases
+type ListDatabasesParams struct {
+	// passed or failed
+	Status string
+	// Entities where this check did not pass
+	CheckID string
+	// Entities with a check of this severity that did not pass
+	Severity string
+	// Entities on hosts with this inventory tag
+	Tag string
+	// Substring of the entity key, case-insensitive
+	Q string
+	// name, score, status or readiness
+	Sort string
+	// asc or desc
+	Order string
+	// Page size, at most 500
+	Limit int
+	// next_cursor of the previous page
+	Cursor string
+}
+
+func (p *ListDatabasesParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Status != "" {
+		q.Set("status", p.Status)
+	}
+	if p.CheckID != "" {
+		q.Set("check_id", p.CheckID)
+	}
+	if p.Severity != "" {
+		q.Set("severity", p.Severity)
+	}
+	if p.Tag != "" {
+		q.Set("tag", p.Tag)
+	}
+	if p.Q != "" {
+		q.Set("q", p.Q)
+	}
+	if p.Sort != "" {
+		q.Set("sort", p.Sort)
+	}
+	if p.Order != "" {
+		q.Set("order", p.Order)
+	}
+	if p.Limit != 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		q.Set("cursor", p.Cursor)
+	}
+	return q
+}
+
+// ListDatabases calls GET /databases: Databases of the latest results. Requires the viewer role.
+func (c *Client) ListDatabases(ctx context.Context, params *ListDatabasesParams) (*DatabaseListResponse, error) {
+	var out DatabaseListResponse
+	if err := c.do(ctx, http.MethodGet, "/databases", params.values(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListInstancesParams are the query parameters of ListInstances
+type ListInstancesParams struct {
+	// passed or failed
+	Status string
+	// Entities where this check did not pass
+	CheckID string
+	// Entities with a check of this severity that did not pass
+	Severity string
+	// Entities on hosts with this inventory tag
+	Tag string
+	// Substring of the entity key, case-insensitive
+	Q string
+	// name, score, status or readiness
+	Sort string
+	// asc or desc
+	Order string
+	// Page size, at most 500
+	Limit int
+	// next_cursor of the previous page
+	Cursor string
+}
+
+func (p *ListInstancesParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Status != "" {
+		q.Set("status", p.Status)
+	}
+	if p.CheckID != "" {
+		q.Set("check_id", p.CheckID)
+	}
+	if p.Severity != "" {
+		q.Set("severity", p.Severity)
+	}
+	if p.Tag != "" {
+		q.Set("tag", p.Tag)
+	}
+	if p.Q != "" {
+		q.Set("q", p.Q)
+	}
+	if p.Sort != "" {
+		q.Set("sort", p.Sort)
+	}
+	if p.Order != "" {
+		q.Set("order", p.Order)
+	}
+	if p.Limit != 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		q.Set("cursor", p.Cursor)
+	}
+	return q
+}
+
+// ListInstances calls GET /instances: Instances of the latest results. Requires the viewer role.
+func (c *Client) ListInstances(ctx context.Context, params *ListInstancesParams) (*InstanceListResponse, error) {
+	var out InstanceListResponse
+	if err := c.do(ctx, http.MethodGet, "/instances", params.values(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListProfiles calls GET /profiles: List the loaded rule profiles. Requires the viewer role.
+func (c *Client) ListProfiles(ctx context.Context) (*ProfileListResponse, error) {
+	var out ProfileListResponse
+	if err := c.do(ctx, http.MethodGet, "/profiles", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListRuns calls GET /runs: List recent runs, newest first. Requires the viewer role.
+func (c *Client) ListRuns(ctx context.Context) (*RunListResponse, error) {
+	var out RunListResponse
+	if err := c.do(ctx, http.MethodGet, "/runs", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListVMsParams are the query parameters of ListVMs
+type ListVMsParams struct {
+	// passed or failed
+	Status string
+	// Entities where this check did not pass
+	CheckID string
+	// Entities with a check of this severity that did not pass
+	Severity string
+	// Entities on hosts with this inventory tag
+	Tag string
+	// Substring of the entity key, case-insensitive
+	Q string
+	// name, score, status or readiness
+	Sort string
+	// asc or desc
+	Order string
+	// Page size, at most 500
+	Limit int
+	// next_cursor of the previous page
+	Cursor string
+}
+
+func (p *ListVMsParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Status != "" {
+		q.Set("status", p.Status)
+	}
+	if p.CheckID != "" {
+		q.Set("check_id", p.CheckID)
+	}
+	if p.Severity != "" {
+		q.Set("severity", p.Severity)
+	}
+	if p.Tag != "" {
+		q.Set("tag", p.Tag)
+	}
+	if p.Q != "" {
+		q.Set("q", p.Q)
+	}
+	if p.Sort != "" {
+		q.Set("sort", p.Sort)
+	}
+	if p.Order != "" {
+		q.Set("order", p.Order)
+	}
+	if p.Limit != 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if p.Cursor != "" {
+		q.Set("cursor", p.Cursor)
+	}
+	return q
+}
+
+// ListVMs calls GET /dbservers: VMs of the latest results. Requires the viewer role.
+func (c *Client) ListVMs(ctx context.Context, params *ListVMsParams) (*VMListResponse, error) {
+	var out VMListResponse
+	if err := c.do(ctx, http.MethodGet, "/dbservers", params.values(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListWaivers calls GET /waivers: List waivers and whether each is active. Requires the viewer role.
+func (c *Client) ListWaivers(ctx context.Context) (*WaiverListResponse, error) {
+	var out WaiverListResponse
+	if err := c.do(ctx, http.MethodGet, "/waivers", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListWebhookDeliveriesParams are the query parameters of ListWebhookDeliveries
+type ListWebhookDeliveriesParams struct {
+	// Only this webhook's deliveries
+	Webhook string
+}
+
+func (p *ListWebhookDeliveriesParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Webhook != "" {
+		q.Set("webhook", p.Webhook)
+	}
+	return q
+}
+
+// ListWebhookDeliveries calls GET /webhooks/deliveries: Webhook delivery log, newest first. Requires the admin role.
+func (c *Client) ListWebhookDeliveries(ctx context.Context, params *ListWebhookDeliveriesParams) (*WebhookDeliveriesResponse, error) {
+	var out WebhookDeliveriesResponse
+	if err := c.do(ctx, http.MethodGet, "/webhooks/deliveries", params.values(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListWebhooks calls GET /webhooks: List the configured webhooks. Requires the admin role.
+func (c *Client) ListWebhooks(ctx context.Context) (*WebhookListResponse, error) {
+	var out WebhookListResponse
+	if err := c.do(ctx, http.MethodGet, "/webhooks", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PreviewDigest calls GET /digest/preview: The digest of the latest results as HTML. Requires the viewer role.
+func (c *Client) PreviewDigest(ctx context.Context) ([]byte, error) {
+	return c.doRaw(ctx, http.MethodGet, "/digest/preview", nil, nil)
+}
+
+// PutCredential calls PUT /credentials/{name}: Create or replace a credential. Requires the admin role.
+func (c *Client) PutCredential(ctx context.Context, name string, body CredentialRequest) (*CredentialResponse, error) {
+	var out CredentialResponse
+	if err := c.do(ctx, http.MethodPut, fmt.Sprintf("/credentials/%s", url.PathEscape(name)), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// QueryAuditParams are the query parameters of QueryAudit
+type QueryAuditParams struct {
+	Actor  string
+	Action string
+	RunID  string
+	Host   string
+	// RFC 3339
+	Since string
+	// RFC 3339
+	Until string
+	// At most this many entries; 100 if unset
+	Limit int
+}
+
+func (p *QueryAuditParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Actor != "" {
+		q.Set("actor", p.Actor)
+	}
+	if p.Action != "" {
+		q.Set("action", p.Action)
+	}
+	if p.RunID != "" {
+		q.Set("run_id", p.RunID)
+	}
+	if p.Host != "" {
+		q.Set("host", p.Host)
+	}
+	if p.Since != "" {
+		q.Set("since", p.Since)
+	}
+	if p.Until != "" {
+		q.Set("until", p.Until)
+	}
+	if p.Limit != 0 {
+		q.Set("limit", strconv.Itoa(p.Limit))
+	}
+	return q
+}
+
+// QueryAudit calls GET /audit: Audit entries, newest first. Requires the admin role.
+func (c *Client) QueryAudit(ctx context.Context, params *QueryAuditParams) (*AuditResponse, error) {
+	var out AuditResponse
+	if err := c.do(ctx, http.MethodGet, "/audit", params.values(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// QueryStateParams are the query parameters of QueryState
+type QueryStateParams struct {
+	// vm, instance or database
+	Type string
+	Host string
+	// passed or failed
+	Status string
+	// Not checked for at least this long, e.g. 7d
+	StaleFor string
+	// Checked at most this long ago, e.g. 12h
+	CheckedWithin string
+}
+
+func (p *QueryStateParams) values() url.Values {
+	q := url.Values{}
+	if p == nil {
+		return q
+	}
+	if p.Type != "" {
+		q.Set("type", p.Type)
+	}
+	if p.Host != "" {
+		q.Set("host", p.Host)
+	}
+	if p.Status != "" {
+		q.Set("status", p.Status)
+	}
+	if p.StaleFor != "" {
+		q.Set("stale_for", p.StaleFor)
+	}
+	if p.CheckedWithin != "" {
+		q.Set("checked_within", p.CheckedWithin)
+	}
+	return q
+}
+
+// QueryState calls GET /state: Latest known state of entities. Requires the viewer role.
+func (c *Client) QueryState(ctx context.Context, params *QueryStateParams) (*StateResponse, error) {
+	var out StateResponse
+	if err := c.do(ctx, http.MethodGet, "/state", params.values(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RejectRemediation calls POST /remediations/{id}/reject: Reject a remediation request. Requires the admin role.
+func (c *Client) RejectRemediation(ctx context.Context, id string, body ReviewRemediationRequest) (*RemediationResponse, error) {
+	var out RemediationResponse
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/remediations/%s/reject", url.PathEscape(id)), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReloadConfig calls POST /config/reload: Re-read the configuration file. Requires the admin role.
+func (c *Client) ReloadConfig(ctx context.Context) (*StatusResponse, error) {
+	var out StatusResponse
+	if err := c.do(ctx, http.MethodPost, "/config/reload", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RequestRemediation calls POST /runs/{id}/remediation/requests: Ask to apply the remediation of failed checks. Requires the operator role.
+func (c *Client) RequestRemediation(ctx context.Context, id string, body CreateRemediationRequest) (*RemediationResponse, error) {
+	var out RemediationResponse
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/runs/%s/remediation/requests", url.PathEscape(id)), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Rerun calls POST /runs/{id}/rerun: Re-check failed or errored hosts, or selected checks, of a finished run. Requires the operator role.
+func (c *Client) Rerun(ctx context.Context, id string, body RerunRequest) (*RerunStartedResponse, error) {
+	var out RerunStartedResponse
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/runs/%s/rerun", url.PathEscape(id)), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SendDigest calls POST /digest/send: Mail the digest of the latest results. Requires the admin role.
+func (c *Client) SendDigest(ctx context.Context) (*DigestSentResponse, error) {
+	var out DigestSentResponse
+	if err := c.do(ctx, http.MethodPost, "/digest/send", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// StartCheck calls POST /check: Start a run against hosts. Requires the operator role.
+func (c *Client) StartCheck(ctx context.Context, body CheckRequest) (*CheckStartedResponse, error) {
+	var out CheckStartedResponse
+	if err := c.do(ctx, http.MethodPost, "/check", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TestWebhook calls POST /webhooks/{name}/test: Send a ping event to a webhook. Requires the admin role.
+func (c *Client) TestWebhook(ctx context.Context, name string) (*WebhookDeliveryResponse, error) {
+	var out WebhookDeliveryResponse
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/webhooks/%s/test", url.PathEscape(name)), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
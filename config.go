@@ -0,0 +1,365 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultConfigPath = "./config.yaml"
+	ungroupedName     = "Ungrouped"
+)
+
+// Config holds the service settings loaded from the YAML config file
+type Config struct {
+	PublicURL    string            `yaml:"public_url"`
+	Auth         AuthConfig        `yaml:"auth"`
+	RBAC         RBACConfig        `yaml:"rbac"`
+	Audit        AuditConfig       `yaml:"audit"`
+	Credentials  CredentialsConfig `yaml:"credentials"`
+	Secrets      SecretsConfig     `yaml:"secrets"`
+	Executor     ExecutorConfig    `yaml:"executor"`
+	SQLChecks    SQLChecksConfig   `yaml:"sql_checks"`
+	Profiles     ProfilesConfig    `yaml:"profiles"`
+	Waivers      WaiversConfig     `yaml:"waivers"`
+	Remediation  RemediationConfig `yaml:"remediation"`
+	State        StateConfig       `yaml:"state"`
+	History      HistoryConfig     `yaml:"history"`
+	Shutdown     ShutdownConfig    `yaml:"shutdown"`
+	CORS         CORSConfig        `yaml:"cors"`
+	Inventory    Inventory         `yaml:"inventory"`
+	Webhooks     []WebhookConfig   `yaml:"webhooks"`
+	WebhookRetry RetryConfig       `yaml:"webhook_retry"`
+	SMTP         SMTPConfig        `yaml:"smtp"`
+
+	// profiles holds the rule profiles loaded from Profiles.Dir
+	profiles map[string]*Profile
+}
+
+// Inventory describes the known estate, grouped for reporting
+type Inventory struct {
+	Groups []HostGroup     `yaml:"groups"`
+	Hosts  []InventoryHost `yaml:"hosts"`
+}
+
+// HostGroup is a named set of hosts, e.g. a migration wave or environment
+type HostGroup struct {
+	Name  string   `yaml:"name" json:"name"`
+	Hosts []string `yaml:"hosts" json:"hosts"`
+	// Credential names the stored credential used for hosts in this group
+	Credential string `yaml:"credential" json:"credential,omitempty"`
+	// Tags label every host in the group, e.g. for filtering trends
+	Tags []string `yaml:"tags" json:"tags,omitempty"`
+}
+
+// InventoryHost holds per-host settings that override the host's group
+type InventoryHost struct {
+	Name       string `yaml:"name" json:"name"`
+	Credential string `yaml:"credential" json:"credential,omitempty"`
+	// Executor overrides executor.default for this host
+	Executor string `yaml:"executor" json:"executor,omitempty"`
+	// Tags add to the tags of the host's group
+	Tags []string `yaml:"tags" json:"tags,omitempty"`
+	// SQL enables Go-native instance and database checks over TDS
+	SQL *HostSQLConfig `yaml:"sql" json:"sql,omitempty"`
+}
+
+// RetryConfig controls how failed outbound deliveries are retried
+type RetryConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+}
+
+// ===== Globals =====
+
+// appConfig holds the running configuration. A reload replaces it whole and
+// never modifies it in place, so readers may keep the *Config they loaded.
+var appConfig atomic.Pointer[Config]
+
+func init() {
+	appConfig.Store(defaultConfig())
+}
+
+// currentConfig returns the running configuration
+func currentConfig() *Config {
+	return appConfig.Load()
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		WebhookRetry: RetryConfig{
+			MaxAttempts:    5,
+			InitialBackoff: 2 * time.Second,
+			MaxBackoff:     2 * time.Minute,
+		},
+		SMTP: SMTPConfig{
+			Port:     587,
+			StartTLS: true,
+		},
+	}
+}
+
+// configPath returns the config file location, overridable via NDB_PRECHECK_CONFIG
+func configPath() string {
+	if p := os.Getenv("NDB_PRECHECK_CONFIG"); p != "" {
+		return p
+	}
+	return defaultConfigPath
+}
+
+// loadConfig reads the YAML config at path on top of the defaults.
+// A missing file is not an error: the service runs with defaults.
+func loadConfig(path string) (*Config, error) {
+	cfg := defaultConfig()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		logrus.Infof("No config file at %s, using defaults", path)
+		if err := cfg.loadProfiles(); err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %v", path, err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %v", path, err)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %v", path, err)
+	}
+	if err := cfg.loadProfiles(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// groupForHost returns the first inventory group listing hostname
+func (c *Config) groupForHost(hostname string) string {
+	for _, g := range c.Inventory.Groups {
+		for _, h := range g.Hosts {
+			if strings.EqualFold(h, hostname) {
+				return g.Name
+			}
+		}
+	}
+	return ungroupedName
+}
+
+// tagsForHost returns the tags of hostname and of its group
+func (c *Config) tagsForHost(hostname string) []string {
+	var tags []string
+	group := c.groupForHost(hostname)
+	for _, g := range c.Inventory.Groups {
+		if g.Name == group {
+			tags = append(tags, g.Tags...)
+		}
+	}
+	if h := c.inventoryHost(hostname); h != nil {
+		tags = append(tags, h.Tags...)
+	}
+	return tags
+}
+
+// inventoryHost returns the per-host inventory entry for hostname, if any
+func (c *Config) inventoryHost(hostname string) *InventoryHost {
+	for i := range c.Inventory.Hosts {
+		if strings.EqualFold(c.Inventory.Hosts[i].Name, hostname) {
+			return &c.Inventory.Hosts[i]
+		}
+	}
+	return nil
+}
+
+// credentialRefForHost returns the credential name for hostname: the host's
+// own reference wins over its group's. Empty means the service identity.
+func (c *Config) credentialRefForHost(hostname string) string {
+	if h := c.inventoryHost(hostname); h != nil && h.Credential != "" {
+		return h.Credential
+	}
+	group := c.groupForHost(hostname)
+	for _, g := range c.Inventory.Groups {
+		if g.Name == group {
+			return g.Credential
+		}
+	}
+	return ""
+}
+
+func (c *Config) validate() error {
+	if err := c.Auth.validate(); err != nil {
+		return err
+	}
+	if err := c.RBAC.validate(); err != nil {
+		return err
+	}
+
+	groups := make(map[string]bool)
+	for _, g := range c.Inventory.Groups {
+		if g.Name == "" {
+			return fmt.Errorf("inventory groups need a name")
+		}
+		if groups[g.Name] {
+			return fmt.Errorf("duplicate inventory group %q", g.Name)
+		}
+		groups[g.Name] = true
+	}
+
+	if !isKnownExecutor(c.Executor.Default) {
+		return fmt.Errorf("unknown executor.default %q", c.Executor.Default)
+	}
+	for _, h := range c.Inventory.Hosts {
+		if !isKnownExecutor(h.Executor) {
+			return fmt.Errorf("inventory host %q has unknown executor %q", h.Name, h.Executor)
+		}
+	}
+	if err := c.Executor.WinRM.validate(); err != nil {
+		return err
+	}
+	if c.usesExecutor(ExecutorSSH) {
+		if err := c.Executor.SSH.validate(); err != nil {
+			return err
+		}
+	}
+	switch c.SQLChecks.Encrypt {
+	case "", "true", "false", "disable", "strict":
+	default:
+		return fmt.Errorf("sql_checks.encrypt must be true, false, strict or disable, got %q", c.SQLChecks.Encrypt)
+	}
+
+	names := make(map[string]bool)
+	for _, wh := range c.Webhooks {
+		if wh.Name == "" || wh.URL == "" {
+			return fmt.Errorf("webhooks need both name and url")
+		}
+		if names[wh.Name] {
+			return fmt.Errorf("duplicate webhook name %q", wh.Name)
+		}
+		names[wh.Name] = true
+		for _, ev := range wh.Events {
+			if !isKnownEvent(ev) {
+				return fmt.Errorf("webhook %q subscribes to unknown event %q", wh.Name, ev)
+			}
+		}
+	}
+	if c.WebhookRetry.MaxAttempts < 1 {
+		c.WebhookRetry.MaxAttempts = 1
+	}
+
+	if c.SMTP.DigestTime != "" {
+		if _, err := time.Parse("15:04", c.SMTP.DigestTime); err != nil {
+			return fmt.Errorf("smtp.digest_time must be HH:MM, got %q", c.SMTP.DigestTime)
+		}
+	}
+	if (c.SMTP.DigestTime != "" || c.SMTP.SendAfterRun) && (c.SMTP.Host == "" || len(c.SMTP.To) == 0) {
+		return fmt.Errorf("smtp digests need host and at least one recipient")
+	}
+	return nil
+}
+
+// redacted returns a copy of c with secrets masked, safe to show to admins
+func (c *Config) redacted() *Config {
+	const mask = "********"
+	out := *c
+
+	out.Auth.Tokens = make([]APIToken, len(c.Auth.Tokens))
+	for i, t := range c.Auth.Tokens {
+		t.Token, t.SHA256 = "", ""
+		out.Auth.Tokens[i] = t
+	}
+	if out.Auth.SessionSecret != "" {
+		out.Auth.SessionSecret = mask
+	}
+	if out.Auth.OIDC.ClientSecret != "" {
+		out.Auth.OIDC.ClientSecret = mask
+	}
+	if out.Secrets.Vault != nil {
+		vault := *out.Secrets.Vault
+		if vault.Token != "" {
+			vault.Token = mask
+		}
+		if vault.SecretID != "" {
+			vault.SecretID = mask
+		}
+		out.Secrets.Vault = &vault
+	}
+	if out.Executor.SSH.PrivateKeyPassphrase != "" {
+		out.Executor.SSH.PrivateKeyPassphrase = mask
+	}
+	if out.SMTP.Password != "" {
+		out.SMTP.Password = mask
+	}
+	out.Webhooks = make([]WebhookConfig, len(c.Webhooks))
+	for i, wh := range c.Webhooks {
+		if wh.Secret != "" {
+			wh.Secret = mask
+		}
+		out.Webhooks[i] = wh
+	}
+	return &out
+}
+
+// ===== API Handlers =====
+
+// handleGetConfig returns the running configuration with secrets masked
+func handleGetConfig(c *gin.Context) {
+	c.YAML(http.StatusOK, currentConfig().redacted())
+}
+
+// restartSettings lists the settings of cfg that differ from old but are only
+// read at startup: the stores they point at stay open until a restart
+func restartSettings(old, cfg *Config) []string {
+	var changed []string
+	for _, s := range []struct {
+		name     string
+		old, new string
+	}{
+		{"credentials.store_path", old.Credentials.StorePath, cfg.Credentials.StorePath},
+		{"credentials.master_key_path", old.Credentials.MasterKeyPath, cfg.Credentials.MasterKeyPath},
+		{"waivers.store_path", old.Waivers.StorePath, cfg.Waivers.StorePath},
+		{"state.store_path", old.State.StorePath, cfg.State.StorePath},
+	} {
+		if s.old != s.new {
+			changed = append(changed, s.name)
+		}
+	}
+	return changed
+}
+
+// handleReloadConfig re-reads the config file and applies it without a
+// restart. Changes to the store paths are refused; they need a restart.
+func handleReloadConfig(c *gin.Context) {
+	cfg, err := loadConfig(configPath())
+	if err == nil {
+		if changed := restartSettings(currentConfig(), cfg); len(changed) > 0 {
+			err = fmt.Errorf("%s cannot change without a restart", strings.Join(changed, ", "))
+		}
+	}
+	if err != nil {
+		auditRequest(c, AuditEntry{Action: AuditConfigReload, Outcome: AuditOutcomeFailure, Detail: err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	appConfig.Store(cfg)
+	initAuth()
+	startDigestScheduler()
+	if err := initSecretProviders(); err != nil {
+		logrus.Errorf("Failed to configure secret backends after reload: %v", err)
+	}
+	auditRequest(c, AuditEntry{Action: AuditConfigReload, Outcome: AuditOutcomeSuccess, Detail: configPath()})
+
+	logrus.Infof("Configuration reloaded from %s by %s", configPath(), currentPrincipal(c).Subject)
+	c.JSON(http.StatusOK, StatusResponse{Status: "reloaded"})
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestConfigRedactedMasksSecrets(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.Auth.SessionSecret = "session"
+	cfg.Auth.OIDC.ClientSecret = "oidc"
+	cfg.Auth.Tokens = []APIToken{{Name: "ci", Token: "token"}}
+	cfg.Secrets.Vault = &VaultConfig{Token: "vault", SecretID: "approle"}
+	cfg.Executor.SSH.PrivateKeyPassphrase = "passphrase"
+	cfg.SMTP.Password = "smtp"
+	cfg.Webhooks = []WebhookConfig{{Name: "hook", Secret: "hook"}}
+
+	out := cfg.redacted()
+	for name, got := range map[string]string{
+		"auth.session_secret":                 out.Auth.SessionSecret,
+		"auth.oidc.client_secret":             out.Auth.OIDC.ClientSecret,
+		"auth.tokens[0].token":                out.Auth.Tokens[0].Token,
+		"secrets.vault.token":                 out.Secrets.Vault.Token,
+		"secrets.vault.secret_id":             out.Secrets.Vault.SecretID,
+		"executor.ssh.private_key_passphrase": out.Executor.SSH.PrivateKeyPassphrase,
+		"smtp.password":                       out.SMTP.Password,
+		"webhooks[0].secret":                  out.Webhooks[0].Secret,
+	} {
+		if got != "" && got != "********" {
+			t.Errorf("%s = %q, want it masked", name, got)
+		}
+	}
+	if cfg.Executor.SSH.PrivateKeyPassphrase != "passphrase" || cfg.Secrets.Vault.Token != "vault" {
+		t.Error("redacted modified the running config")
+	}
+}
+
+func TestCORSFollowsReload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(corsMiddleware())
+	router.GET("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+	allowed := func(origin string) string {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Header().Get("Access-Control-Allow-Origin")
+	}
+
+	withConfig(t, &Config{})
+	if got := allowed("https://ui.example.com"); got != "" {
+		t.Errorf("no origins configured: allowed %q", got)
+	}
+	withConfig(t, &Config{CORS: CORSConfig{AllowedOrigins: []string{"https://ui.example.com"}}})
+	if got := allowed("https://ui.example.com"); got != "https://ui.example.com" {
+		t.Errorf("after reload: allowed %q, want the new origin", got)
+	}
+	if got := allowed("https://other.example.com"); got != "" {
+		t.Errorf("unlisted origin allowed: %q", got)
+	}
+}
+
+// fakeIssuer serves just enough OpenID discovery for oidc.NewProvider
+func fakeIssuer(t *testing.T) *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                 srv.URL,
+			"authorization_endpoint": srv.URL + "/authorize",
+			"token_endpoint":         srv.URL + "/token",
+			"jwks_uri":               srv.URL + "/keys",
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOIDCProviderFollowsReload(t *testing.T) {
+	t.Cleanup(func() {
+		oidcMu.Lock()
+		oidcProvider, oidcIssuer = nil, ""
+		oidcMu.Unlock()
+	})
+	first, second := fakeIssuer(t), fakeIssuer(t)
+
+	withConfig(t, &Config{Auth: AuthConfig{OIDC: OIDCConfig{Issuer: first.URL}}})
+	p, err := getOIDCProvider(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := getOIDCProvider(context.Background()); cached != p {
+		t.Error("provider rediscovered without a change")
+	}
+
+	withConfig(t, &Config{Auth: AuthConfig{OIDC: OIDCConfig{Issuer: second.URL}}})
+	p, err = getOIDCProvider(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Endpoint().AuthURL; got != second.URL+"/authorize" {
+		t.Errorf("auth URL = %s, want the reloaded issuer's", got)
+	}
+}
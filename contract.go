@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// contractPath is the frozen OpenAPI document of /api/v1, relative to the
+// repository root. The Go client is generated from it.
+const contractPath = "contracts/" + apiVersion + ".openapi.json"
+
+// contractChange is one difference between the frozen contract and the API
+type contractChange struct {
+	// Breaking changes can fail existing callers: removed operations,
+	// fields or enum values, changed types, fields that became required
+	Breaking bool
+	Detail   string
+}
+
+func (c contractChange) String() string {
+	if c.Breaking {
+		return "BREAKING: " + c.Detail
+	}
+	return "changed: " + c.Detail
+}
+
+// schemaShape summarises the type of a schema, e.g. []Run or map[Severity]int
+func schemaShape(v any) string {
+	s, _ := v.(map[string]any)
+	if ref, ok := s["$ref"].(string); ok {
+		return ref[strings.LastIndex(ref, "/")+1:]
+	}
+	if all, ok := s["allOf"].([]any); ok && len(all) == 1 {
+		return schemaShape(all[0])
+	}
+	switch s["type"] {
+	case "array":
+		return "[]" + schemaShape(s["items"])
+	case "object":
+		if add, ok := s["additionalProperties"]; ok {
+			key, _ := s["x-go-key-type"].(string)
+			return "map[" + key + "]" + schemaShape(add)
+		}
+		return "object"
+	case nil:
+		return "any"
+	}
+	if format, ok := s["format"].(string); ok {
+		return fmt.Sprint(s["type"], ":", format)
+	}
+	return fmt.Sprint(s["type"])
+}
+
+// jsonStrings returns the string items of a JSON array
+func jsonStrings(v any) map[string]bool {
+	set := make(map[string]bool)
+	list, _ := v.([]any)
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			set[s] = true
+		}
+	}
+	return set
+}
+
+func jsonObject(v any, keys ...string) map[string]any {
+	for _, k := range keys {
+		m, _ := v.(map[string]any)
+		v = m[k]
+	}
+	m, _ := v.(map[string]any)
+	return m
+}
+
+func sortedJSONKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// diffContract lists the shape changes from frozen to current
+func diffContract(frozen, current map[string]any) []contractChange {
+	var changes []contractChange
+	add := func(breaking bool, format string, args ...any) {
+		changes = append(changes, contractChange{Breaking: breaking, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	oldPaths, newPaths := jsonObject(frozen, "paths"), jsonObject(current, "paths")
+	for _, path := range sortedJSONKeys(oldPaths) {
+		for _, method := range sortedJSONKeys(jsonObject(oldPaths, path)) {
+			op := strings.ToUpper(method) + " " + path
+			was, now := jsonObject(oldPaths, path, method), jsonObject(newPaths, path, method)
+			if now == nil {
+				add(true, "%s removed", op)
+				continue
+			}
+			if a, b := schemaShape(jsonObject(was, "requestBody", "content", "application/json", "schema")),
+				schemaShape(jsonObject(now, "requestBody", "content", "application/json", "schema")); a != b {
+				add(true, "%s request body %s is now %s", op, a, b)
+			}
+			for _, status := range sortedJSONKeys(jsonObject(was, "responses")) {
+				for _, mt := range sortedJSONKeys(jsonObject(was, "responses", status, "content")) {
+					nowContent := jsonObject(now, "responses", status, "content", mt)
+					if nowContent == nil {
+						add(true, "%s no longer returns %s %s", op, status, mt)
+						continue
+					}
+					if a, b := schemaShape(jsonObject(was, "responses", status, "content", mt, "schema")),
+						schemaShape(nowContent["schema"]); a != b {
+						add(true, "%s %s response %s is now %s", op, status, a, b)
+					}
+				}
+			}
+			newParams := make(map[string]bool)
+			params, _ := now["parameters"].([]any)
+			for _, p := range params {
+				newParams[fmt.Sprint(jsonObject(p)["in"], ":", jsonObject(p)["name"])] = true
+			}
+			oldParams, _ := was["parameters"].([]any)
+			for _, p := range oldParams {
+				if key := fmt.Sprint(jsonObject(p)["in"], ":", jsonObject(p)["name"]); !newParams[key] {
+					add(true, "%s parameter %s removed", op, key)
+				}
+			}
+		}
+	}
+	for _, path := range sortedJSONKeys(newPaths) {
+		for _, method := range sortedJSONKeys(jsonObject(newPaths, path)) {
+			if jsonObject(oldPaths, path, method) == nil {
+				add(false, "%s %s added", strings.ToUpper(method), path)
+			}
+		}
+	}
+
+	oldSchemas, newSchemas := jsonObject(frozen, "components", "schemas"), jsonObject(current, "components", "schemas")
+	for _, name := range sortedJSONKeys(oldSchemas) {
+		was, now := jsonObject(oldSchemas, name), jsonObject(newSchemas, name)
+		if now == nil {
+			add(true, "schema %s removed", name)
+			continue
+		}
+		if a, b := schemaShape(was), schemaShape(now); a != b {
+			add(true, "schema %s %s is now %s", name, a, b)
+		}
+		oldEnum, newEnum := jsonStrings(was["enum"]), jsonStrings(now["enum"])
+		for _, v := range sortedKeysOf(oldEnum) {
+			if !newEnum[v] {
+				add(true, "%s value %s removed", name, v)
+			}
+		}
+		for _, v := range sortedKeysOf(newEnum) {
+			if !oldEnum[v] {
+				add(false, "%s value %s added", name, v)
+			}
+		}
+
+		oldProps, newProps := jsonObject(was, "properties"), jsonObject(now, "properties")
+		oldRequired, newRequired := jsonStrings(was["required"]), jsonStrings(now["required"])
+		for _, prop := range sortedJSONKeys(oldProps) {
+			field := name + "." + prop
+			if _, ok := newProps[prop]; !ok {
+				add(true, "%s removed", field)
+				continue
+			}
+			if a, b := schemaShape(oldProps[prop]), schemaShape(newProps[prop]); a != b {
+				add(true, "%s %s is now %s", field, a, b)
+			}
+			switch {
+			case oldRequired[prop] && !newRequired[prop]:
+				add(true, "%s is no longer always present", field)
+			case !oldRequired[prop] && newRequired[prop]:
+				add(true, "%s is now required", field)
+			}
+		}
+		for _, prop := range sortedJSONKeys(newProps) {
+			if _, ok := oldProps[prop]; !ok {
+				add(false, "%s.%s added", name, prop)
+			}
+		}
+	}
+	for _, name := range sortedJSONKeys(newSchemas) {
+		if _, ok := oldSchemas[name]; !ok {
+			add(false, "schema %s added", name)
+		}
+	}
+	return changes
+}
+
+func sortedKeysOf(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkContract compares the API with the frozen contract. Any change fails
+// the check until the contract is updated; -update records additive changes
+// and refuses breaking ones, which belong in a new API version.
+//
+//	ndb-precheck contract [-update] [-file contracts/v1.openapi.json]
+func checkContract(args []string) int {
+	flags := flag.NewFlagSet("contract", flag.ContinueOnError)
+	update := flags.Bool("update", false, "record additive changes in the contract")
+	path := flags.String("file", contractPath, "frozen OpenAPI document")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	current := openAPIDocument()
+	frozenData, err := os.ReadFile(*path)
+	if os.IsNotExist(err) && *update {
+		frozenData, err = []byte("{}"), nil
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "contract: %v\n", err)
+		return 1
+	}
+	if bytes.Equal(frozenData, current) {
+		fmt.Printf("contract: API matches %s\n", *path)
+		return 0
+	}
+
+	var frozenDoc, currentDoc map[string]any
+	if err := json.Unmarshal(frozenData, &frozenDoc); err != nil {
+		fmt.Fprintf(os.Stderr, "contract: parse %s: %v\n", *path, err)
+		return 1
+	}
+	json.Unmarshal(current, &currentDoc)
+
+	changes := diffContract(frozenDoc, currentDoc)
+	breaking := false
+	for _, c := range changes {
+		fmt.Println(c)
+		breaking = breaking || c.Breaking
+	}
+	if len(changes) == 0 {
+		fmt.Println("changed: descriptions only")
+	}
+
+	switch {
+	case breaking && *update:
+		fmt.Fprintf(os.Stderr, "contract: refusing to record breaking changes in %s; add a new API version instead\n", *path)
+		return 1
+	case *update:
+		if err := os.MkdirAll(filepath.Dir(*path), 0o755); err == nil {
+			err = os.WriteFile(*path, current, 0o644)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "contract: %v\n", err)
+			return 1
+		}
+		fmt.Printf("contract: updated %s\n", *path)
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "contract: API differs from %s; run ndb-precheck contract -update if the change is intended\n", *path)
+	return 1
+}
@@ -0,0 +1,469 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func readContract(t *testing.T) map[string]any {
+	t.Helper()
+	var doc map[string]any
+	if err := json.Unmarshal(mustRead(t, contractPath), &doc); err != nil {
+		t.Fatalf("parse %s: %v", contractPath, err)
+	}
+	return doc
+}
+
+func TestContractFrozen(t *testing.T) {
+	frozen := readContract(t)
+	var current map[string]any
+	if err := json.Unmarshal(openAPIDocument(), &current); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range diffContract(frozen, current) {
+		t.Error(c)
+	}
+	if !bytes.Equal(mustRead(t, contractPath), openAPIDocument()) && !t.Failed() {
+		t.Errorf("%s is out of date; run ndb-precheck contract -update", contractPath)
+	}
+}
+
+// schemaErrors lists where v, decoded JSON, does not match schema. $refs
+// resolve in doc. null matches any schema: the API encodes nil slices, maps
+// and pointers as null. Properties the schema does not list are errors, so
+// undocumented fields are caught.
+func schemaErrors(doc, schema map[string]any, v any, at string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := ref[strings.LastIndex(ref, "/")+1:]
+		target := jsonObject(doc, "components", "schemas", name)
+		if target == nil {
+			return []string{fmt.Sprintf("%s: unknown schema %s", at, ref)}
+		}
+		return schemaErrors(doc, target, v, at)
+	}
+	if all, ok := schema["allOf"].([]any); ok {
+		var errs []string
+		for _, s := range all {
+			errs = append(errs, schemaErrors(doc, jsonObject(s), v, at)...)
+		}
+		return errs
+	}
+	if v == nil {
+		return nil
+	}
+
+	mismatch := func() []string {
+		return []string{fmt.Sprintf("%s: %T %v is not %s", at, v, v, schemaShape(schema))}
+	}
+	switch schema["type"] {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return mismatch()
+		}
+		var errs []string
+		if add, ok := schema["additionalProperties"]; ok {
+			for _, k := range sortedJSONKeys(obj) {
+				errs = append(errs, schemaErrors(doc, jsonObject(add), obj[k], at+"."+k)...)
+			}
+			return errs
+		}
+		props := jsonObject(schema, "properties")
+		for _, k := range sortedJSONKeys(obj) {
+			prop, ok := props[k]
+			if !ok {
+				errs = append(errs, fmt.Sprintf("%s.%s: not in the contract", at, k))
+				continue
+			}
+			errs = append(errs, schemaErrors(doc, jsonObject(prop), obj[k], at+"."+k)...)
+		}
+		for req := range jsonStrings(schema["required"]) {
+			if _, ok := obj[req]; !ok {
+				errs = append(errs, fmt.Sprintf("%s.%s: required but missing", at, req))
+			}
+		}
+		sort.Strings(errs)
+		return errs
+	case "array":
+		list, ok := v.([]any)
+		if !ok {
+			return mismatch()
+		}
+		var errs []string
+		for i, item := range list {
+			errs = append(errs, schemaErrors(doc, jsonObject(schema, "items"), item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+		return errs
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return mismatch()
+		}
+		if enum := jsonStrings(schema["enum"]); len(enum) > 0 && !enum[s] {
+			return []string{fmt.Sprintf("%s: %q is not one of %v", at, s, sortedKeysOf(enum))}
+		}
+	case "integer":
+		if n, ok := v.(float64); !ok || n != math.Trunc(n) {
+			return mismatch()
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			return mismatch()
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return mismatch()
+		}
+	}
+	return nil
+}
+
+func TestSchemaErrors(t *testing.T) {
+	doc := map[string]any{"components": map[string]any{"schemas": map[string]any{
+		"Item": map[string]any{"type": "object", "required": []any{"id"}, "properties": map[string]any{
+			"id":     map[string]any{"type": "integer"},
+			"status": map[string]any{"type": "string", "enum": []any{"ok", "bad"}},
+			"tags":   map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+			"counts": map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "number"}},
+		}},
+	}}}
+	item := map[string]any{"$ref": "#/components/schemas/Item"}
+	tests := []struct {
+		body string
+		errs []string
+	}{
+		{`{"id": 1, "status": "ok", "tags": ["a"], "counts": {"x": 1.5}}`, nil},
+		{`{"id": 1, "tags": null, "counts": null}`, nil},
+		{`{"id": 1.5}`, []string{"$.id: float64 1.5 is not integer"}},
+		{`{"status": "meh"}`, []string{`$.id: required but missing`, `$.status: "meh" is not one of [bad ok]`}},
+		{`{"id": 1, "extra": true}`, []string{"$.extra: not in the contract"}},
+		{`{"id": 1, "tags": [1], "counts": {"x": "1"}}`, []string{"$.counts.x: string 1 is not number", "$.tags[0]: float64 1 is not string"}},
+		{`[]`, []string{"$: []interface {} [] is not object"}},
+	}
+	for _, tt := range tests {
+		var v any
+		json.Unmarshal([]byte(tt.body), &v)
+		errs := schemaErrors(doc, item, v, "$")
+		if strings.Join(errs, "\n") != strings.Join(tt.errs, "\n") {
+			t.Errorf("%s: errors = %q, want %q", tt.body, errs, tt.errs)
+		}
+	}
+}
+
+// smtpSink accepts every message and keeps the last one
+type smtpSink struct {
+	addr     *net.TCPAddr
+	messages chan string
+}
+
+func startSMTPSink(t *testing.T) *smtpSink {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	s := &smtpSink{addr: l.Addr().(*net.TCPAddr), messages: make(chan string, 10)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(textproto.NewConn(conn))
+		}
+	}()
+	return s
+}
+
+func (s *smtpSink) serve(conn *textproto.Conn) {
+	defer conn.Close()
+	conn.PrintfLine("220 sink ready")
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+		switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+		case "EHLO", "HELO":
+			conn.PrintfLine("250 sink")
+		case "DATA":
+			conn.PrintfLine("354 go ahead")
+			msg, _ := conn.ReadDotBytes()
+			s.messages <- string(msg)
+			conn.PrintfLine("250 queued")
+		case "QUIT":
+			conn.PrintfLine("221 bye")
+			return
+		default:
+			conn.PrintfLine("250 ok")
+		}
+	}
+}
+
+// seedRun records a finished run of sql01 through the same evaluation,
+// history and state paths as a real run, and makes it the latest results
+func seedRun(t *testing.T) *Run {
+	t.Helper()
+	profile, ok := currentConfig().profile("")
+	if !ok {
+		t.Fatal("no default profile")
+	}
+	result := &ComprehensiveResult{Success: true, Target: "sql01", Facts: &HostFacts{
+		VM: map[string]any{"execution_policy": "RemoteSigned", "memory_gb": 16, "disks": disks(50, 5)},
+		Instances: []InstanceFacts{
+			instanceFacts(defaultSQLInstance, "15.0.2000.5", "Developer Edition", "RTM", []DatabaseFacts{
+				sqlDatabaseRow{Name: "Sales", State: "ONLINE", RecoveryModel: "SIMPLE", DataMB: 120, LogMB: 8}.facts(),
+				sqlDatabaseRow{Name: "Scratch", State: "ONLINE", RecoveryModel: "FULL", DataMB: 1, LogMB: 1}.facts(),
+			}),
+		},
+	}}
+	profile.evaluate(result, nil)
+
+	r := &BatchResponse{
+		RunID:           newID(),
+		Profile:         profile.Name,
+		ProfileVersion:  profile.Version,
+		Timestamp:       time.Now().Format("2006-01-02 15:04:05"),
+		VMResults:       make(map[string][]CheckResult),
+		InstanceResults: make(map[string][]CheckResult),
+		DatabaseResults: make(map[string][]CheckResult),
+		HostDetails:     make(map[string]*HostDetail),
+	}
+	var total, passed, failed, errored int
+	processResults("sql01", result, r, &total, &passed, &failed, &errored)
+	summarize(r)
+	r.HostDetails["sql01"] = newHostDetail("sql01", time.Now().Add(-time.Second), result, `{"Success":true}`, nil, nil)
+
+	run := startRun(r, []string{"sql01"}, "seed", profile, nil)
+	finishRun(run)
+	recordHistory(run.snapshot(), r)
+	recordState(r)
+	recordFinished(r, nil, false)
+	setLatestResults(r)
+	return run
+}
+
+// contractStep is one request of TestContractResponses
+type contractStep struct {
+	id     string // operationId
+	token  string
+	path   func() string
+	body   string
+	status int
+	// then reads the decoded response body, e.g. to pick up created ids
+	then func(body map[string]any)
+}
+
+// TestContractResponses calls every endpoint through the router on a seeded
+// run and checks each response against the frozen contract
+func TestContractResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+	t.Cleanup(hook.Close)
+	sink := startSMTPSink(t)
+
+	configFile := filepath.Join(dir, "config.yaml")
+	configYAML := fmt.Sprintf(`auth:
+  tokens:
+    - {name: admin, token: admin-token, scopes: [admin]}
+    - {name: operator, token: operator-token, scopes: [operator, "group:*"]}
+audit: {path: %[1]s/audit.jsonl}
+history: {path: %[1]s/history.jsonl}
+credentials: {store_path: %[1]s/credentials.enc, master_key_path: %[1]s/master.key}
+waivers: {store_path: %[1]s/waivers.json}
+state: {store_path: %[1]s/state.json}
+remediation: {enabled: true}
+webhooks:
+  - {name: hook, url: %[2]s}
+smtp: {host: 127.0.0.1, port: %[3]d, starttls: false, from: precheck@example.com, to: [dba@example.com]}
+`, dir, hook.URL, sink.addr.Port)
+	if err := os.WriteFile(configFile, []byte(configYAML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NDB_PRECHECK_CONFIG", configFile)
+	cfg, err := loadConfig(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withConfig(t, cfg)
+	initAuth()
+
+	previousResults := latestResults()
+	finished, finishedBefore := latestFinished()
+	t.Cleanup(func() {
+		setLatestResults(previousResults)
+		recordFinished(finished, finishedBefore, false)
+	})
+	credentialStore, err = openCredentialStore(cfg.Credentials.StorePath, cfg.Credentials.MasterKeyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if waiverStore, err = openWaiverStore(cfg.Waivers.StorePath); err != nil {
+		t.Fatal(err)
+	}
+	if stateStore, err = openStateStore(cfg.State.StorePath); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { credentialStore, waiverStore, stateStore = nil, nil, nil })
+
+	run := seedRun(t)
+	running := startRun(&BatchResponse{RunID: newID(), HostDetails: map[string]*HostDetail{}}, []string{"sql02"}, "seed", builtinProfile(), nil)
+	t.Cleanup(func() { finishRun(running) })
+
+	router := gin.New()
+	registerAPI(router)
+	frozen := readContract(t)
+
+	var remediationID, waiverID string
+	at := func(format string, args ...any) func() string {
+		return func() string { return fmt.Sprintf(format, args...) }
+	}
+	runPath := func(suffix string) func() string { return at("/runs/%s%s", run.ID, suffix) }
+	remediation := `{"reason": "contract test", "checks": [{"entity": "sql01\\MSSQLSERVER\\Sales", "check_id": "database.recovery_model"}]}`
+	keepRemediation := func(body map[string]any) { remediationID, _ = jsonObject(body, "remediation")["id"].(string) }
+
+	steps := []contractStep{
+		{id: "getMe", path: at("/me"), status: http.StatusOK},
+		{id: "getProgress", path: at("/progress"), status: http.StatusOK},
+		{id: "listRuns", path: at("/runs"), status: http.StatusOK},
+		{id: "getRun", path: runPath(""), status: http.StatusOK},
+		{id: "getRunState", path: runPath("/state"), status: http.StatusOK},
+		{id: "getVMDetail", path: runPath("/vms/sql01"), status: http.StatusOK},
+		{id: "getInstanceDetail", path: runPath("/instances/MSSQLSERVER"), status: http.StatusOK},
+		{id: "getDatabaseDetail", path: runPath("/databases/Sales"), status: http.StatusOK},
+		{id: "getRemediationScripts", path: runPath("/remediation"), status: http.StatusOK},
+		{id: "requestRemediation", token: "operator-token", path: runPath("/remediation/requests"), body: remediation,
+			status: http.StatusCreated, then: keepRemediation},
+		{id: "rejectRemediation", path: func() string { return "/remediations/" + remediationID + "/reject" },
+			body: `{"comment": "not now"}`, status: http.StatusOK},
+		{id: "requestRemediation", token: "operator-token", path: runPath("/remediation/requests"), body: remediation,
+			status: http.StatusCreated, then: keepRemediation},
+		{id: "approveRemediation", path: func() string { return "/remediations/" + remediationID + "/approve" },
+			body: `{}`, status: http.StatusOK},
+		{id: "listProfiles", path: at("/profiles"), status: http.StatusOK},
+		{id: "queryState", path: at("/state?type=database"), status: http.StatusOK},
+		{id: "getTrends", path: at("/trends?level=instance"), status: http.StatusOK},
+		{id: "getSummary", path: at("/summary"), status: http.StatusOK},
+		{id: "listVMs", path: at("/dbservers"), status: http.StatusOK},
+		{id: "listInstances", path: at("/instances?status=failed"), status: http.StatusOK},
+		{id: "listDatabases", path: at("/databases?sort=score"), status: http.StatusOK},
+		{id: "previewDigest", path: at("/digest/preview"), status: http.StatusOK},
+		{id: "listWebhooks", path: at("/webhooks"), status: http.StatusOK},
+		{id: "testWebhook", path: at("/webhooks/hook/test"), status: http.StatusOK},
+		{id: "listWebhookDeliveries", path: at("/webhooks/deliveries"), status: http.StatusOK},
+		{id: "sendDigest", path: at("/digest/send"), status: http.StatusOK},
+		{id: "getConfig", path: at("/config"), status: http.StatusOK},
+		{id: "reloadConfig", path: at("/config/reload"), status: http.StatusOK},
+		{id: "queryAudit", path: at("/audit?limit=5"), status: http.StatusOK},
+		{id: "exportAudit", path: at("/audit/export"), status: http.StatusOK},
+		{id: "putCredential", path: at("/credentials/wave-1"), body: `{"username": "CORP\\svc", "password": "pw"}`, status: http.StatusOK},
+		{id: "listCredentials", path: at("/credentials"), status: http.StatusOK},
+		{id: "deleteCredential", path: at("/credentials/wave-1"), status: http.StatusOK},
+		{id: "createWaiver", path: at("/waivers"), status: http.StatusCreated,
+			body: `{"check_id": "vm.disk_free_space", "host": "sql01", "justification": "disk on order", "expires_at": "2099-01-01"}`,
+			then: func(body map[string]any) { waiverID, _ = jsonObject(body, "waiver")["id"].(string) }},
+		{id: "listWaivers", path: at("/waivers"), status: http.StatusOK},
+		{id: "deleteWaiver", path: func() string { return "/waivers/" + waiverID }, status: http.StatusOK},
+		{id: "getRun", path: at("/runs/missing"), status: http.StatusNotFound},
+		{id: "cancelRun", token: "operator-token", path: at("/runs/%s/cancel", running.ID), status: http.StatusOK},
+		{id: "rerun", token: "operator-token", path: runPath("/rerun"), body: `{"mode": "failed_hosts"}`, status: http.StatusOK},
+		{id: "startCheck", token: "operator-token", path: at("/check"), body: `{"hostnames": "sql03"}`, status: http.StatusOK},
+	}
+
+	ops := make(map[string][2]string)
+	for _, e := range apiEndpoints() {
+		path, _ := openAPIPath(e.Path)
+		ops[e.ID] = [2]string{e.Method, path}
+	}
+	covered := make(map[string]bool)
+	for _, step := range steps {
+		op, ok := ops[step.id]
+		if !ok {
+			t.Fatalf("no endpoint %s", step.id)
+		}
+		covered[step.id] = true
+		method, opPath := op[0], op[1]
+		token := step.token
+		if token == "" {
+			token = "admin-token"
+		}
+
+		path := step.path()
+		var body io.Reader
+		if step.body != "" {
+			body = strings.NewReader(step.body)
+		}
+		req := httptest.NewRequest(method, "/api/"+apiVersion+path, body)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		name := step.id + " " + path
+		if w.Code != step.status {
+			t.Errorf("%s: status %d, want %d: %s", name, w.Code, step.status, w.Body.String())
+			continue
+		}
+		responses := jsonObject(frozen, "paths", opPath, strings.ToLower(method), "responses")
+		response := jsonObject(responses, fmt.Sprint(w.Code))
+		if response == nil {
+			response = jsonObject(responses, "default")
+		}
+		mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+		content := jsonObject(response, "content", mediaType)
+		if content == nil {
+			t.Errorf("%s: %d %s is not in the contract", name, w.Code, mediaType)
+			continue
+		}
+		if mediaType != "application/json" {
+			continue
+		}
+		var decoded any
+		if err := json.Unmarshal(w.Body.Bytes(), &decoded); err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		for _, e := range schemaErrors(frozen, jsonObject(content, "schema"), decoded, "$") {
+			t.Errorf("%s: %s", name, e)
+		}
+		if step.then != nil {
+			step.then(jsonObject(decoded))
+		}
+	}
+	for id := range ops {
+		if !covered[id] {
+			t.Errorf("endpoint %s has no step", id)
+		}
+	}
+
+	// Let the approved remediation and the started runs finish
+	inflight.Wait()
+	select {
+	case msg := <-sink.messages:
+		if !strings.Contains(msg, "Subject: SQL Server fitment digest") {
+			t.Errorf("digest mail = %.200s", msg)
+		}
+	default:
+		t.Error("no digest mailed")
+	}
+}
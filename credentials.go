@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultCredentialStorePath = "./data/credentials.enc"
+	defaultMasterKeyPath       = "./data/master.key"
+
+	AuditCredentialPut    = "credential.put"
+	AuditCredentialDelete = "credential.delete"
+)
+
+// CredentialsConfig locates the encrypted credential store and its master key
+type CredentialsConfig struct {
+	StorePath     string `yaml:"store_path"`
+	MasterKeyPath string `yaml:"master_key_path"`
+}
+
+// Credential is a username/password used to reach a target host.
+// It must never be logged or returned by an API.
+type Credential struct {
+	Name      string `json:"name"`
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// CredentialInfo is the public, secret-free view of a stored credential
+type CredentialInfo struct {
+	Name      string `json:"name"`
+	Username  string `json:"username"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// String keeps the password out of logs and error messages
+func (c Credential) String() string {
+	return fmt.Sprintf("credential %s (%s)", c.Name, c.Username)
+}
+
+// GoString keeps the password out of %#v output
+func (c Credential) GoString() string {
+	return c.String()
+}
+
+// CredentialStore keeps credentials encrypted at rest with AES-256-GCM
+type CredentialStore struct {
+	mu    sync.Mutex
+	path  string
+	key   []byte
+	creds map[string]Credential
+}
+
+// ===== Globals =====
+
+var credentialStore *CredentialStore
+
+// openCredentialStore loads the store at storePath, creating the master key
+// at keyPath on first use
+func openCredentialStore(storePath, keyPath string) (*CredentialStore, error) {
+	if storePath == "" {
+		storePath = defaultCredentialStorePath
+	}
+	if keyPath == "" {
+		keyPath = defaultMasterKeyPath
+	}
+
+	key, err := loadOrCreateMasterKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	store := &CredentialStore{path: storePath, key: key, creds: make(map[string]Credential)}
+
+	data, err := os.ReadFile(storePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credential store %s: %v", storePath, err)
+	}
+	plain, err := store.decrypt(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credential store %s (wrong master key?): %v", storePath, err)
+	}
+	if err := json.Unmarshal(plain, &store.creds); err != nil {
+		return nil, fmt.Errorf("failed to parse credential store %s: %v", storePath, err)
+	}
+	return store, nil
+}
+
+func loadOrCreateMasterKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("master key %s must hold 32 hex-encoded bytes", path)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read master key %s: %v", path, err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write master key %s: %v", path, err)
+	}
+	logrus.Warnf("Created new credential master key at %s; back it up, credentials cannot be recovered without it", path)
+	return key, nil
+}
+
+func (s *CredentialStore) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s *CredentialStore) encrypt(plain []byte) ([]byte, error) {
+	aead, err := s.gcm()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func (s *CredentialStore) decrypt(data []byte) ([]byte, error) {
+	aead, err := s.gcm()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, nil)
+}
+
+// save writes the store atomically; callers hold s.mu
+func (s *CredentialStore) save() error {
+	plain, err := json.Marshal(s.creds)
+	if err != nil {
+		return err
+	}
+	sealed, err := s.encrypt(plain)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, sealed, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Get returns the named credential
+func (s *CredentialStore) Get(name string) (Credential, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.creds[name]
+	return c, ok
+}
+
+// Put creates or replaces a credential
+func (s *CredentialStore) Put(c Credential) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	old, existed := s.creds[c.Name]
+	s.creds[c.Name] = c
+	if err := s.save(); err != nil {
+		if existed {
+			s.creds[c.Name] = old
+		} else {
+			delete(s.creds, c.Name)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete removes a credential, reporting whether it existed
+func (s *CredentialStore) Delete(name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.creds[name]
+	if !ok {
+		return false, nil
+	}
+	delete(s.creds, name)
+	if err := s.save(); err != nil {
+		s.creds[name] = old
+		return true, err
+	}
+	return true, nil
+}
+
+// List returns the stored credentials without secrets, sorted by name
+func (s *CredentialStore) List() []CredentialInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]CredentialInfo, 0, len(s.creds))
+	for _, c := range s.creds {
+		list = append(list, CredentialInfo{Name: c.Name, Username: c.Username, UpdatedAt: c.UpdatedAt})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// resolveHostCredential returns the credential referenced for hostname, or
+// nil to run under the service's own identity
+func resolveHostCredential(ctx context.Context, hostname string) (*Credential, error) {
+	ref := currentConfig().credentialRefForHost(hostname)
+	if ref == "" {
+		return nil, nil
+	}
+	cred, err := resolveCredential(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("credential for %s: %v", hostname, err)
+	}
+	return cred, nil
+}
+
+// credentialRefsForHosts lists the distinct credential references used by hostnames
+func credentialRefsForHosts(hostnames []string) []string {
+	seen := make(map[string]bool)
+	var refs []string
+	for _, h := range hostnames {
+		if ref := currentConfig().credentialRefForHost(h); ref != "" && !seen[ref] {
+			seen[ref] = true
+			refs = append(refs, ref)
+		}
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+// ===== API Handlers =====
+
+// handleListCredentials returns credential names and usernames, never passwords
+func handleListCredentials(c *gin.Context) {
+	if credentialStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Credential store unavailable"})
+		return
+	}
+	c.JSON(http.StatusOK, CredentialListResponse{Credentials: credentialStore.List()})
+}
+
+// handlePutCredential creates or replaces a credential
+func handlePutCredential(c *gin.Context) {
+	if credentialStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Credential store unavailable"})
+		return
+	}
+	var req CredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Username == "" || req.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username and password are required"})
+		return
+	}
+
+	name := c.Param("name")
+	err := credentialStore.Put(Credential{Name: name, Username: req.Username, Password: req.Password})
+	if err != nil {
+		logrus.Errorf("Failed to save credential %s: %v", name, err)
+		auditRequest(c, AuditEntry{Action: AuditCredentialPut, CredentialRefs: []string{name}, Outcome: AuditOutcomeFailure})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save credential"})
+		return
+	}
+	auditRequest(c, AuditEntry{Action: AuditCredentialPut, CredentialRefs: []string{name}, Outcome: AuditOutcomeSuccess})
+
+	cred, _ := credentialStore.Get(name)
+	c.JSON(http.StatusOK, CredentialResponse{Credential: CredentialInfo{Name: cred.Name, Username: cred.Username, UpdatedAt: cred.UpdatedAt}})
+}
+
+// handleDeleteCredential removes a credential
+func handleDeleteCredential(c *gin.Context) {
+	if credentialStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Credential store unavailable"})
+		return
+	}
+	name := c.Param("name")
+	existed, err := credentialStore.Delete(name)
+	if err != nil {
+		logrus.Errorf("Failed to delete credential %s: %v", name, err)
+		auditRequest(c, AuditEntry{Action: AuditCredentialDelete, CredentialRefs: []string{name}, Outcome: AuditOutcomeFailure})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete credential"})
+		return
+	}
+	if !existed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Credential not found"})
+		return
+	}
+	auditRequest(c, AuditEntry{Action: AuditCredentialDelete, CredentialRefs: []string{name}, Outcome: AuditOutcomeSuccess})
+	c.JSON(http.StatusOK, StatusResponse{Status: "deleted"})
+}
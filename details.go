@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxRawOutput caps the executor output kept per host; the rest is dropped
+const maxRawOutput = 64 << 10
+
+// HostDetail records how a host was checked in one run and the raw facts
+// its executor (and the TDS checks) returned
+type HostDetail struct {
+	Executor   string `json:"executor"`
+	StartedAt  string `json:"started_at"`
+	FinishedAt string `json:"finished_at"`
+	DurationMS int64  `json:"duration_ms"`
+	// Error is why the executor failed, or the script's own error message
+	Error string     `json:"error,omitempty"`
+	Facts *HostFacts `json:"facts,omitempty"`
+	// RawOutput is what the check script printed, cut at 64 KiB; kept with
+	// the run only, not in history
+	RawOutput string `json:"raw_output,omitempty"`
+
+	// interrupted hosts were not (fully) checked before a shutdown
+	interrupted bool
+}
+
+// newHostDetail builds the detail of a host checked between started and now
+func newHostDetail(hostname string, started time.Time, result *ComprehensiveResult, output string, sqlFacts []InstanceFacts, err error) *HostDetail {
+	finished := time.Now()
+	d := &HostDetail{
+		Executor:   executorName(hostname),
+		StartedAt:  started.UTC().Format(time.RFC3339Nano),
+		FinishedAt: finished.UTC().Format(time.RFC3339Nano),
+		DurationMS: finished.Sub(started).Milliseconds(),
+		RawOutput:  truncateOutput(output, maxRawOutput),
+	}
+	switch {
+	case err != nil:
+		d.Error = err.Error()
+	case result != nil:
+		d.Error, d.Facts = result.ErrorMessage, result.Facts
+	}
+	if d.Facts == nil && sqlFacts != nil {
+		d.Facts = &HostFacts{Instances: sqlFacts}
+	}
+	return d
+}
+
+// truncateOutput cuts output to at most limit bytes, on a character
+// boundary, and notes how much was dropped
+func truncateOutput(output string, limit int) string {
+	if len(output) <= limit {
+		return output
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(output[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s\n... (%d more bytes truncated)", output[:cut], len(output)-cut)
+}
+
+// RuleInfo is the catalog entry of the rule behind a check
+type RuleInfo struct {
+	ID          string   `json:"id"`
+	Scope       string   `json:"scope"`
+	When        string   `json:"when,omitempty"`
+	Pass        string   `json:"pass"`
+	Severity    Severity `json:"severity"`
+	Remediation string   `json:"remediation,omitempty"`
+	Automated   bool     `json:"automated"`
+}
+
+// CheckDetail is one check result with its rule and timing
+type CheckDetail struct {
+	CheckResult
+	Rule      *RuleInfo `json:"rule,omitempty"`
+	CheckedAt string    `json:"checked_at,omitempty"`
+}
+
+// ruleInfo returns the catalog entry for checkID in profile, if it has one
+func (p *Profile) ruleInfo(checkID string) *RuleInfo {
+	if p == nil || checkID == "" {
+		return nil
+	}
+	for _, r := range p.Rules {
+		if r.ID != checkID {
+			continue
+		}
+		info := &RuleInfo{ID: r.ID, Scope: r.Scope, When: r.When, Pass: r.Pass, Severity: r.Severity}
+		if info.Severity == "" {
+			info.Severity = SeverityCritical
+		}
+		if r.Remediation != nil {
+			info.Remediation = r.Remediation.Description
+			info.Automated = r.Remediation.Script != ""
+		}
+		return info
+	}
+	return nil
+}
+
+// checkDetails pairs checks with their rules and the time their host finished
+func checkDetails(checks []CheckResult, profile *Profile, host *HostDetail) []CheckDetail {
+	details := make([]CheckDetail, 0, len(checks))
+	for _, check := range checks {
+		d := CheckDetail{CheckResult: check, Rule: profile.ruleInfo(check.CheckID)}
+		if host != nil {
+			d.CheckedAt = host.FinishedAt
+		}
+		details = append(details, d)
+	}
+	return details
+}
+
+// resolveEntity finds the result key for name in results: either the full
+// key (host\instance or host\instance\database; escape the backslash as
+// %5C) or, when it is unique in the run, just the instance or database name
+func resolveEntity(results map[string][]CheckResult, name string) (string, []string) {
+	if _, ok := results[name]; ok {
+		return name, nil
+	}
+	var matches []string
+	for _, key := range sortedKeys(results) {
+		if strings.EqualFold(key, name) {
+			return key, nil
+		}
+		if i := strings.LastIndex(key, "\\"); i >= 0 && strings.EqualFold(key[i+1:], name) {
+			matches = append(matches, key)
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0], nil
+	}
+	return "", matches
+}
+
+// finishedRunResults returns the run's results, or writes an error response
+func finishedRunResults(c *gin.Context) (*Run, *BatchResponse, bool) {
+	run := getRun(c.Param("id"))
+	if run == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Run not found"})
+		return nil, nil, false
+	}
+	if run.snapshot().FinishedAt == "" || run.results == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Run has not finished"})
+		return nil, nil, false
+	}
+	return run, run.results, true
+}
+
+// entityDetail builds the detail response shared by the entity endpoints
+func entityDetail(run *Run, r *BatchResponse, entityType, key string, checks []CheckResult, fitment Fitment) EntityDetail {
+	snap := run.snapshot()
+	// Rules from another version of the profile would not be the ones applied
+	profile, _ := currentConfig().profile(snap.Profile)
+	if profile != nil && profile.Version != snap.ProfileVersion {
+		profile = nil
+	}
+	host, _, _ := strings.Cut(key, "\\")
+	hostDetail := r.HostDetails[host]
+
+	failed := false
+	for _, check := range checks {
+		failed = failed || check.Status.Fails()
+	}
+	return EntityDetail{
+		RunID:          snap.ID,
+		Profile:        snap.Profile,
+		ProfileVersion: snap.ProfileVersion,
+		Entity:         EntityRef{Key: key, Type: entityType, Host: host},
+		Status:         statusLabel(failed),
+		Fitment:        fitment,
+		Checks:         checkDetails(checks, profile, hostDetail),
+		Host:           hostDetail,
+	}
+}
+
+// ===== API Handlers =====
+
+// handleVMDetail returns every check of a VM in a run, with the raw facts
+// collected from it and the keys of its instances and databases
+func handleVMDetail(c *gin.Context) {
+	run, r, ok := finishedRunResults(c)
+	if !ok {
+		return
+	}
+	host, found := findFold(resultHosts(r), c.Param("host"))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Host not in run"})
+		return
+	}
+	var instances, databases []string
+	for _, key := range sortedKeys(r.InstanceResults) {
+		if strings.HasPrefix(key, host+"\\") {
+			instances = append(instances, key)
+		}
+	}
+	for _, key := range sortedKeys(r.DatabaseResults) {
+		if strings.HasPrefix(key, host+"\\") {
+			databases = append(databases, key)
+		}
+	}
+	detail := entityDetail(run, r, EntityVM, host, r.VMResults[host], vmFitment(r, host))
+	detail.Instances, detail.Databases = instances, databases
+	c.JSON(http.StatusOK, detail)
+}
+
+// handleInstanceDetail returns every check of an instance in a run
+func handleInstanceDetail(c *gin.Context) {
+	run, r, ok := finishedRunResults(c)
+	if !ok {
+		return
+	}
+	key, candidates := resolveEntity(r.InstanceResults, c.Param("name"))
+	if key == "" {
+		entityNotFound(c, "Instance", candidates)
+		return
+	}
+	var databases []string
+	for _, db := range sortedKeys(r.DatabaseResults) {
+		if databaseInInstance(db, key) {
+			databases = append(databases, db)
+		}
+	}
+	detail := entityDetail(run, r, EntityInstance, key, r.InstanceResults[key], instanceFitment(r, key))
+	detail.Databases = databases
+	c.JSON(http.StatusOK, detail)
+}
+
+// handleDatabaseDetail returns every check of a database in a run
+func handleDatabaseDetail(c *gin.Context) {
+	run, r, ok := finishedRunResults(c)
+	if !ok {
+		return
+	}
+	key, candidates := resolveEntity(r.DatabaseResults, c.Param("name"))
+	if key == "" {
+		entityNotFound(c, "Database", candidates)
+		return
+	}
+	_, instance, _ := splitDatabaseKey(key)
+	detail := entityDetail(run, r, EntityDatabase, key, r.DatabaseResults[key], fitmentOf(r.DatabaseResults[key]))
+	detail.Instance = instance
+	c.JSON(http.StatusOK, detail)
+}
+
+func entityNotFound(c *gin.Context, kind string, candidates []string) {
+	if len(candidates) > 1 {
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:      fmt.Sprintf("%s name is ambiguous; use the full key", kind),
+			Candidates: candidates,
+		})
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": kind + " not in run"})
+}
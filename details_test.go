@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTruncateOutput(t *testing.T) {
+	if got := truncateOutput("short", 10); got != "short" {
+		t.Errorf("got %q", got)
+	}
+	got := truncateOutput("abcdefghij", 4)
+	if got != "abcd\n... (6 more bytes truncated)" {
+		t.Errorf("got %q", got)
+	}
+	// Never cut a multi-byte character in half
+	got = truncateOutput("aé"+strings.Repeat("x", 10), 2)
+	if !strings.HasPrefix(got, "a\n") || !utf8.ValidString(got) {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestNewHostDetailRawOutput(t *testing.T) {
+	withConfig(t, &Config{})
+	big := strings.Repeat("y", maxRawOutput+100)
+	d := newHostDetail("sql01", time.Now(), nil, big, nil, errors.New("failed to parse check output"))
+	if !strings.HasPrefix(d.RawOutput, big[:maxRawOutput]) || !strings.HasSuffix(d.RawOutput, "(100 more bytes truncated)") {
+		t.Errorf("raw output not capped: %d bytes, ends %q", len(d.RawOutput), d.RawOutput[len(d.RawOutput)-40:])
+	}
+	if d.Error == "" || d.Executor != ExecutorPowerShell {
+		t.Errorf("detail = %+v", d)
+	}
+}
+
+// detailRun finishes a run of the built-in profile over hosts, each with a
+// default instance holding a Sales database
+func detailRun(t *testing.T, hosts ...string) *Run {
+	t.Helper()
+	profile := builtinProfile()
+	r := &BatchResponse{
+		RunID:           newID(),
+		Profile:         profile.Name,
+		ProfileVersion:  profile.Version,
+		VMResults:       make(map[string][]CheckResult),
+		InstanceResults: make(map[string][]CheckResult),
+		DatabaseResults: make(map[string][]CheckResult),
+		HostDetails:     make(map[string]*HostDetail),
+	}
+	for _, host := range hosts {
+		result := &ComprehensiveResult{Success: true, Target: host, Facts: &HostFacts{
+			VM: map[string]any{"execution_policy": "RemoteSigned", "memory_gb": 16, "disks": disks(50)},
+			Instances: []InstanceFacts{
+				instanceFacts(defaultSQLInstance, "15.0.2000.5", "Developer Edition", "RTM", []DatabaseFacts{
+					sqlDatabaseRow{Name: "Sales", State: "ONLINE", RecoveryModel: "FULL", DataMB: 120, LogMB: 8}.facts(),
+				}),
+			},
+		}}
+		profile.evaluate(result, nil)
+		var total, passed, failed, errored int
+		processResults(host, result, r, &total, &passed, &failed, &errored)
+	}
+	summarize(r)
+	run := startRun(r, hosts, "seed", profile, nil)
+	finishRun(run)
+	return run
+}
+
+func TestEntityDetailHandlers(t *testing.T) {
+	cfg := &Config{Profiles: ProfilesConfig{Dir: t.TempDir()}}
+	if err := cfg.loadProfiles(); err != nil {
+		t.Fatal(err)
+	}
+	withConfig(t, cfg)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/runs/:id/vms/:host", handleVMDetail)
+	router.GET("/runs/:id/instances/:name", handleInstanceDetail)
+	router.GET("/runs/:id/databases/:name", handleDatabaseDetail)
+	get := func(path string) (int, EntityDetail, ErrorResponse) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var detail EntityDetail
+		var errResp ErrorResponse
+		json.Unmarshal(w.Body.Bytes(), &detail)
+		json.Unmarshal(w.Body.Bytes(), &errResp)
+		return w.Code, detail, errResp
+	}
+
+	run := detailRun(t, "sql01", "sql02")
+	base := "/runs/" + run.ID
+
+	// Host names match whatever their case
+	code, detail, _ := get(base + "/vms/SQL01")
+	if code != http.StatusOK || detail.Entity.Key != "sql01" || len(detail.Databases) != 1 {
+		t.Errorf("vm SQL01 = %d %+v", code, detail.Entity)
+	}
+	if len(detail.Checks) == 0 || detail.Checks[0].Rule == nil {
+		t.Errorf("checks lack their rules: %+v", detail.Checks)
+	}
+
+	// A name in more than one host is ambiguous; the full key is not
+	for _, kind := range []string{"instances/MSSQLSERVER", "databases/Sales"} {
+		code, _, errResp := get(base + "/" + kind)
+		if code != http.StatusConflict || len(errResp.Candidates) != 2 || !strings.HasPrefix(errResp.Candidates[0], `sql01\`) {
+			t.Errorf("%s = %d %+v, want 409 with both candidates", kind, code, errResp)
+		}
+	}
+	code, detail, _ = get(base + `/databases/sql02%5CMSSQLSERVER%5Csales`)
+	if code != http.StatusOK || detail.Entity.Key != `sql02\MSSQLSERVER\Sales` || detail.Instance != "MSSQLSERVER" {
+		t.Errorf("database by key = %d %+v", code, detail.Entity)
+	}
+	if code, _, _ := get(base + "/instances/OTHER"); code != http.StatusNotFound {
+		t.Errorf("unknown instance = %d, want 404", code)
+	}
+
+	// Once the profile moves on, its rules no longer describe the run
+	runsMu.Lock()
+	run.ProfileVersion = "older"
+	runsMu.Unlock()
+	if _, detail, _ := get(base + "/vms/sql01"); len(detail.Checks) == 0 || detail.Checks[0].Rule != nil {
+		t.Errorf("checks of another profile version carry rules: %+v", detail.Checks)
+	}
+
+	running := startRun(&BatchResponse{RunID: newID(), HostDetails: map[string]*HostDetail{}}, []string{"sql03"}, "seed", builtinProfile(), nil)
+	t.Cleanup(func() { finishRun(running) })
+	for _, path := range []string{"/vms/sql03", "/instances/MSSQLSERVER", "/databases/Sales"} {
+		if code, _, _ := get("/runs/" + running.ID + path); code != http.StatusConflict {
+			t.Errorf("%s of a running run = %d, want 409", path, code)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// SMTPConfig configures the email digest notifier
+type SMTPConfig struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	StartTLS bool     `yaml:"starttls"`
+	// SendAfterRun mails a digest as soon as each run finishes
+	SendAfterRun bool `yaml:"send_after_run"`
+	// DigestTime sends a daily digest of the latest run at HH:MM local time
+	DigestTime string `yaml:"digest_time"`
+}
+
+// GroupDigest holds pass/fail counts for one inventory group
+type GroupDigest struct {
+	Name   string
+	Hosts  int
+	Passed int
+	Failed int
+}
+
+// DigestData is the model rendered into the digest email
+type DigestData struct {
+	RunID             string
+	Timestamp         string
+	Total             int
+	Passed            int
+	Failed            int
+	Summary           SummaryStats
+	Groups            []GroupDigest
+	NewlyFailingVMs   []string
+	NewlyFailingInsts []string
+	NewlyFailingDBs   []string
+	ReportURL         string
+}
+
+var digestMu sync.Mutex
+var digestStop chan struct{}
+
+// finishedMu guards finishedResults and finishedPrevious: the latest
+// finished batch, which the digest reports on, and the results that were
+// latest when it started, for its "newly failing" lists
+var finishedMu sync.Mutex
+var finishedResults *BatchResponse
+var finishedPrevious *BatchResponse
+
+// latestFinished returns the latest finished batch and its predecessor
+func latestFinished() (current, previous *BatchResponse) {
+	finishedMu.Lock()
+	defer finishedMu.Unlock()
+	return finishedResults, finishedPrevious
+}
+
+// recordFinished makes current, a finished batch that started from
+// previous, the one the digest reports on. A rerun's merged state only
+// replaces the batch it was merged into, so rerunning an older run does not
+// roll the digest back.
+func recordFinished(current, previous *BatchResponse, rerun bool) {
+	finishedMu.Lock()
+	defer finishedMu.Unlock()
+	if rerun && finishedResults != previous {
+		return
+	}
+	finishedResults, finishedPrevious = current, previous
+}
+
+var digestTemplate = template.Must(template.New("digest").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: Arial, sans-serif; color: #22272e;">
+  <h2>SQL Server Fitment Digest</h2>
+  <p>Run {{.RunID}} finished at {{.Timestamp}}: {{.Passed}} of {{.Total}} database servers passed, {{.Failed}} failed.</p>
+  <p>Checks: {{.Summary.PassedChecks}} passed, {{.Summary.FailedChecks}} failed, {{.Summary.ErrorChecks}} errors, {{.Summary.WarningChecks}} warnings, {{.Summary.WaivedChecks}} waived.</p>
+
+  <h3>By group</h3>
+  <table cellpadding="6" cellspacing="0" border="1" style="border-collapse: collapse;">
+    <tr><th align="left">Group</th><th>Servers</th><th>Passed</th><th>Failed</th></tr>
+    {{range .Groups}}<tr><td>{{.Name}}</td><td align="right">{{.Hosts}}</td><td align="right">{{.Passed}}</td><td align="right">{{.Failed}}</td></tr>
+    {{end}}
+  </table>
+
+  <h3>Newly failing since the previous run</h3>
+  {{if or .NewlyFailingVMs .NewlyFailingInsts .NewlyFailingDBs}}
+  {{if .NewlyFailingVMs}}<p><b>Database servers</b></p><ul>{{range .NewlyFailingVMs}}<li>{{.}}</li>{{end}}</ul>{{end}}
+  {{if .NewlyFailingInsts}}<p><b>Instances</b></p><ul>{{range .NewlyFailingInsts}}<li>{{.}}</li>{{end}}</ul>{{end}}
+  {{if .NewlyFailingDBs}}<p><b>Databases</b></p><ul>{{range .NewlyFailingDBs}}<li>{{.}}</li>{{end}}</ul>{{end}}
+  {{else}}
+  <p>None.</p>
+  {{end}}
+
+  {{if .ReportURL}}<p><a href="{{.ReportURL}}">Open the full report</a></p>{{end}}
+</body>
+</html>
+`))
+
+// buildDigest summarizes current, comparing against previous for new failures
+func buildDigest(current, previous *BatchResponse) DigestData {
+	data := DigestData{
+		RunID:     current.RunID,
+		Timestamp: current.Timestamp,
+		Total:     current.Total,
+		Passed:    current.Passed,
+		Failed:    current.Failed,
+		Summary:   current.Summary,
+	}
+	if publicURL := currentConfig().PublicURL; publicURL != "" {
+		data.ReportURL = strings.TrimRight(publicURL, "/") + "/summary"
+	}
+
+	groups := make(map[string]*GroupDigest)
+	for hostname := range current.VMResults {
+		name := currentConfig().groupForHost(hostname)
+		g, ok := groups[name]
+		if !ok {
+			g = &GroupDigest{Name: name}
+			groups[name] = g
+		}
+		g.Hosts++
+		if hostHasFailure(current, hostname) {
+			g.Failed++
+		} else {
+			g.Passed++
+		}
+	}
+	for _, g := range groups {
+		data.Groups = append(data.Groups, *g)
+	}
+	sort.Slice(data.Groups, func(i, j int) bool { return data.Groups[i].Name < data.Groups[j].Name })
+
+	var prevVMs, prevInsts, prevDBs map[string][]CheckResult
+	if previous != nil {
+		prevVMs, prevInsts, prevDBs = previous.VMResults, previous.InstanceResults, previous.DatabaseResults
+	}
+	data.NewlyFailingVMs = newlyFailingEntities(current.VMResults, prevVMs)
+	data.NewlyFailingInsts = newlyFailingEntities(current.InstanceResults, prevInsts)
+	data.NewlyFailingDBs = newlyFailingEntities(current.DatabaseResults, prevDBs)
+	return data
+}
+
+// hostHasFailure reports whether any check on the host or its instances/databases failed
+func hostHasFailure(response *BatchResponse, hostname string) bool {
+	if hasFailedCheck(response.VMResults[hostname]) {
+		return true
+	}
+	for name, checks := range response.InstanceResults {
+		if strings.HasPrefix(name, hostname+"\\") && hasFailedCheck(checks) {
+			return true
+		}
+	}
+	for name, checks := range response.DatabaseResults {
+		if strings.HasPrefix(name, hostname+"\\") && hasFailedCheck(checks) {
+			return true
+		}
+	}
+	return false
+}
+
+func renderDigest(data DigestData) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := digestTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sendDigest renders and mails the digest for current
+func sendDigest(current, previous *BatchResponse) error {
+	cfg := currentConfig().SMTP
+	if cfg.Host == "" || len(cfg.To) == 0 {
+		return fmt.Errorf("SMTP is not configured")
+	}
+
+	data := buildDigest(current, previous)
+	body, err := renderDigest(data)
+	if err != nil {
+		return fmt.Errorf("failed to render digest: %v", err)
+	}
+
+	from := cfg.From
+	if from == "" {
+		from = cfg.Username
+	}
+	subject := fmt.Sprintf("SQL Server fitment digest: %d passed, %d failed", data.Passed, data.Failed)
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=UTF-8\r\n\r\n")
+	msg.Write(body)
+
+	return sendMail(cfg, from, msg.Bytes())
+}
+
+// sendMail delivers msg over SMTP, upgrading with STARTTLS and authenticating when configured
+func sendMail(cfg SMTPConfig, from string, msg []byte) error {
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	client, err := smtp.Dial(addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server %s: %v", addr, err)
+	}
+	defer client.Close()
+
+	if cfg.StartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP server %s does not support STARTTLS", addr)
+		}
+		if err := client.StartTLS(&tls.Config{ServerName: cfg.Host}); err != nil {
+			return fmt.Errorf("STARTTLS failed: %v", err)
+		}
+	}
+	if cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %v", err)
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range cfg.To {
+		if err := client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("recipient %s rejected: %v", rcpt, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func sendDigestAsync(current, previous *BatchResponse) {
+	if err := sendDigest(current, previous); err != nil {
+		logrus.Errorf("Failed to send fitment digest for run %s: %v", current.RunID, err)
+		return
+	}
+	logrus.Infof("Fitment digest for run %s sent to %d recipients", current.RunID, len(currentConfig().SMTP.To))
+}
+
+// startDigestScheduler sends the latest results daily at smtp.digest_time.
+// Calling it again replaces any running schedule, e.g. after a config reload.
+func startDigestScheduler() {
+	digestMu.Lock()
+	defer digestMu.Unlock()
+	if digestStop != nil {
+		close(digestStop)
+		digestStop = nil
+	}
+	digestTime := currentConfig().SMTP.DigestTime
+	if digestTime == "" {
+		return
+	}
+	at, _ := time.Parse("15:04", digestTime)
+	stop := make(chan struct{})
+	digestStop = stop
+
+	go func() {
+		for {
+			now := time.Now()
+			next := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+			if !next.After(now) {
+				next = next.AddDate(0, 0, 1)
+			}
+			select {
+			case <-stop:
+				return
+			case <-time.After(time.Until(next)):
+			}
+
+			current, previous := latestFinished()
+			if current == nil {
+				logrus.Info("Skipping scheduled digest: no check results yet")
+				continue
+			}
+			if !beginWork() {
+				return
+			}
+			sendDigestAsync(current, previous)
+			inflight.Done()
+		}
+	}()
+	logrus.Infof("Daily fitment digest scheduled at %s", currentConfig().SMTP.DigestTime)
+}
+
+// ===== API Handlers =====
+
+// handleDigestPreview renders the digest for the latest finished run as HTML
+func handleDigestPreview(c *gin.Context) {
+	current, previous := latestFinished()
+	if current == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No check results available"})
+		return
+	}
+	body, err := renderDigest(buildDigest(current, previous))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", body)
+}
+
+// handleDigestSend mails the digest for the latest finished run immediately
+func handleDigestSend(c *gin.Context) {
+	current, previous := latestFinished()
+	if current == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No check results available"})
+		return
+	}
+	if err := sendDigest(current, previous); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, DigestSentResponse{Status: "sent", Recipients: len(currentConfig().SMTP.To)})
+}
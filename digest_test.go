@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// vmBatch is a batch with one VM check per host, failed where fails is set
+func vmBatch(runID string, fails map[string]bool) *BatchResponse {
+	r := &BatchResponse{
+		RunID:           runID,
+		VMResults:       make(map[string][]CheckResult),
+		InstanceResults: make(map[string][]CheckResult),
+		DatabaseResults: make(map[string][]CheckResult),
+	}
+	for host, failed := range fails {
+		status := StatusSuccess
+		if failed {
+			status = StatusFailed
+		}
+		r.VMResults[host] = []CheckResult{{CheckID: "vm.execution_policy", Check: "PowerShell Execution Policy", Status: status}}
+	}
+	summarize(r)
+	return r
+}
+
+func TestDigestReportsFinishedRuns(t *testing.T) {
+	withConfig(t, &Config{})
+	current, previous := latestFinished()
+	last := latestResults()
+	t.Cleanup(func() {
+		recordFinished(current, previous, false)
+		setLatestResults(last)
+	})
+
+	first := vmBatch("first", map[string]bool{"sql01": false, "sql02": false})
+	second := vmBatch("second", map[string]bool{"sql01": true, "sql02": false})
+	recordFinished(first, nil, false)
+	recordFinished(second, first, false)
+	// A run still going is what the dashboards show, not the digest
+	setLatestResults(vmBatch("running", map[string]bool{"sql09": true}))
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/digest/preview", nil)
+	handleDigestPreview(c)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "second") || strings.Contains(w.Body.String(), "sql09") {
+		t.Errorf("preview = %d %.300s, want the second run", w.Code, w.Body.String())
+	}
+	got, got2 := latestFinished()
+	if data := buildDigest(got, got2); len(data.NewlyFailingVMs) != 1 || data.NewlyFailingVMs[0] != "sql01" {
+		t.Errorf("newly failing = %v, want sql01 against the run before", data.NewlyFailingVMs)
+	}
+
+	// Rerunning the older run does not roll the digest back
+	recordFinished(vmBatch("rerun-of-first", map[string]bool{"sql01": false}), first, true)
+	if got, _ := latestFinished(); got != second {
+		t.Errorf("digest reports %s, want second", got.RunID)
+	}
+	// A rerun of the latest run replaces it, compared with what it re-checked
+	fixed := vmBatch("rerun-of-second", map[string]bool{"sql01": false, "sql02": true})
+	recordFinished(fixed, second, true)
+	got, got2 = latestFinished()
+	if got != fixed || got2 != second {
+		t.Errorf("digest reports %s after %v, want the rerun after second", got.RunID, got2)
+	}
+	if data := buildDigest(got, got2); len(data.NewlyFailingVMs) != 1 || data.NewlyFailingVMs[0] != "sql02" {
+		t.Errorf("newly failing = %v, want sql02", data.NewlyFailingVMs)
+	}
+}
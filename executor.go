@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Executor names, as used in config
+const (
+	ExecutorPowerShell = "powershell"
+	ExecutorWinRM      = "winrm"
+	ExecutorSSH        = "ssh"
+)
+
+const checksScriptPath = "./checks.ps1"
+
+// Executor runs checks.ps1 against one host to collect its facts, and other
+// PowerShell such as remediation scripts
+type Executor interface {
+	// Run also returns the script's raw output, when there is any, even if
+	// it could not be parsed
+	Run(ctx context.Context, hostname string, cred *Credential) (*ComprehensiveResult, string, error)
+	// Exec runs script on hostname and returns its standard output
+	Exec(ctx context.Context, hostname string, cred *Credential, script string) (string, error)
+}
+
+// ExecutorConfig selects and configures how checks reach target hosts
+type ExecutorConfig struct {
+	// Default executor for hosts without their own; "powershell" if empty
+	Default string      `yaml:"default"`
+	WinRM   WinRMConfig `yaml:"winrm"`
+	SSH     SSHConfig   `yaml:"ssh"`
+}
+
+// powerShellExecutor shells out to the local powershell.exe and Invoke-Command
+type powerShellExecutor struct{}
+
+func (powerShellExecutor) Run(ctx context.Context, hostname string, cred *Credential) (*ComprehensiveResult, string, error) {
+	return runPowerShellScript(ctx, hostname, cred)
+}
+
+// invokeCommandWrapper runs the script in NDB_PRECHECK_SCRIPT on the target
+// through Invoke-Command, the same way script.ps1 runs checks.ps1
+const invokeCommandWrapper = `$invokeParams = @{
+    ComputerName = $env:NDB_PRECHECK_TARGET
+    ScriptBlock = [scriptblock]::Create($env:NDB_PRECHECK_SCRIPT)
+    ErrorAction = 'Stop'
+}
+if ($env:NDB_PRECHECK_USERNAME) {
+    $securePassword = ConvertTo-SecureString $env:NDB_PRECHECK_PASSWORD -AsPlainText -Force
+    $invokeParams.Credential = New-Object System.Management.Automation.PSCredential($env:NDB_PRECHECK_USERNAME, $securePassword)
+    Remove-Item Env:\NDB_PRECHECK_PASSWORD -ErrorAction SilentlyContinue
+}
+Invoke-Command @invokeParams
+`
+
+func (powerShellExecutor) Exec(ctx context.Context, hostname string, cred *Credential, script string) (string, error) {
+	cmd := exec.CommandContext(ctx, "powershell.exe",
+		"-NoProfile", "-NonInteractive", "-ExecutionPolicy", "Bypass",
+		"-EncodedCommand", encodePowerShell(invokeCommandWrapper))
+	cmd.Env = append(os.Environ(), "NDB_PRECHECK_TARGET="+hostname, "NDB_PRECHECK_SCRIPT="+script)
+	if cred != nil {
+		cmd.Env = append(cmd.Env,
+			"NDB_PRECHECK_USERNAME="+cred.Username,
+			"NDB_PRECHECK_PASSWORD="+cred.Password)
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("PowerShell execution failed: %v, output: %s", err, string(output))
+	}
+	return string(output), nil
+}
+
+func isKnownExecutor(name string) bool {
+	switch name {
+	case "", ExecutorPowerShell, ExecutorWinRM, ExecutorSSH:
+		return true
+	}
+	return false
+}
+
+// usesExecutor reports whether the default or any inventory host selects name
+func (c *Config) usesExecutor(name string) bool {
+	if c.Executor.Default == name {
+		return true
+	}
+	for _, h := range c.Inventory.Hosts {
+		if h.Executor == name {
+			return true
+		}
+	}
+	return false
+}
+
+// executorName returns the executor configured for hostname
+func executorName(hostname string) string {
+	cfg := currentConfig()
+	if h := cfg.inventoryHost(hostname); h != nil && h.Executor != "" {
+		return h.Executor
+	}
+	if cfg.Executor.Default != "" {
+		return cfg.Executor.Default
+	}
+	return ExecutorPowerShell
+}
+
+// executorFor returns the executor configured for hostname
+func executorFor(hostname string) Executor {
+	switch executorName(hostname) {
+	case ExecutorWinRM:
+		return winRMExecutor{cfg: currentConfig().Executor.WinRM}
+	case ExecutorSSH:
+		return sshExecutor{cfg: currentConfig().Executor.SSH}
+	default:
+		return powerShellExecutor{}
+	}
+}
+
+// remoteChecksScript wraps checks.ps1 so it runs standalone on the target
+// and prints its result as compact JSON
+func remoteChecksScript(hostname string) (string, error) {
+	checks, err := os.ReadFile(checksScriptPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", checksScriptPath, err)
+	}
+	target := strings.ReplaceAll(hostname, "'", "''")
+	return fmt.Sprintf("$checks = {\n%s\n}\n(& $checks '%s') | ConvertTo-Json -Depth 10 -Compress\n",
+		string(checks), target), nil
+}
+
+// maxCommandLine is the longest command line cmd.exe accepts; WinRM and
+// Windows OpenSSH both start their commands through it
+const maxCommandLine = 8191
+
+// stdinBootstrap runs the script sent on standard input, as base64 of its
+// UTF-8 so no console code page touches it. Taking the input whole keeps
+// here-strings and multi-line blocks intact, which -Command - does not.
+const stdinBootstrap = `$ProgressPreference = 'SilentlyContinue'
+$script = [Text.Encoding]::UTF8.GetString([Convert]::FromBase64String(($input | Out-String)))
+& ([scriptblock]::Create($script))
+`
+
+// stdinCommand is the command line that runs the script from stdinPayload;
+// it is the same few hundred characters whatever the script's size
+func stdinCommand(shell string) string {
+	return shell + " -NoLogo -NoProfile -NonInteractive -EncodedCommand " + encodePowerShell(stdinBootstrap)
+}
+
+// stdinPayload is what stdinCommand expects on standard input
+func stdinPayload(script string) string {
+	return base64.StdEncoding.EncodeToString([]byte(script)) + "\n"
+}
+
+// parseChecksOutput decodes the JSON printed by remoteChecksScript
+func parseChecksOutput(hostname, output string) (*ComprehensiveResult, error) {
+	var result ComprehensiveResult
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse check output: %v, raw output: %s", err, output)
+	}
+	result.Target = hostname
+	return &result, nil
+}
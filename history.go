@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultHistoryPath = "./data/history.jsonl"
+
+// HistoryConfig sets where finished runs are persisted for trends
+type HistoryConfig struct {
+	Path string `yaml:"path"`
+}
+
+// HistoryRecord is one finished run and its own results, one per line of
+// the history file
+type HistoryRecord struct {
+	RunID      string         `json:"run_id"`
+	ParentID   string         `json:"parent_id,omitempty"`
+	Status     string         `json:"status"`
+	StartedBy  string         `json:"started_by"`
+	StartedAt  string         `json:"started_at"`
+	FinishedAt string         `json:"finished_at"`
+	Results    *BatchResponse `json:"results"`
+}
+
+// ===== Globals =====
+
+var historyMu sync.Mutex
+
+func historyPath() string {
+	if path := currentConfig().History.Path; path != "" {
+		return path
+	}
+	return defaultHistoryPath
+}
+
+// recordHistory appends a finished run to the history file
+func recordHistory(run Run, results *BatchResponse) {
+	line, err := json.Marshal(HistoryRecord{
+		RunID:      run.ID,
+		ParentID:   run.ParentID,
+		Status:     run.Status,
+		StartedBy:  run.StartedBy,
+		StartedAt:  run.StartedAt,
+		FinishedAt: run.FinishedAt,
+		Results:    results,
+	})
+	if err != nil {
+		logrus.Errorf("Failed to encode history for run %s: %v", run.ID, err)
+		return
+	}
+
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	path := historyPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		logrus.Errorf("Failed to create history directory: %v", err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		logrus.Errorf("Failed to open history %s: %v", path, err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		logrus.Errorf("Failed to write history %s: %v", path, err)
+	}
+}
+
+// readHistory calls fn for every run finished in [since, until), oldest
+// first; zero times leave that end open
+func readHistory(since, until time.Time, fn func(HistoryRecord, time.Time)) error {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	f, err := os.Open(historyPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var rec HistoryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil || rec.Results == nil {
+			continue
+		}
+		finished, err := time.Parse(time.RFC3339, rec.FinishedAt)
+		if err != nil {
+			continue
+		}
+		if (!since.IsZero() && finished.Before(since)) || (!until.IsZero() && !finished.Before(until)) {
+			continue
+		}
+		fn(rec, finished)
+	}
+	return scanner.Err()
+}
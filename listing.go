@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Sort keys for the drill-down endpoints
+const (
+	SortName      = "name"
+	SortScore     = "score"
+	SortStatus    = "status"
+	SortReadiness = "readiness"
+)
+
+const maxListLimit = 500
+
+// ListQuery filters, sorts and pages the drill-down endpoints. Zero fields
+// match everything; without limit or cursor every match is returned.
+type ListQuery struct {
+	// Status is "passed" or "failed"
+	Status string
+	// CheckID matches entities where that check did not pass
+	CheckID string
+	// Severity matches entities with a check of that severity that did not pass
+	Severity Severity
+	Tag      string
+	// Search matches entity keys (host\instance\database) case-insensitively
+	Search string
+	Sort   string
+	Desc   bool
+	Limit  int
+	Cursor *listCursor
+}
+
+// listCursor marks the last row of the previous page
+type listCursor struct {
+	Key   string `json:"k"`
+	Value string `json:"v"`
+}
+
+// listEntity is what a drill-down listing filters and sorts an entity by
+type listEntity struct {
+	// key is the entity's result key; it breaks ties so ordering is total
+	key     string
+	failed  bool
+	fitment Fitment
+	// checks are the results the entity's fitment is computed from
+	checks []CheckResult
+}
+
+// listRow is one entity of a drill-down listing and the item returned for it
+type listRow[T any] struct {
+	listEntity
+	item T
+}
+
+func statusLabel(failed bool) string {
+	if failed {
+		return "Failed"
+	}
+	return "Passed"
+}
+
+// parseListQuery reads status, check_id, severity, tag, q (search), sort
+// (name, score, status, readiness), order (asc, desc), limit and cursor
+func parseListQuery(c *gin.Context) (ListQuery, error) {
+	q := ListQuery{
+		Status:   strings.ToLower(c.Query("status")),
+		CheckID:  c.Query("check_id"),
+		Severity: Severity(strings.ToUpper(c.Query("severity"))),
+		Tag:      c.Query("tag"),
+		Search:   strings.ToLower(c.Query("q")),
+		Sort:     c.DefaultQuery("sort", SortName),
+	}
+	switch q.Status {
+	case "", "passed", "failed":
+	default:
+		return q, fmt.Errorf("status must be passed or failed")
+	}
+	if q.Severity != "" && !q.Severity.Valid() {
+		return q, fmt.Errorf("unknown severity %q", q.Severity)
+	}
+	switch q.Sort {
+	case SortName, SortScore, SortStatus, SortReadiness:
+	default:
+		return q, fmt.Errorf("sort must be name, score, status or readiness")
+	}
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return q, fmt.Errorf("order must be asc or desc")
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxListLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxListLimit)
+		}
+		q.Limit = n
+	}
+	if v := c.Query("cursor"); v != "" {
+		data, err := base64.RawURLEncoding.DecodeString(v)
+		var cur listCursor
+		if err != nil || json.Unmarshal(data, &cur) != nil {
+			return q, fmt.Errorf("invalid cursor")
+		}
+		q.Cursor = &cur
+		if q.Limit == 0 {
+			q.Limit = maxListLimit
+		}
+	}
+	return q, nil
+}
+
+// sortValue is the row's value for the query's sort key, zero-padded so
+// values compare as strings
+func (q ListQuery) sortValue(r listEntity) string {
+	switch q.Sort {
+	case SortScore:
+		return fmt.Sprintf("%03d", r.fitment.Score)
+	case SortStatus:
+		return statusLabel(r.failed)
+	case SortReadiness:
+		return strconv.Itoa(readinessRank(r.fitment.Readiness))
+	default:
+		return strings.ToLower(r.key)
+	}
+}
+
+func (q ListQuery) matches(r listEntity) bool {
+	if q.Status != "" && (q.Status == "failed") != r.failed {
+		return false
+	}
+	if q.Severity != "" && r.fitment.BySeverity[q.Severity] == 0 {
+		return false
+	}
+	if q.CheckID != "" {
+		found := false
+		for _, check := range r.checks {
+			if check.CheckID == q.CheckID && check.Status != StatusSuccess && check.Status != StatusInfo {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if q.Tag != "" {
+		host, _, _ := strings.Cut(r.key, "\\")
+		if !containsString(currentConfig().tagsForHost(host), q.Tag) {
+			return false
+		}
+	}
+	return q.Search == "" || strings.Contains(strings.ToLower(r.key), q.Search)
+}
+
+// before reports whether a sorts before b; the entity key breaks ties
+func (q ListQuery) before(aValue, aKey, bValue, bKey string) bool {
+	if aValue != bValue {
+		return (aValue < bValue) != q.Desc
+	}
+	return (aKey < bKey) != q.Desc
+}
+
+// applyListQuery filters and sorts rows and cuts the page after the cursor. It
+// returns the page, the number of matching rows and the cursor of the next
+// page, if any.
+func applyListQuery[T any](q ListQuery, rows []listRow[T]) ([]T, int, string) {
+	matched := rows[:0]
+	for _, r := range rows {
+		if q.matches(r.listEntity) {
+			matched = append(matched, r)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return q.before(q.sortValue(matched[i].listEntity), matched[i].key, q.sortValue(matched[j].listEntity), matched[j].key)
+	})
+
+	start := 0
+	if q.Cursor != nil {
+		start = sort.Search(len(matched), func(i int) bool {
+			return q.before(q.Cursor.Value, q.Cursor.Key, q.sortValue(matched[i].listEntity), matched[i].key)
+		})
+	}
+	end := len(matched)
+	if q.Limit > 0 && start+q.Limit < end {
+		end = start + q.Limit
+	}
+
+	page := make([]T, 0, end-start)
+	for _, r := range matched[start:end] {
+		page = append(page, r.item)
+	}
+	next := ""
+	if end < len(matched) {
+		last := matched[end-1]
+		data, _ := json.Marshal(listCursor{Key: last.key, Value: q.sortValue(last.listEntity)})
+		next = base64.RawURLEncoding.EncodeToString(data)
+	}
+	return page, len(matched), next
+}
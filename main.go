@@ -3,14 +3,15 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
 	"os/exec"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
@@ -28,23 +29,37 @@ type ComprehensiveResult struct {
 	InstanceChecks []CheckItem `json:"InstanceChecks"`
 	DatabaseChecks []CheckItem `json:"DatabaseChecks"`
 	ErrorMessage   string      `json:"ErrorMessage,omitempty"`
+	// Facts is the raw data collected by checks.ps1, evaluated by the rule engine
+	Facts *HostFacts `json:"Facts,omitempty"`
+	// Instances holds the rule results per instance, which replace the
+	// instance and database checks when present
+	Instances []InstanceResult `json:"-"`
 }
 
 type CheckItem struct {
-	Check    string `json:"Check"`
-	Status   string `json:"Status"`
-	Message  string `json:"Message"`
-	Severity string `json:"Severity"`
+	CheckID  string      `json:"CheckID,omitempty"`
+	Check    string      `json:"Check"`
+	Status   CheckStatus `json:"Status"`
+	Message  string      `json:"Message"`
+	Severity Severity    `json:"Severity"`
+	// Remediation is the rendered fix for a check that did not pass
+	Remediation string `json:"Remediation,omitempty"`
 }
 
 type CheckResult struct {
-	Check    string `json:"Check"`
-	Status   string `json:"Status"`
-	Message  string `json:"Message"`
-	Severity string `json:"Severity"`
+	CheckID  string      `json:"CheckID,omitempty"`
+	Check    string      `json:"Check"`
+	Status   CheckStatus `json:"Status"`
+	Message  string      `json:"Message"`
+	Severity Severity    `json:"Severity"`
+	// Remediation is the rendered fix for a check that did not pass
+	Remediation string `json:"Remediation,omitempty"`
 }
 
 type BatchResponse struct {
+	RunID           string                   `json:"run_id"`
+	Profile         string                   `json:"profile"`
+	ProfileVersion  string                   `json:"profile_version"`
 	Timestamp       string                   `json:"timestamp"`
 	Total           int                      `json:"total"`
 	Passed          int                      `json:"passed"`
@@ -53,6 +68,9 @@ type BatchResponse struct {
 	InstanceResults map[string][]CheckResult `json:"InstanceResults"`
 	DatabaseResults map[string][]CheckResult `json:"DatabaseResults"`
 	Summary         SummaryStats             `json:"Summary"`
+	// HostDetails holds each host's timing and raw facts for the detail
+	// endpoints; it is kept out of listings and history
+	HostDetails map[string]*HostDetail `json:"-"`
 }
 
 type SummaryStats struct {
@@ -63,43 +81,80 @@ type SummaryStats struct {
 	PassedChecks   int `json:"PassedChecks"`
 	FailedChecks   int `json:"FailedChecks"`
 	ErrorChecks    int `json:"ErrorChecks"`
+	// WarningChecks and InfoChecks did not pass, but the severity policy
+	// lets the entity pass
+	WarningChecks int `json:"WarningChecks"`
+	InfoChecks    int `json:"InfoChecks"`
+	// WaivedChecks failed but are covered by an active waiver; they are
+	// not counted in FailedChecks
+	WaivedChecks int `json:"WaivedChecks"`
 }
 
 type CheckRequest struct {
 	Hostnames string `json:"hostnames"`
+	// Profile names the rule profile to apply; the configured default if empty
+	Profile string `json:"profile"`
 }
 
 // ===== Globals =====
 
+// lastCheckResults is what the dashboards show: the latest run, filled in as
+// it goes, or the state a rerun or remediation of it merged since
 var lastCheckResults *BatchResponse
+var lastResultsMu sync.Mutex
 var processedVMs int
 var totalVMs int
 var progressMu sync.Mutex
 
 // ===== Worker Logic =====
 
-func worker(id int, jobs <-chan string, wg *sync.WaitGroup, response *BatchResponse,
+func worker(ctx context.Context, id int, profile *Profile, jobs <-chan string, wg *sync.WaitGroup, response *BatchResponse,
 	mu *sync.Mutex, totalChecks *int, passedChecks *int, failedChecks *int, errorChecks *int) {
 
 	defer wg.Done()
 	for hostname := range jobs {
-		logrus.Infof("Worker %d processing hostname: %s", id, hostname)
-
-		psResult, err := runPowerShellScript(hostname)
+		var psResult *ComprehensiveResult
+		var output string
+		var err error
+		started := time.Now()
+		if errors.Is(context.Cause(ctx), errInterrupted) {
+			err = fmt.Errorf("run interrupted before host was checked")
+		} else if ctx.Err() != nil {
+			err = fmt.Errorf("run cancelled before host was checked")
+		} else {
+			logrus.Infof("Worker %d processing hostname: %s", id, hostname)
+			var cred *Credential
+			if cred, err = resolveHostCredential(ctx, hostname); err == nil {
+				psResult, output, err = executorFor(hostname).Run(ctx, hostname, cred)
+			}
+		}
+		var sqlFacts []InstanceFacts
+		if sqlCfg := currentConfig().sqlConfigFor(hostname); sqlCfg != nil && ctx.Err() == nil {
+			sqlFacts = collectSQLFacts(ctx, hostname, sqlCfg)
+		}
+		detail := newHostDetail(hostname, started, psResult, output, sqlFacts, err)
+		detail.interrupted = err != nil && errors.Is(context.Cause(ctx), errInterrupted)
 
 		mu.Lock()
+		response.HostDetails[hostname] = detail
 		if err != nil {
-			logrus.Errorf("PowerShell execution failed for %s: %v", hostname, err)
+			logrus.Errorf("Check execution failed for %s: %v", hostname, err)
 			response.VMResults[hostname] = []CheckResult{{
 				Check:    "PowerShell Execution Policy",
-				Status:   "ERROR",
+				Status:   StatusError,
 				Message:  fmt.Sprintf("Failed to execute checks: %v", err),
-				Severity: "CRITICAL",
+				Severity: SeverityCritical,
 			}}
 			response.Failed++
 			*errorChecks++
 			*totalChecks++
+			sqlResult := &ComprehensiveResult{}
+			profile.evaluate(sqlResult, sqlFacts)
+			applyWaivers(hostname, sqlResult)
+			recordInstances(hostname, sqlResult.Instances, response, totalChecks, passedChecks, failedChecks, errorChecks)
 		} else {
+			profile.evaluate(psResult, sqlFacts)
+			applyWaivers(hostname, psResult)
 			processResults(hostname, psResult, response, totalChecks, passedChecks, failedChecks, errorChecks)
 		}
 
@@ -121,17 +176,24 @@ func processResults(hostname string, psResult *ComprehensiveResult, response *Ba
 			vmResults = append(vmResults, CheckResult(check))
 			*totalChecks++
 			switch check.Status {
-			case "SUCCESS":
+			case StatusSuccess:
 				*passedChecks++
-			case "FAILED":
+			case StatusFailed:
 				*failedChecks++
-			case "ERROR":
+			case StatusError:
 				*errorChecks++
 			}
 		}
 		response.VMResults[hostname] = vmResults
 	}
 
+	// Instance and database checks collected over TDS
+	hasFailure := false
+	if len(psResult.Instances) > 0 {
+		hasFailure = recordInstances(hostname, psResult.Instances, response, totalChecks, passedChecks, failedChecks, errorChecks)
+		psResult.InstanceChecks, psResult.DatabaseChecks = nil, nil
+	}
+
 	// Instance checks
 	if len(psResult.InstanceChecks) > 0 {
 		instanceResults := make([]CheckResult, 0, len(psResult.InstanceChecks))
@@ -139,11 +201,11 @@ func processResults(hostname string, psResult *ComprehensiveResult, response *Ba
 			instanceResults = append(instanceResults, CheckResult(check))
 			*totalChecks++
 			switch check.Status {
-			case "SUCCESS":
+			case StatusSuccess:
 				*passedChecks++
-			case "FAILED":
+			case StatusFailed:
 				*failedChecks++
-			case "ERROR":
+			case StatusError:
 				*errorChecks++
 			}
 		}
@@ -157,11 +219,11 @@ func processResults(hostname string, psResult *ComprehensiveResult, response *Ba
 			databaseResults = append(databaseResults, CheckResult(check))
 			*totalChecks++
 			switch check.Status {
-			case "SUCCESS":
+			case StatusSuccess:
 				*passedChecks++
-			case "FAILED":
+			case StatusFailed:
 				*failedChecks++
-			case "ERROR":
+			case StatusError:
 				*errorChecks++
 			}
 		}
@@ -169,21 +231,20 @@ func processResults(hostname string, psResult *ComprehensiveResult, response *Ba
 	}
 
 	// Passed/Failed decision
-	hasFailure := false
 	for _, check := range psResult.VMChecks {
-		if check.Status == "FAILED" || check.Status == "ERROR" {
+		if check.Status.Fails() {
 			hasFailure = true
 			break
 		}
 	}
 	for _, check := range psResult.InstanceChecks {
-		if check.Status == "FAILED" || check.Status == "ERROR" {
+		if check.Status.Fails() {
 			hasFailure = true
 			break
 		}
 	}
 	for _, check := range psResult.DatabaseChecks {
-		if check.Status == "FAILED" || check.Status == "ERROR" {
+		if check.Status.Fails() {
 			hasFailure = true
 			break
 		}
@@ -288,6 +349,62 @@ func handleCheck(c *gin.Context) {
 		hostnames[i] = strings.TrimSpace(hostnames[i])
 	}
 
+	profile, ok := currentConfig().profile(req.Profile)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown profile %q", req.Profile)})
+		return
+	}
+
+	access := accessFor(currentPrincipal(c))
+	if denied := access.deniedHosts(hostnames); len(denied) > 0 {
+		auditRequest(c, AuditEntry{
+			Action:  AuditRunStart,
+			Hosts:   hostnames,
+			Checks:  profile.checkIDs(),
+			Outcome: AuditOutcomeDenied,
+			Detail:  fmt.Sprintf("not permitted to target %v", denied),
+		})
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Not permitted to run checks against %v", denied)})
+		return
+	}
+
+	if !beginWork() {
+		rejectShuttingDown(c)
+		return
+	}
+	response := launchRun(c, hostnames, profile, nil)
+	c.JSON(http.StatusOK, CheckStartedResponse{Status: "started", RunID: response.RunID, Total: len(hostnames)})
+}
+
+// launchRun starts checking hostnames with profile in the background and
+// returns the run's response, which the workers fill in. A rerun's results
+// are merged into its parent's state once it finishes. The caller registers
+// the run with beginWork.
+// latestResults returns what the dashboards show
+func latestResults() *BatchResponse {
+	lastResultsMu.Lock()
+	defer lastResultsMu.Unlock()
+	return lastCheckResults
+}
+
+// setLatestResults makes r what the dashboards show
+func setLatestResults(r *BatchResponse) {
+	lastResultsMu.Lock()
+	defer lastResultsMu.Unlock()
+	lastCheckResults = r
+}
+
+// replaceLatestResults makes r what the dashboards show if they still show
+// old, the state r was merged onto
+func replaceLatestResults(old, r *BatchResponse) {
+	lastResultsMu.Lock()
+	defer lastResultsMu.Unlock()
+	if lastCheckResults == old {
+		lastCheckResults = r
+	}
+}
+
+func launchRun(c *gin.Context, hostnames []string, profile *Profile, rerun *Rerun) *BatchResponse {
 	logrus.Infof("Processing checks for %d hostnames: %v", len(hostnames), hostnames)
 
 	// Reset counters
@@ -297,7 +414,13 @@ func handleCheck(c *gin.Context) {
 	progressMu.Unlock()
 
 	// Init response struct
+	// The digest and webhooks compare the run with the latest finished
+	// results when it started; a rerun with the state it is merged onto
+	predecessor, _ := latestFinished()
 	response := &BatchResponse{
+		RunID:           newID(),
+		Profile:         profile.Name,
+		ProfileVersion:  profile.Version,
 		Timestamp:       time.Now().Format("2006-01-02 15:04:05"),
 		Total:           len(hostnames),
 		Passed:          0,
@@ -305,10 +428,32 @@ func handleCheck(c *gin.Context) {
 		VMResults:       make(map[string][]CheckResult),
 		InstanceResults: make(map[string][]CheckResult),
 		DatabaseResults: make(map[string][]CheckResult),
+		HostDetails:     make(map[string]*HostDetail),
+	}
+	// A rerun only covers part of the estate; the dashboards keep showing
+	// the previous state until it can be merged in
+	if rerun == nil {
+		setLatestResults(response)
 	}
-	lastCheckResults = response
+	run := startRun(response, hostnames, currentPrincipal(c).Subject, profile, rerun)
+	detail := fmt.Sprintf("profile %s@%s", profile.Name, profile.Version)
+	if rerun != nil {
+		detail += fmt.Sprintf(", rerun of %s (%s)", rerun.Parent.ID, rerun.Mode)
+	}
+	auditRequest(c, AuditEntry{
+		Action:         AuditRunStart,
+		RunID:          run.ID,
+		Hosts:          hostnames,
+		Checks:         profile.checkIDs(),
+		CredentialRefs: credentialRefsForHosts(hostnames),
+		Outcome:        AuditOutcomeSuccess,
+		Detail:         detail,
+	})
+	// Sent while the run holds its place in inflight, before it can finish
+	notifyWebhooks(EventRunStarted, response.RunID, gin.H{"hostnames": hostnames, "total": len(hostnames)})
 
 	go func() {
+		defer inflight.Done()
 		var totalChecks, passedChecks, failedChecks, errorChecks int
 		var mu sync.Mutex
 		jobs := make(chan string, len(hostnames))
@@ -318,7 +463,7 @@ func handleCheck(c *gin.Context) {
 		numWorkers := 10
 		wg.Add(numWorkers)
 		for w := 1; w <= numWorkers; w++ {
-			go worker(w, jobs, &wg, response, &mu, &totalChecks, &passedChecks, &failedChecks, &errorChecks)
+			go worker(run.ctx, w, profile, jobs, &wg, response, &mu, &totalChecks, &passedChecks, &failedChecks, &errorChecks)
 		}
 
 		// Send jobs
@@ -340,28 +485,53 @@ func handleCheck(c *gin.Context) {
 			PassedChecks:   passedChecks,
 			FailedChecks:   failedChecks,
 			ErrorChecks:    errorChecks,
+			WarningChecks:  countStatus(response, StatusWarning),
+			InfoChecks:     countStatus(response, StatusInfo),
+			WaivedChecks:   countStatus(response, StatusWaived),
 		}
 		mu.Unlock()
 
+		// Merge a rerun into its parent's state before the run shows as
+		// finished, so it can be rerun in turn
+		latest := response
+		if rerun != nil {
+			latest, predecessor = rerun.fold(run, response, hostnames)
+			replaceLatestResults(predecessor, latest)
+		}
+		// Hosts an interrupted run did not finish keep their previous state
+		checked := withoutInterruptedHosts(response)
+		recordState(checked)
+
+		finishRun(run)
+		recordFinished(latest, predecessor, rerun != nil)
+		recordHistory(run.snapshot(), checked)
+		writeAudit(AuditEntry{
+			Actor:   run.StartedBy,
+			Action:  AuditRunFinish,
+			RunID:   run.ID,
+			Hosts:   hostnames,
+			Outcome: run.snapshot().Status,
+			Detail:  fmt.Sprintf("%d passed, %d failed", response.Passed, response.Failed),
+		})
 		logrus.Infof("All workers finished. Summary ready for %d hosts.", len(hostnames))
+		notifyRunFinished(latest, predecessor)
+		if currentConfig().SMTP.SendAfterRun {
+			goWork(func() { sendDigestAsync(latest, predecessor) })
+		}
 	}()
-
-	c.JSON(http.StatusOK, gin.H{"status": "started", "total": len(hostnames)})
+	return response
 }
 
 func getProgress(c *gin.Context) {
 	progressMu.Lock()
 	defer progressMu.Unlock()
-	c.JSON(http.StatusOK, gin.H{
-		"processed": processedVMs,
-		"total":     totalVMs,
-	})
+	c.JSON(http.StatusOK, ProgressResponse{Processed: processedVMs, Total: totalVMs})
 }
 
 // ===== PowerShell runner =====
 
-func runPowerShellScript(hostname string) (*ComprehensiveResult, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+func runPowerShellScript(ctx context.Context, hostname string, cred *Credential) (*ComprehensiveResult, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, "powershell.exe",
@@ -369,90 +539,60 @@ func runPowerShellScript(hostname string) (*ComprehensiveResult, error) {
 		"-File", scriptPath,
 		"-ComputerName", hostname)
 
+	// Hand credentials to the child through its environment only, never argv,
+	// so they don't show up in process listings or logs.
+	if cred != nil {
+		cmd.Env = append(os.Environ(),
+			"NDB_PRECHECK_USERNAME="+cred.Username,
+			"NDB_PRECHECK_PASSWORD="+cred.Password)
+	}
+
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return nil, fmt.Errorf("PowerShell execution failed: %v, output: %s", err, string(output))
+		return nil, string(output), fmt.Errorf("PowerShell execution failed: %v, output: %s", err, string(output))
 	}
 
-	logrus.Infof("PowerShell raw output for %s: %s", hostname, string(output))
+	logrus.Debugf("PowerShell raw output for %s: %s", hostname, string(output))
 
 	var result ComprehensiveResult
 	if err := json.Unmarshal(output, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse PowerShell output: %v, raw output: %s", err, string(output))
+		return nil, string(output), fmt.Errorf("failed to parse PowerShell output: %v, raw output: %s", err, string(output))
 	}
-	return &result, nil
+	return &result, string(output), nil
 }
 
 // handleSummaryAPI returns summary data for the new UI
 func handleSummaryAPI(c *gin.Context) {
-	if lastCheckResults == nil {
+	last := latestResults()
+	if last == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "No check results available"})
 		return
 	}
 
+	vmTiers, instanceTiers, databaseTiers := readinessCounts(last)
+
 	// Transform data to match new UI expectations
-	summary := gin.H{
-		"summary": gin.H{
-			"entity_wise": gin.H{
-				"total_vms":        lastCheckResults.Summary.TotalServers,
-				"failed_vms":       lastCheckResults.Failed,
-				"total_instances":  lastCheckResults.Summary.TotalInstances,
-				"failed_instances": calculateFailedInstances(),
-				"total_databases":  lastCheckResults.Summary.TotalDatabases,
-				"failed_databases": calculateFailedDatabases(),
+	summary := SummaryResponse{
+		Summary: Summary{
+			EntityWise: EntityCounts{
+				TotalVMs:        last.Summary.TotalServers,
+				FailedVMs:       last.Failed,
+				TotalInstances:  last.Summary.TotalInstances,
+				FailedInstances: calculateFailedInstances(),
+				TotalDatabases:  last.Summary.TotalDatabases,
+				FailedDatabases: calculateFailedDatabases(),
+			},
+			Readiness: ReadinessSummary{
+				VMs:       vmTiers,
+				Instances: instanceTiers,
+				Databases: databaseTiers,
+				Groups:    groupReadiness(last),
 			},
-			"check_wise": []gin.H{
-				{
-					"type": "Database Server",
-					"categories": []gin.H{
-						{
-							"category_name": "VM Checks",
-							"passed":        countChecksByStatus("VM", "SUCCESS"),
-							"failed":        countChecksByStatus("VM", "FAILED") + countChecksByStatus("VM", "ERROR"),
-							"check": []gin.H{
-								{
-									"check_name": "PowerShell Execution Policy",
-									"passed":     countChecksByStatus("VM", "SUCCESS"),
-									"failed":     countChecksByStatus("VM", "FAILED") + countChecksByStatus("VM", "ERROR"),
-								},
-							},
-						},
-					},
-				},
-				{
-					"type": "Instance",
-					"categories": []gin.H{
-						{
-							"category_name": "Instance Checks",
-							"passed":        countChecksByStatus("Instance", "SUCCESS"),
-							"failed":        countChecksByStatus("Instance", "FAILED") + countChecksByStatus("Instance", "ERROR"),
-							"check": []gin.H{
-								{
-									"check_name": "Database Count Validation",
-									"passed":     countChecksByStatus("Instance", "SUCCESS"),
-									"failed":     countChecksByStatus("Instance", "FAILED") + countChecksByStatus("Instance", "ERROR"),
-								},
-							},
-						},
-					},
-				},
-				{
-					"type": "Database",
-					"categories": []gin.H{
-						{
-							"category_name": "Database Checks",
-							"passed":        countChecksByStatus("Database", "SUCCESS"),
-							"failed":        countChecksByStatus("Database", "FAILED") + countChecksByStatus("Database", "ERROR"),
-							"check": []gin.H{
-								{
-									"check_name": "Database State",
-									"passed":     countChecksByStatus("Database", "SUCCESS"),
-									"failed":     countChecksByStatus("Database", "FAILED") + countChecksByStatus("Database", "ERROR"),
-								},
-							},
-						},
-					},
-				},
+			SeverityWise: severityBreakdown(last.VMResults, last.InstanceResults, last.DatabaseResults),
+			CheckWise: []CheckTypeSummary{
+				checkTypeSummary("Database Server", "VM Checks", "VM", "PowerShell Execution Policy"),
+				checkTypeSummary("Instance", "Instance Checks", "Instance", "Database Count Validation"),
+				checkTypeSummary("Database", "Database Checks", "Database", "Database State"),
 			},
 		},
 	}
@@ -460,18 +600,43 @@ func handleSummaryAPI(c *gin.Context) {
 	c.JSON(http.StatusOK, summary)
 }
 
+// checkTypeSummary counts the outcomes of the checks in category
+func checkTypeSummary(entityType, categoryName, category, checkName string) CheckTypeSummary {
+	passed := countChecksByStatus(category, StatusSuccess)
+	failed := countChecksByStatus(category, StatusFailed) + countChecksByStatus(category, StatusError)
+	return CheckTypeSummary{
+		Type: entityType,
+		Categories: []CheckCategorySummary{{
+			CategoryName: categoryName,
+			Passed:       passed,
+			Failed:       failed,
+			Warnings:     countChecksByStatus(category, StatusWarning),
+			Check:        []CheckCountSummary{{CheckName: checkName, Passed: passed, Failed: failed}},
+		}},
+	}
+}
+
 // handleDBServersAPI returns database servers data for the new UI
+// (see parseListQuery for filtering, sorting and paging)
 func handleDBServersAPI(c *gin.Context) {
-	if lastCheckResults == nil {
+	last := latestResults()
+	if last == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "No check results available"})
 		return
 	}
 
-	var vms []gin.H
-	for hostname, vmChecks := range lastCheckResults.VMResults {
+	query, err := parseListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var rows []listRow[VMListItem]
+	for _, hostname := range sortedKeys(last.VMResults) {
+		vmChecks := last.VMResults[hostname]
 		hasFailure := false
 		for _, check := range vmChecks {
-			if check.Status == "FAILED" || check.Status == "ERROR" {
+			if check.Status.Fails() {
 				hasFailure = true
 				break
 			}
@@ -480,39 +645,34 @@ func handleDBServersAPI(c *gin.Context) {
 		// compute per-VM instance/database counts
 		instancesCount := 0
 		databasesCount := 0
-		for instanceName := range lastCheckResults.InstanceResults {
+		for instanceName := range last.InstanceResults {
 			if strings.HasPrefix(instanceName, hostname+"\\") {
 				instancesCount++
 			}
 		}
-		for dbName := range lastCheckResults.DatabaseResults {
+		for dbName := range last.DatabaseResults {
 			if strings.HasPrefix(dbName, hostname+"\\") {
 				databasesCount++
 			}
 		}
 
-		vm := gin.H{
-			"entity_name": hostname,
-			"type":        "Database VM",
-			"overall_fitment_status": gin.H{
-				"status": func() string {
-					if hasFailure {
-						return "Failed"
-					}
-					return "Passed"
-				}(),
-			},
-			"instances_count": instancesCount,
-			"databases_count": databasesCount,
-			"instances":       []gin.H{},
+		fitment := vmFitment(last, hostname)
+		vm := VMListItem{
+			EntityName:           hostname,
+			Type:                 "Database VM",
+			OverallFitmentStatus: fitmentStatus(hasFailure, fitment),
+			InstancesCount:       instancesCount,
+			DatabasesCount:       databasesCount,
+			Instances:            []InstanceListItem{},
 		}
 
 		// Add instances for this VM
-		for instanceName, instanceChecks := range lastCheckResults.InstanceResults {
+		for _, instanceName := range sortedKeys(last.InstanceResults) {
+			instanceChecks := last.InstanceResults[instanceName]
 			if strings.HasPrefix(instanceName, hostname+"\\") {
 				instHasFailure := false
 				for _, check := range instanceChecks {
-					if check.Status == "FAILED" || check.Status == "ERROR" {
+					if check.Status.Fails() {
 						instHasFailure = true
 						break
 					}
@@ -524,85 +684,82 @@ func handleDBServersAPI(c *gin.Context) {
 					shortInstance = instanceName[idx+1:]
 				}
 
-				// count DBs belonging to this VM (demo approximation)
+				// count DBs belonging to this instance
 				instDBCount := 0
-				for dbName := range lastCheckResults.DatabaseResults {
-					if strings.HasPrefix(dbName, hostname+"\\") {
+				for dbName := range last.DatabaseResults {
+					if databaseInInstance(dbName, instanceName) {
 						instDBCount++
 					}
 				}
 
-				instance := gin.H{
-					"entity_name": shortInstance,
-					"type":        "SQL Server Instance",
-					"overall_fitment_status": gin.H{
-						"status": func() string {
-							if instHasFailure {
-								return "Failed"
-							}
-							return "Passed"
-						}(),
-					},
-					"databases_count": instDBCount,
-					"databases":       []gin.H{},
+				instFitment := instanceFitment(last, instanceName)
+				instance := InstanceListItem{
+					EntityName:           shortInstance,
+					Type:                 "SQL Server Instance",
+					OverallFitmentStatus: fitmentStatus(instHasFailure, instFitment),
+					DatabasesCount:       instDBCount,
+					Databases:            []DatabaseListItem{},
 				}
 
 				// Add databases for this instance
-				for dbName, dbChecks := range lastCheckResults.DatabaseResults {
-					if strings.HasPrefix(dbName, hostname+"\\") {
+				for _, dbName := range sortedKeys(last.DatabaseResults) {
+					dbChecks := last.DatabaseResults[dbName]
+					if databaseInInstance(dbName, instanceName) {
 						dbHasFailure := false
 						for _, check := range dbChecks {
-							if check.Status == "FAILED" || check.Status == "ERROR" {
+							if check.Status.Fails() {
 								dbHasFailure = true
 								break
 							}
 						}
 
-						// strip VM prefix from DB name
-						shortDB := dbName
-						if idx := strings.Index(dbName, "\\"); idx != -1 {
-							shortDB = dbName[idx+1:]
-						}
+						// strip VM and instance prefix from DB name
+						_, _, shortDB := splitDatabaseKey(dbName)
 
-						database := gin.H{
-							"entity_name": shortDB,
-							"type":        "Database",
-							"overall_fitment_status": gin.H{
-								"status": func() string {
-									if dbHasFailure {
-										return "Failed"
-									}
-									return "Passed"
-								}(),
-							},
-						}
-
-						instance["databases"] = append(instance["databases"].([]gin.H), database)
+						instance.Databases = append(instance.Databases, DatabaseListItem{
+							EntityName:           shortDB,
+							Type:                 "Database",
+							OverallFitmentStatus: fitmentStatus(dbHasFailure, fitmentOf(dbChecks)),
+						})
 					}
 				}
 
-				vm["instances"] = append(vm["instances"].([]gin.H), instance)
+				vm.Instances = append(vm.Instances, instance)
 			}
 		}
 
-		vms = append(vms, vm)
+		var checks []CheckResult
+		hostChecks(last, hostname, func(results map[string][]CheckResult, key string) {
+			checks = append(checks, results[key]...)
+		})
+		rows = append(rows, listRow[VMListItem]{listEntity{key: hostname, failed: hasFailure, fitment: fitment, checks: checks}, vm})
 	}
 
-	c.JSON(http.StatusOK, gin.H{"vms": vms})
+	vms, total, next := applyListQuery(query, rows)
+	c.JSON(http.StatusOK, VMListResponse{VMs: vms, Total: total, NextCursor: next})
 }
 
 // handleInstancesAPI returns instances data for the new UI
+// (see parseListQuery for filtering, sorting and paging)
 func handleInstancesAPI(c *gin.Context) {
-	if lastCheckResults == nil {
+	last := latestResults()
+	if last == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "No check results available"})
 		return
 	}
 
-	var instances []gin.H
-	for instanceName, instanceChecks := range lastCheckResults.InstanceResults {
+	query, err := parseListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var rows []listRow[InstanceListItem]
+	for _, instanceName := range sortedKeys(last.InstanceResults) {
+		instanceChecks := last.InstanceResults[instanceName]
 		hasFailure := false
 		for _, check := range instanceChecks {
-			if check.Status == "FAILED" || check.Status == "ERROR" {
+			if check.Status.Fails() {
 				hasFailure = true
 				break
 			}
@@ -616,115 +773,105 @@ func handleInstancesAPI(c *gin.Context) {
 			shortInstance = instanceName[idx+1:]
 		}
 
-		// Build databases list for this instance (approximate by VM prefix)
-		var dbs []gin.H
+		// Build databases list for this instance
+		var dbs []DatabaseListItem
 		dbCount := 0
-		for dbName, dbChecks := range lastCheckResults.DatabaseResults {
-			if strings.HasPrefix(dbName, vmName+"\\") {
+		checks := append([]CheckResult(nil), instanceChecks...)
+		for _, dbName := range sortedKeys(last.DatabaseResults) {
+			dbChecks := last.DatabaseResults[dbName]
+			if databaseInInstance(dbName, vmName+"\\"+shortInstance) {
 				dbCount++
+				checks = append(checks, dbChecks...)
 
 				dbHasFailure := false
 				for _, check := range dbChecks {
-					if check.Status == "FAILED" || check.Status == "ERROR" {
+					if check.Status.Fails() {
 						dbHasFailure = true
 						break
 					}
 				}
 
-				shortDB := dbName
-				if idx := strings.Index(dbName, "\\"); idx != -1 {
-					shortDB = dbName[idx+1:]
-				}
+				_, _, shortDB := splitDatabaseKey(dbName)
 
-				dbs = append(dbs, gin.H{
-					"entity_name": shortDB,
-					"type":        "Database",
-					"overall_fitment_status": gin.H{
-						"status": func() string {
-							if dbHasFailure {
-								return "Failed"
-							}
-							return "Passed"
-						}(),
-					},
+				dbs = append(dbs, DatabaseListItem{
+					EntityName:           shortDB,
+					Type:                 "Database",
+					OverallFitmentStatus: fitmentStatus(dbHasFailure, fitmentOf(dbChecks)),
 				})
 			}
 		}
 
-		instance := gin.H{
-			"entity_name": shortInstance,
-			"type":        "SQL Server Instance",
-			"overall_fitment_status": gin.H{
-				"status": func() string {
-					if hasFailure {
-						return "Failed"
-					}
-					return "Passed"
-				}(),
-			},
-			"databases_count": dbCount,
-			"databases":       dbs,
+		fitment := instanceFitment(last, instanceName)
+		instance := InstanceListItem{
+			EntityName:           shortInstance,
+			Type:                 "SQL Server Instance",
+			OverallFitmentStatus: fitmentStatus(hasFailure, fitment),
+			DatabasesCount:       dbCount,
+			Databases:            dbs,
 		}
 
-		instances = append(instances, instance)
+		rows = append(rows, listRow[InstanceListItem]{listEntity{key: instanceName, failed: hasFailure, fitment: fitment, checks: checks}, instance})
 	}
 
-	c.JSON(http.StatusOK, gin.H{"instances": instances})
+	instances, total, next := applyListQuery(query, rows)
+	c.JSON(http.StatusOK, InstanceListResponse{Instances: instances, Total: total, NextCursor: next})
 }
 
 // handleDatabasesAPI returns databases data for the new UI
+// (see parseListQuery for filtering, sorting and paging)
 func handleDatabasesAPI(c *gin.Context) {
-	if lastCheckResults == nil {
+	last := latestResults()
+	if last == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "No check results available"})
 		return
 	}
 
-	var databases []gin.H
-	for dbName, dbChecks := range lastCheckResults.DatabaseResults {
+	query, err := parseListQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var rows []listRow[DatabaseListItem]
+	for _, dbName := range sortedKeys(last.DatabaseResults) {
+		dbChecks := last.DatabaseResults[dbName]
 		hasFailure := false
 		for _, check := range dbChecks {
-			if check.Status == "FAILED" || check.Status == "ERROR" {
+			if check.Status.Fails() {
 				hasFailure = true
 				break
 			}
 		}
 
 		// Strip VM prefix to show only database name and derive parent instance name
-		shortDB := dbName
-		if idx := strings.Index(dbName, "\\"); idx != -1 {
-			shortDB = dbName[idx+1:]
-		}
-
-		database := gin.H{
-			"entity_name": shortDB,
-			"type":        "Database",
-			"overall_fitment_status": gin.H{
-				"status": func() string {
-					if hasFailure {
-						return "Failed"
-					}
-					return "Passed"
-				}(),
-			},
-			"parent_instance": "MSSQLSERVER",
+		_, parentInstance, shortDB := splitDatabaseKey(dbName)
+
+		fitment := fitmentOf(dbChecks)
+		database := DatabaseListItem{
+			EntityName:           shortDB,
+			Type:                 "Database",
+			OverallFitmentStatus: fitmentStatus(hasFailure, fitment),
+			ParentInstance:       parentInstance,
 		}
 
-		databases = append(databases, database)
+		rows = append(rows, listRow[DatabaseListItem]{listEntity{key: dbName, failed: hasFailure, fitment: fitment, checks: dbChecks}, database})
 	}
 
-	c.JSON(http.StatusOK, gin.H{"databases": databases})
+	databases, total, next := applyListQuery(query, rows)
+	c.JSON(http.StatusOK, DatabaseListResponse{Databases: databases, Total: total, NextCursor: next})
 }
 
 // Helper functions
 func calculateFailedInstances() int {
-	if lastCheckResults == nil {
+	last := latestResults()
+	if last == nil {
 		return 0
 	}
 
 	failed := 0
-	for _, instanceChecks := range lastCheckResults.InstanceResults {
+	for _, instanceChecks := range last.InstanceResults {
 		for _, check := range instanceChecks {
-			if check.Status == "FAILED" || check.Status == "ERROR" {
+			if check.Status.Fails() {
 				failed++
 				break
 			}
@@ -734,14 +881,15 @@ func calculateFailedInstances() int {
 }
 
 func calculateFailedDatabases() int {
-	if lastCheckResults == nil {
+	last := latestResults()
+	if last == nil {
 		return 0
 	}
 
 	failed := 0
-	for _, dbChecks := range lastCheckResults.DatabaseResults {
+	for _, dbChecks := range last.DatabaseResults {
 		for _, check := range dbChecks {
-			if check.Status == "FAILED" || check.Status == "ERROR" {
+			if check.Status.Fails() {
 				failed++
 				break
 			}
@@ -750,8 +898,9 @@ func calculateFailedDatabases() int {
 	return failed
 }
 
-func countChecksByStatus(category, status string) int {
-	if lastCheckResults == nil {
+func countChecksByStatus(category string, status CheckStatus) int {
+	last := latestResults()
+	if last == nil {
 		return 0
 	}
 
@@ -760,11 +909,11 @@ func countChecksByStatus(category, status string) int {
 
 	switch category {
 	case "VM":
-		results = lastCheckResults.VMResults
+		results = last.VMResults
 	case "Instance":
-		results = lastCheckResults.InstanceResults
+		results = last.InstanceResults
 	case "Database":
-		results = lastCheckResults.DatabaseResults
+		results = last.DatabaseResults
 	default:
 		return 0
 	}
@@ -781,17 +930,47 @@ func countChecksByStatus(category, status string) int {
 }
 
 func main() {
+	// "ndb-precheck openapi" prints the API description; "ndb-precheck
+	// contract" checks it against the frozen v1 contract
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "openapi":
+			os.Stdout.Write(openAPIDocument())
+			return
+		case "contract":
+			os.Exit(checkContract(os.Args[2:]))
+		}
+	}
+
 	logrus.SetLevel(logrus.InfoLevel)
 	logrus.Info("Starting NDB PreCheck Service...")
 
+	cfg, err := loadConfig(configPath())
+	if err != nil {
+		logrus.Fatalf("Failed to load config: %v", err)
+	}
+	appConfig.Store(cfg)
+	initAuth()
+	startDigestScheduler()
+
+	credentialStore, err = openCredentialStore(cfg.Credentials.StorePath, cfg.Credentials.MasterKeyPath)
+	if err != nil {
+		logrus.Errorf("Credential store unavailable, hosts referencing credentials will fail: %v", err)
+	}
+	if err := initSecretProviders(); err != nil {
+		logrus.Fatalf("Failed to configure secret backends: %v", err)
+	}
+	waiverStore, err = openWaiverStore(cfg.Waivers.StorePath)
+	if err != nil {
+		logrus.Errorf("Waiver store unavailable, accepted failures will count as failed: %v", err)
+	}
+	stateStore, err = openStateStore(cfg.State.StorePath)
+	if err != nil {
+		logrus.Errorf("State store unavailable, latest known state will not be kept: %v", err)
+	}
+
 	router := gin.Default()
-	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"*"},
-		ExposeHeaders:    []string{"*"},
-		AllowCredentials: true,
-	}))
+	router.Use(corsMiddleware())
 
     // Serve React build
     router.Static("/assets", "./webapp/dist/assets")
@@ -805,18 +984,20 @@ func main() {
             c.Status(http.StatusNotFound)
             return
         }
+        if !requireUISession(c) {
+            return
+        }
         c.File("./webapp/dist/index.html")
     })
 
-	// APIs
-	router.POST("/api/check", handleCheck)
-	router.GET("/api/progress", getProgress)
+	// Login
+	router.GET("/auth/login", handleLogin)
+	router.GET("/auth/callback", handleCallback)
+	router.GET("/auth/logout", handleLogout)
 
-	router.GET("/api/summary", handleSummaryAPI)
-	router.GET("/api/dbservers", handleDBServersAPI)
-	router.GET("/api/instances", handleInstancesAPI)
-	router.GET("/api/databases", handleDatabasesAPI)
+	// APIs
+	registerAPI(router)
 
 	logrus.Infof("Server starting on port %s", port)
-	router.Run(port)
+	serve(router, port)
 }
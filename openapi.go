@@ -0,0 +1,420 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiEndpoint describes one REST endpoint under /api/v1. The same table
+// registers the routes and generates the OpenAPI document, so the two
+// cannot drift apart.
+type apiEndpoint struct {
+	Method string
+	// Path uses gin syntax, e.g. /runs/:id
+	Path string
+	// Role is the minimum role; RoleNone admits any authenticated caller
+	Role Role
+	// ID is the operationId, which names the generated client's method
+	ID      string
+	Summary string
+	Query   []apiParam
+	// Request and Response are zero values of the JSON bodies, nil if none
+	Request  any
+	Response any
+	// Status is the success status; 200 if zero
+	Status int
+	// Produces is the content type of a non-JSON success response
+	Produces string
+	Handler  gin.HandlerFunc
+}
+
+// apiParam is a query parameter
+type apiParam struct {
+	Name string
+	// Type is string or integer
+	Type        string
+	Description string
+}
+
+// listParams are the query parameters of the drill-down listings
+var listParams = []apiParam{
+	{"status", "string", "passed or failed"},
+	{"check_id", "string", "Entities where this check did not pass"},
+	{"severity", "string", "Entities with a check of this severity that did not pass"},
+	{"tag", "string", "Entities on hosts with this inventory tag"},
+	{"q", "string", "Substring of the entity key, case-insensitive"},
+	{"sort", "string", "name, score, status or readiness"},
+	{"order", "string", "asc or desc"},
+	{"limit", "integer", "Page size, at most 500"},
+	{"cursor", "string", "next_cursor of the previous page"},
+}
+
+var auditParams = []apiParam{
+	{"actor", "string", ""},
+	{"action", "string", ""},
+	{"run_id", "string", ""},
+	{"host", "string", ""},
+	{"since", "string", "RFC 3339"},
+	{"until", "string", "RFC 3339"},
+}
+
+// apiEndpoints lists every endpoint under /api/v1
+func apiEndpoints() []apiEndpoint {
+	return []apiEndpoint{
+		{Method: http.MethodGet, Path: "/me", ID: "getMe", Summary: "Describe the caller",
+			Response: MeResponse{}, Handler: handleMe},
+
+		{Method: http.MethodPost, Path: "/check", Role: RoleOperator, ID: "startCheck", Summary: "Start a run against hosts",
+			Request: CheckRequest{}, Response: CheckStartedResponse{}, Handler: handleCheck},
+		{Method: http.MethodGet, Path: "/progress", Role: RoleViewer, ID: "getProgress", Summary: "Progress of the latest run",
+			Response: ProgressResponse{}, Handler: getProgress},
+
+		{Method: http.MethodGet, Path: "/runs", Role: RoleViewer, ID: "listRuns", Summary: "List recent runs, newest first",
+			Response: RunListResponse{}, Handler: handleListRuns},
+		{Method: http.MethodGet, Path: "/runs/:id", Role: RoleViewer, ID: "getRun", Summary: "Get a run",
+			Response: RunResponse{}, Handler: handleGetRun},
+		{Method: http.MethodGet, Path: "/runs/:id/state", Role: RoleViewer, ID: "getRunState", Summary: "Latest known state of a run's hosts; a rerun's results merged into its parent's",
+			Response: RunStateResponse{}, Handler: handleRunState},
+		{Method: http.MethodGet, Path: "/runs/:id/vms/:host", Role: RoleViewer, ID: "getVMDetail", Summary: "Every check of a VM in a run, with its raw facts",
+			Response: EntityDetail{}, Handler: handleVMDetail},
+		{Method: http.MethodGet, Path: "/runs/:id/instances/:name", Role: RoleViewer, ID: "getInstanceDetail", Summary: "Every check of an instance in a run; name is host\\instance or a unique instance name",
+			Response: EntityDetail{}, Handler: handleInstanceDetail},
+		{Method: http.MethodGet, Path: "/runs/:id/databases/:name", Role: RoleViewer, ID: "getDatabaseDetail", Summary: "Every check of a database in a run; name is host\\instance\\database or a unique database name",
+			Response: EntityDetail{}, Handler: handleDatabaseDetail},
+		{Method: http.MethodPost, Path: "/runs/:id/cancel", Role: RoleOperator, ID: "cancelRun", Summary: "Cancel a running run",
+			Response: RunResponse{}, Handler: handleCancelRun},
+		{Method: http.MethodPost, Path: "/runs/:id/rerun", Role: RoleOperator, ID: "rerun", Summary: "Re-check failed or errored hosts, or selected checks, of a finished run",
+			Request: RerunRequest{}, Response: RerunStartedResponse{}, Handler: handleRerun},
+		{Method: http.MethodGet, Path: "/runs/:id/remediation", Role: RoleOperator, ID: "getRemediationScripts", Summary: "Remediation scripts of a finished run; with host, that host's script as text",
+			Query:    []apiParam{{"host", "string", "Return this host's script as a .ps1 download"}},
+			Response: RemediationScriptsResponse{}, Produces: "text/plain", Handler: handleRunRemediation},
+		{Method: http.MethodPost, Path: "/runs/:id/remediation/requests", Role: RoleOperator, ID: "requestRemediation", Summary: "Ask to apply the remediation of failed checks",
+			Request: CreateRemediationRequest{}, Response: RemediationResponse{}, Status: http.StatusCreated, Handler: handleRequestRemediation},
+		{Method: http.MethodPost, Path: "/remediations/:id/approve", Role: RoleAdmin, ID: "approveRemediation", Summary: "Approve and apply a remediation request",
+			Request: ReviewRemediationRequest{}, Response: RemediationResponse{}, Handler: handleReviewRemediation(true)},
+		{Method: http.MethodPost, Path: "/remediations/:id/reject", Role: RoleAdmin, ID: "rejectRemediation", Summary: "Reject a remediation request",
+			Request: ReviewRemediationRequest{}, Response: RemediationResponse{}, Handler: handleReviewRemediation(false)},
+
+		{Method: http.MethodGet, Path: "/profiles", Role: RoleViewer, ID: "listProfiles", Summary: "List the loaded rule profiles",
+			Response: ProfileListResponse{}, Handler: handleListProfiles},
+		{Method: http.MethodGet, Path: "/state", Role: RoleViewer, ID: "queryState", Summary: "Latest known state of entities",
+			Query: []apiParam{
+				{"type", "string", "vm, instance or database"},
+				{"host", "string", ""},
+				{"status", "string", "passed or failed"},
+				{"stale_for", "string", "Not checked for at least this long, e.g. 7d"},
+				{"checked_within", "string", "Checked at most this long ago, e.g. 12h"},
+			},
+			Response: StateResponse{}, Handler: handleStateQuery},
+		{Method: http.MethodGet, Path: "/trends", Role: RoleViewer, ID: "getTrends", Summary: "Check counts and readiness over time",
+			Query: []apiParam{
+				{"group", "string", ""},
+				{"tag", "string", ""},
+				{"check_id", "string", "Comma-separated check ids"},
+				{"level", "string", "vm, instance or database"},
+				{"bucket", "string", "day or week"},
+				{"since", "string", "RFC 3339 or YYYY-MM-DD"},
+				{"until", "string", "RFC 3339 or YYYY-MM-DD"},
+			},
+			Response: TrendsResponse{}, Handler: handleTrends},
+
+		{Method: http.MethodGet, Path: "/summary", Role: RoleViewer, ID: "getSummary", Summary: "Summary of the latest results",
+			Response: SummaryResponse{}, Handler: handleSummaryAPI},
+		{Method: http.MethodGet, Path: "/dbservers", Role: RoleViewer, ID: "listVMs", Summary: "VMs of the latest results",
+			Query: listParams, Response: VMListResponse{}, Handler: handleDBServersAPI},
+		{Method: http.MethodGet, Path: "/instances", Role: RoleViewer, ID: "listInstances", Summary: "Instances of the latest results",
+			Query: listParams, Response: InstanceListResponse{}, Handler: handleInstancesAPI},
+		{Method: http.MethodGet, Path: "/databases", Role: RoleViewer, ID: "listDatabases", Summary: "Databases of the latest results",
+			Query: listParams, Response: DatabaseListResponse{}, Handler: handleDatabasesAPI},
+		{Method: http.MethodGet, Path: "/digest/preview", Role: RoleViewer, ID: "previewDigest", Summary: "The digest of the latest results as HTML",
+			Produces: "text/html", Handler: handleDigestPreview},
+
+		{Method: http.MethodGet, Path: "/webhooks", Role: RoleAdmin, ID: "listWebhooks", Summary: "List the configured webhooks",
+			Response: WebhookListResponse{}, Handler: handleListWebhooks},
+		{Method: http.MethodGet, Path: "/webhooks/deliveries", Role: RoleAdmin, ID: "listWebhookDeliveries", Summary: "Webhook delivery log, newest first",
+			Query:    []apiParam{{"webhook", "string", "Only this webhook's deliveries"}},
+			Response: WebhookDeliveriesResponse{}, Handler: handleWebhookDeliveries},
+		{Method: http.MethodPost, Path: "/webhooks/:name/test", Role: RoleAdmin, ID: "testWebhook", Summary: "Send a ping event to a webhook",
+			Response: WebhookDeliveryResponse{}, Handler: handleTestWebhook},
+		{Method: http.MethodPost, Path: "/digest/send", Role: RoleAdmin, ID: "sendDigest", Summary: "Mail the digest of the latest results",
+			Response: DigestSentResponse{}, Handler: handleDigestSend},
+		{Method: http.MethodGet, Path: "/config", Role: RoleAdmin, ID: "getConfig", Summary: "The running configuration as YAML, secrets masked",
+			Produces: "application/yaml", Handler: handleGetConfig},
+		{Method: http.MethodPost, Path: "/config/reload", Role: RoleAdmin, ID: "reloadConfig", Summary: "Re-read the configuration file",
+			Response: StatusResponse{}, Handler: handleReloadConfig},
+		{Method: http.MethodGet, Path: "/audit", Role: RoleAdmin, ID: "queryAudit", Summary: "Audit entries, newest first",
+			Query:    append(auditParams, apiParam{"limit", "integer", "At most this many entries; 100 if unset"}),
+			Response: AuditResponse{}, Handler: handleAuditQuery},
+		{Method: http.MethodGet, Path: "/audit/export", Role: RoleAdmin, ID: "exportAudit", Summary: "Audit entries as JSON lines, oldest first",
+			Query: auditParams, Produces: "application/x-ndjson", Handler: handleAuditExport},
+		{Method: http.MethodGet, Path: "/credentials", Role: RoleAdmin, ID: "listCredentials", Summary: "List credentials without passwords",
+			Response: CredentialListResponse{}, Handler: handleListCredentials},
+		{Method: http.MethodPut, Path: "/credentials/:name", Role: RoleAdmin, ID: "putCredential", Summary: "Create or replace a credential",
+			Request: CredentialRequest{}, Response: CredentialResponse{}, Handler: handlePutCredential},
+		{Method: http.MethodDelete, Path: "/credentials/:name", Role: RoleAdmin, ID: "deleteCredential", Summary: "Delete a credential",
+			Response: StatusResponse{}, Handler: handleDeleteCredential},
+
+		{Method: http.MethodGet, Path: "/waivers", Role: RoleViewer, ID: "listWaivers", Summary: "List waivers and whether each is active",
+			Response: WaiverListResponse{}, Handler: handleListWaivers},
+		{Method: http.MethodPost, Path: "/waivers", Role: RoleAdmin, ID: "createWaiver", Summary: "Waive a check until a date",
+			Request: WaiverRequest{}, Response: WaiverResponse{}, Status: http.StatusCreated, Handler: handleCreateWaiver},
+		{Method: http.MethodDelete, Path: "/waivers/:id", Role: RoleAdmin, ID: "deleteWaiver", Summary: "Revoke a waiver",
+			Response: StatusResponse{}, Handler: handleDeleteWaiver},
+	}
+}
+
+// apiEnums lists the values of the named string types
+var apiEnums = map[reflect.Type][]string{
+	reflect.TypeOf(StatusSuccess): {
+		string(StatusSuccess), string(StatusInfo), string(StatusWarning),
+		string(StatusFailed), string(StatusError), string(StatusWaived),
+	},
+	reflect.TypeOf(SeverityInfo): func() []string {
+		values := make([]string, 0, len(severities))
+		for _, s := range severities {
+			values = append(values, string(s))
+		}
+		return values
+	}(),
+}
+
+// openAPISchemas builds JSON schemas from Go types. Named types of this
+// package become components; embedded structs are flattened like
+// encoding/json does. x-go-name and x-order keep the Go field names and
+// order for the client generator.
+type openAPISchemas struct {
+	defs map[string]any
+}
+
+var mainPkgPath = reflect.TypeOf(Run{}).PkgPath()
+
+func (s *openAPISchemas) ref(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.PkgPath() != mainPkgPath || t.Name() == "" {
+		return s.build(t)
+	}
+	if _, ok := s.defs[t.Name()]; !ok {
+		s.defs[t.Name()] = nil // placeholder while recursing
+		s.defs[t.Name()] = s.build(t)
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+}
+
+func (s *openAPISchemas) build(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Struct:
+		props := make(map[string]any)
+		var required []string
+		s.fields(t, props, &required)
+		schema := map[string]any{"type": "object", "properties": props}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	case reflect.String:
+		schema := map[string]any{"type": "string"}
+		if values, ok := apiEnums[t]; ok {
+			schema["enum"] = values
+		}
+		return schema
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": s.ref(t.Elem())}
+	case reflect.Map:
+		schema := map[string]any{"type": "object", "additionalProperties": s.ref(t.Elem())}
+		if k := t.Key(); k.PkgPath() == mainPkgPath && k.Name() != "" {
+			s.ref(k)
+			schema["x-go-key-type"] = k.Name()
+		}
+		return schema
+	case reflect.Pointer:
+		return s.ref(t.Elem())
+	}
+	// interface{} and anything else: any JSON value
+	return map[string]any{}
+}
+
+// fields adds the JSON fields of struct t to props
+func (s *openAPISchemas) fields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				s.fields(ft, props, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		field := map[string]any{"x-go-name": f.Name, "x-order": len(props)}
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.PkgPath() == mainPkgPath && ft.Name() != "" {
+			// $ref siblings are ignored by OpenAPI 3.0, so wrap it
+			field["allOf"] = []any{s.ref(ft)}
+		} else {
+			for k, v := range s.build(ft) {
+				field[k] = v
+			}
+		}
+		props[name] = field
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// openAPIPath turns /runs/:id into /runs/{id} and returns the path parameters
+func openAPIPath(path string) (string, []string) {
+	var params []string
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if name, ok := strings.CutPrefix(part, ":"); ok {
+			params = append(params, name)
+			parts[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(parts, "/"), params
+}
+
+// buildOpenAPI returns the OpenAPI 3 document of the endpoints
+func buildOpenAPI(endpoints []apiEndpoint) map[string]any {
+	schemas := &openAPISchemas{defs: make(map[string]any)}
+	errorSchema := schemas.ref(reflect.TypeOf(ErrorResponse{}))
+	jsonContent := func(schema map[string]any) map[string]any {
+		return map[string]any{"application/json": map[string]any{"schema": schema}}
+	}
+
+	paths := make(map[string]any)
+	for _, e := range endpoints {
+		path, pathParams := openAPIPath(e.Path)
+		var params []any
+		for _, name := range pathParams {
+			params = append(params, map[string]any{
+				"name": name, "in": "path", "required": true,
+				"schema": map[string]any{"type": "string"},
+			})
+		}
+		for _, q := range e.Query {
+			param := map[string]any{"name": q.Name, "in": "query", "schema": map[string]any{"type": q.Type}}
+			if q.Description != "" {
+				param["description"] = q.Description
+			}
+			params = append(params, param)
+		}
+
+		content := make(map[string]any)
+		if e.Response != nil {
+			content = jsonContent(schemas.ref(reflect.TypeOf(e.Response)))
+		}
+		if e.Produces != "" {
+			content[e.Produces] = map[string]any{"schema": map[string]any{"type": "string"}}
+		}
+		status := e.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		op := map[string]any{
+			"operationId": e.ID,
+			"summary":     e.Summary,
+			"tags":        []string{strings.Split(strings.TrimPrefix(e.Path, "/"), "/")[0]},
+			"x-role":      e.Role.String(),
+			"responses": map[string]any{
+				strconv.Itoa(status): map[string]any{"description": http.StatusText(status), "content": content},
+				"default":            map[string]any{"description": "Error", "content": jsonContent(errorSchema)},
+			},
+		}
+		if e.Role > RoleNone {
+			op["description"] = "Requires the " + e.Role.String() + " role."
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if e.Request != nil {
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content":  jsonContent(schemas.ref(reflect.TypeOf(e.Request))),
+			}
+		}
+		item, _ := paths[path].(map[string]any)
+		if item == nil {
+			item = make(map[string]any)
+			paths[path] = item
+		}
+		item[strings.ToLower(e.Method)] = op
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "NDB PreCheck Service",
+			"version":     "1.0.0",
+			"description": "Readiness checks of SQL Server hosts before onboarding them into NDB. The same endpoints under /api instead of /api/v1 are deprecated aliases.",
+		},
+		"servers":  []any{map[string]any{"url": "/api/" + apiVersion}},
+		"security": []any{map[string]any{"bearerAuth": []string{}}, map[string]any{"sessionCookie": []string{}}},
+		"paths":    paths,
+		"components": map[string]any{
+			"schemas": schemas.defs,
+			"securitySchemes": map[string]any{
+				"bearerAuth":    map[string]any{"type": "http", "scheme": "bearer"},
+				"sessionCookie": map[string]any{"type": "apiKey", "in": "cookie", "name": sessionCookie},
+			},
+		},
+	}
+}
+
+// ===== Globals =====
+
+var openAPIOnce sync.Once
+var openAPIJSON []byte
+
+// openAPIDocument returns the OpenAPI document as indented JSON
+func openAPIDocument() []byte {
+	openAPIOnce.Do(func() {
+		openAPIJSON, _ = json.MarshalIndent(buildOpenAPI(apiEndpoints()), "", "  ")
+		openAPIJSON = append(openAPIJSON, '\n')
+	})
+	return openAPIJSON
+}
+
+// ===== API Handlers =====
+
+// handleOpenAPI serves the OpenAPI document; it needs no authentication
+func handleOpenAPI(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", openAPIDocument())
+}
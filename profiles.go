@@ -0,0 +1,265 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultProfilesDir = "./profiles"
+	builtinProfileName = "default"
+)
+
+// NDB workflows a profile can target
+const (
+	WorkflowRegister  = "register"
+	WorkflowProvision = "provision"
+)
+
+// ProfilesConfig locates the rule profiles and picks the one used by default
+type ProfilesConfig struct {
+	Dir     string `yaml:"dir"`
+	Default string `yaml:"default"`
+}
+
+// Profile is a named, versioned set of fitment rules for one NDB release
+// and workflow, loaded from a YAML file in profiles.dir
+type Profile struct {
+	Name        string `yaml:"name" json:"name"`
+	Version     string `yaml:"version" json:"version"`
+	NDBVersion  string `yaml:"ndb_version" json:"ndb_version,omitempty"`
+	Workflow    string `yaml:"workflow" json:"workflow,omitempty"`
+	Description string `yaml:"description" json:"description,omitempty"`
+	// Thresholds are named limits rules refer to as thresholds.<name>;
+	// they override the built-in ones
+	Thresholds map[string]any `yaml:"thresholds" json:"thresholds"`
+	// Rules evaluate the collected facts. They replace the built-in rule
+	// with the same id (or scope and check), or add to them.
+	Rules  []Rule                 `yaml:"rules" json:"rules"`
+	Checks map[string]ProfileRule `yaml:"checks" json:"checks,omitempty"`
+	// SeverityPolicy decides the status of failing checks by severity,
+	// overriding the default (CRITICAL/HIGH fail, MEDIUM/LOW warn, INFO informs)
+	SeverityPolicy SeverityPolicy `yaml:"severity_policy" json:"severity_policy"`
+}
+
+// ProfileRule adjusts one check, keyed by its check name
+type ProfileRule struct {
+	// Enabled defaults to true; false drops the check from results
+	Enabled *bool `yaml:"enabled" json:"enabled,omitempty"`
+	// Severity replaces the severity reported when the check does not pass
+	Severity Severity `yaml:"severity" json:"severity,omitempty"`
+}
+
+// builtinProfile applies the built-in rules and thresholds
+func builtinProfile() *Profile {
+	p := &Profile{
+		Name:           builtinProfileName,
+		Version:        "builtin",
+		Description:    "Built-in fitment rules",
+		Thresholds:     builtinThresholds(),
+		Rules:          builtinRules(),
+		SeverityPolicy: defaultSeverityPolicy(),
+	}
+	for i := range p.Rules {
+		if err := p.Rules[i].compile(); err != nil {
+			panic(err)
+		}
+	}
+	return p
+}
+
+// loadProfiles reads every *.yaml/*.yml file in profiles.dir. A missing
+// directory leaves only the built-in profile.
+func (c *Config) loadProfiles() error {
+	dir := c.Profiles.Dir
+	if dir == "" {
+		dir = defaultProfilesDir
+	}
+	c.profiles = map[string]*Profile{builtinProfileName: builtinProfile()}
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return c.checkDefaultProfile()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read profiles dir %s: %v", dir, err)
+	}
+
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		p, err := loadProfile(path)
+		if err != nil {
+			return err
+		}
+		c.profiles[p.Name] = p
+	}
+	return c.checkDefaultProfile()
+}
+
+func (c *Config) checkDefaultProfile() error {
+	if c.Profiles.Default != "" && c.profiles[c.Profiles.Default] == nil {
+		return fmt.Errorf("profiles.default %q is not a known profile", c.Profiles.Default)
+	}
+	return nil
+}
+
+func loadProfile(path string) (*Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile %s: %v", path, err)
+	}
+	var p *Profile
+	if err := yaml.Unmarshal(data, &p); err != nil || p == nil {
+		return nil, fmt.Errorf("failed to parse profile %s: %v", path, err)
+	}
+	if p.Name == "" || p.Name == builtinProfileName {
+		return nil, fmt.Errorf("profile %s needs a name other than %q", path, builtinProfileName)
+	}
+	if p.Version == "" {
+		return nil, fmt.Errorf("profile %s needs a version", path)
+	}
+	switch p.Workflow {
+	case "", WorkflowRegister, WorkflowProvision:
+	default:
+		return nil, fmt.Errorf("profile %s: workflow must be register or provision, got %q", path, p.Workflow)
+	}
+	for check, rule := range p.Checks {
+		if rule.Severity != "" && !rule.Severity.Valid() {
+			return nil, fmt.Errorf("profile %s: check %q has unknown severity %q", path, check, rule.Severity)
+		}
+	}
+
+	if err := p.SeverityPolicy.validate(); err != nil {
+		return nil, fmt.Errorf("profile %s: %v", path, err)
+	}
+	policy := defaultSeverityPolicy()
+	for sev, status := range p.SeverityPolicy {
+		policy[sev] = status
+	}
+	p.SeverityPolicy = policy
+
+	thresholds := builtinThresholds()
+	for k, v := range p.Thresholds {
+		thresholds[k] = v
+	}
+	p.Thresholds = thresholds
+
+	rules := builtinRules()
+	index := make(map[string]int, 2*len(rules))
+	for i, r := range rules {
+		index[r.ID] = i
+		index[r.Scope+"/"+r.Check] = i
+	}
+	for _, rule := range p.Rules {
+		i, ok := index[rule.ID]
+		if rule.ID == "" {
+			i, ok = index[rule.Scope+"/"+rule.Check]
+		}
+		if ok {
+			if rule.ID == "" {
+				rule.ID = rules[i].ID
+			}
+			if rule.Remediation == nil {
+				rule.Remediation = rules[i].Remediation
+			}
+			rules[i] = rule
+		} else {
+			rules = append(rules, rule)
+		}
+	}
+	seen := make(map[string]bool)
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("profile %s: %v", path, err)
+		}
+		if seen[rules[i].ID] {
+			return nil, fmt.Errorf("profile %s: duplicate rule id %q", path, rules[i].ID)
+		}
+		seen[rules[i].ID] = true
+	}
+	p.Rules = rules
+	return p, nil
+}
+
+// profile returns the named profile, or the default one when name is empty
+func (c *Config) profile(name string) (*Profile, bool) {
+	if name == "" {
+		name = c.Profiles.Default
+	}
+	if name == "" {
+		name = builtinProfileName
+	}
+	p, ok := c.profiles[name]
+	return p, ok
+}
+
+// apply drops disabled checks, applies severity overrides to failing ones and
+// then lets the severity policy decide their status
+func (p *Profile) apply(result *ComprehensiveResult) {
+	result.VMChecks = p.filter(result.VMChecks)
+	result.InstanceChecks = p.filter(result.InstanceChecks)
+	result.DatabaseChecks = p.filter(result.DatabaseChecks)
+	for i := range result.Instances {
+		inst := &result.Instances[i]
+		inst.Checks = p.filter(inst.Checks)
+		for db, checks := range inst.Databases {
+			inst.Databases[db] = p.filter(checks)
+		}
+	}
+}
+
+// checkIDs returns the ids of the rules a run with the profile evaluates,
+// leaving out the checks it disables
+func (p *Profile) checkIDs() []string {
+	ids := make([]string, 0, len(p.Rules))
+	for _, rule := range p.Rules {
+		if adj, ok := p.Checks[rule.Check]; ok && adj.Enabled != nil && !*adj.Enabled {
+			continue
+		}
+		ids = append(ids, rule.ID)
+	}
+	return ids
+}
+
+func (p *Profile) filter(checks []CheckItem) []CheckItem {
+	out := checks[:0]
+	for _, check := range checks {
+		rule, ok := p.Checks[check.Check]
+		if ok && rule.Enabled != nil && !*rule.Enabled {
+			continue
+		}
+		if ok && rule.Severity != "" && check.Status != StatusSuccess {
+			check.Severity = rule.Severity
+		}
+		if check.Status == StatusFailed {
+			check.Status = p.SeverityPolicy.statusFor(check.Severity)
+		}
+		out = append(out, check)
+	}
+	return out
+}
+
+// ===== API Handlers =====
+
+// handleListProfiles returns the loaded rule profiles, sorted by name
+func handleListProfiles(c *gin.Context) {
+	cfg := currentConfig()
+	profiles := make([]*Profile, 0, len(cfg.profiles))
+	for _, p := range cfg.profiles {
+		profiles = append(profiles, p)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	def, _ := cfg.profile("")
+	c.JSON(http.StatusOK, ProfileListResponse{Profiles: profiles, Default: def.Name})
+}
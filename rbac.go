@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Role is a caller's permission level; higher roles include lower ones
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleViewer
+	RoleOperator
+	RoleAdmin
+)
+
+const (
+	groupScopePrefix = "group:"
+	allHostGroups    = "*"
+)
+
+// RBACConfig maps OIDC groups to roles and permitted host groups.
+// API tokens carry their role and host groups as scopes instead,
+// e.g. scopes: [operator, "group:wave-1"].
+type RBACConfig struct {
+	// DefaultRole applies to authenticated callers without a mapping
+	DefaultRole string        `yaml:"default_role"`
+	Mappings    []RoleMapping `yaml:"mappings"`
+}
+
+// RoleMapping grants Role on HostGroups to members of OIDCGroup
+type RoleMapping struct {
+	OIDCGroup  string   `yaml:"oidc_group"`
+	Role       string   `yaml:"role"`
+	HostGroups []string `yaml:"host_groups"`
+}
+
+// Access is the resolved role and host group permissions of a caller
+type Access struct {
+	Role       Role
+	HostGroups map[string]bool
+}
+
+func parseRole(s string) (Role, bool) {
+	switch strings.ToLower(s) {
+	case "viewer":
+		return RoleViewer, true
+	case "operator":
+		return RoleOperator, true
+	case "admin":
+		return RoleAdmin, true
+	case "", "none":
+		return RoleNone, true
+	}
+	return RoleNone, false
+}
+
+func (r Role) String() string {
+	switch r {
+	case RoleViewer:
+		return "viewer"
+	case RoleOperator:
+		return "operator"
+	case RoleAdmin:
+		return "admin"
+	}
+	return "none"
+}
+
+func (r RBACConfig) validate() error {
+	if _, ok := parseRole(r.DefaultRole); !ok {
+		return fmt.Errorf("rbac.default_role %q is not a role", r.DefaultRole)
+	}
+	for _, m := range r.Mappings {
+		if m.OIDCGroup == "" {
+			return fmt.Errorf("rbac mappings need an oidc_group")
+		}
+		if _, ok := parseRole(m.Role); !ok {
+			return fmt.Errorf("rbac mapping for %q has unknown role %q", m.OIDCGroup, m.Role)
+		}
+	}
+	return nil
+}
+
+// accessFor resolves what p may do from its token scopes or OIDC groups
+func accessFor(p *Principal) Access {
+	access := Access{HostGroups: make(map[string]bool)}
+	if p == nil {
+		return access
+	}
+	if p.Kind == "anonymous" {
+		// Only reachable with auth disabled.
+		access.Role = RoleAdmin
+		return access
+	}
+
+	grant := func(role Role, groups []string) {
+		if role > access.Role {
+			access.Role = role
+		}
+		for _, g := range groups {
+			access.HostGroups[g] = true
+		}
+	}
+
+	if p.Kind == "token" {
+		var groups []string
+		role := RoleNone
+		for _, scope := range p.Scopes {
+			if strings.HasPrefix(scope, groupScopePrefix) {
+				groups = append(groups, strings.TrimPrefix(scope, groupScopePrefix))
+			} else if r, ok := parseRole(scope); ok && r > role {
+				role = r
+			}
+		}
+		grant(role, groups)
+	} else {
+		for _, m := range currentConfig().RBAC.Mappings {
+			for _, g := range p.Groups {
+				if g == m.OIDCGroup {
+					role, _ := parseRole(m.Role)
+					grant(role, m.HostGroups)
+				}
+			}
+		}
+	}
+
+	if access.Role == RoleNone {
+		access.Role, _ = parseRole(currentConfig().RBAC.DefaultRole)
+	}
+	return access
+}
+
+// canTarget reports whether the caller may run checks against hostname
+func (a Access) canTarget(hostname string) bool {
+	if a.Role >= RoleAdmin {
+		return true
+	}
+	if a.Role < RoleOperator {
+		return false
+	}
+	return a.HostGroups[allHostGroups] || a.HostGroups[currentConfig().groupForHost(hostname)]
+}
+
+// deniedHosts returns the hostnames the caller may not target
+func (a Access) deniedHosts(hostnames []string) []string {
+	var denied []string
+	for _, h := range hostnames {
+		if !a.canTarget(h) {
+			denied = append(denied, h)
+		}
+	}
+	return denied
+}
+
+// requireRole rejects callers below min; it must run after requireAuth
+func requireRole(min Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if accessFor(currentPrincipal(c)).Role < min {
+			if c.Request.Method != http.MethodGet {
+				auditRequest(c, AuditEntry{
+					Action:  AuditAccessDenied,
+					Outcome: AuditOutcomeDenied,
+					Detail:  fmt.Sprintf("%s %s requires %s role", c.Request.Method, c.FullPath(), min),
+				})
+			}
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Requires %s role", min)})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Remediation is a rule's fix template. Each field is a text/template
+// rendered with the same names as the rule's Pass expression.
+type Remediation struct {
+	// Description says what the fix does; on its own it becomes a manual step
+	Description string `yaml:"description" json:"description"`
+	// Guard is a PowerShell expression that is true while the fix is still
+	// needed, so the generated script can be run more than once
+	Guard string `yaml:"guard" json:"guard,omitempty"`
+	// Script is the PowerShell that applies the fix. T-SQL runs through
+	// Invoke-Sql -Instance <name> -Variables @{ key = <value> } -Query <query>,
+	// where the query reads each value as @key.
+	Script string `yaml:"script" json:"script,omitempty"`
+
+	description *template.Template
+	guard       *template.Template
+	script      *template.Template
+}
+
+// remediationFuncs quote template values for PowerShell and T-SQL. Names
+// found on the host are better passed to Invoke-Sql -Variables than quoted
+// into a query: sqlident and sqlstring refuse line breaks, which could end
+// the here-string around the query.
+var remediationFuncs = template.FuncMap{
+	"psquote": psQuote,
+	// sqlident renders a bracket-quoted T-SQL identifier
+	"sqlident": func(v any) (string, error) {
+		s := fmt.Sprint(v)
+		if strings.ContainsAny(s, "\r\n") {
+			return "", fmt.Errorf("sqlident: name contains a line break")
+		}
+		return "[" + strings.ReplaceAll(s, "]", "]]") + "]", nil
+	},
+	// sqlstring renders a T-SQL Unicode string literal
+	"sqlstring": func(v any) (string, error) {
+		s := fmt.Sprint(v)
+		if strings.ContainsAny(s, "\r\n") {
+			return "", fmt.Errorf("sqlstring: value contains a line break")
+		}
+		return "N'" + strings.ReplaceAll(s, "'", "''") + "'", nil
+	},
+	// service returns the Windows service name of a SQL Server instance
+	"service": func(v any) string {
+		if name := fmt.Sprint(v); !strings.EqualFold(name, defaultSQLInstance) {
+			return "MSSQL$" + name
+		}
+		return defaultSQLInstance
+	},
+}
+
+// psQuote renders a PowerShell single-quoted string. PowerShell also takes
+// the typographic quotes U+2018-U+201B as single quotes, so they are doubled
+// the same way. Line breaks are joined in as [char] so the string stays on
+// one line, where it cannot end a here-string or be re-indented.
+func psQuote(v any) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	joined := false
+	for _, r := range fmt.Sprint(v) {
+		switch {
+		case r == '\r' || r == '\n':
+			fmt.Fprintf(&b, "' + [char]%d + '", r)
+			joined = true
+		case r == '\'' || r >= '\u2018' && r <= '\u201b':
+			b.WriteRune(r)
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('\'')
+	if joined {
+		return "(" + b.String() + ")"
+	}
+	return b.String()
+}
+
+// remediationPreamble defines the helpers every generated script relies on.
+// Scripts are dry runs unless started with -Apply.
+const remediationPreamble = `param([switch]$Apply)
+$ErrorActionPreference = 'Stop'
+
+# Invoke-Step applies Fix when Guard says it is still needed. Without -Apply
+# it only reports what it would do.
+function Invoke-Step([string]$Description, [scriptblock]$Guard, [scriptblock]$Fix) {
+    if ($Guard -and -not (& $Guard)) {
+        Write-Host "[ok]      $Description (already done)"
+        return
+    }
+    if (-not $Apply) {
+        Write-Host "[dry-run] $Description"
+        return
+    }
+    Write-Host "[apply]   $Description"
+    & $Fix
+}
+
+# Invoke-Sql runs a T-SQL batch against a local instance. Each Variables
+# entry is declared as @<key> ahead of the batch, so values found on the host
+# reach the query as data rather than as query text.
+function Invoke-Sql([string]$Instance, [string]$Query, [hashtable]$Variables = @{}) {
+    $server = if ($Instance -eq 'MSSQLSERVER') { '.' } else { ".\$Instance" }
+    $declare = foreach ($key in $Variables.Keys) {
+        $value = ([string]$Variables[$key]).Replace("'", "''")
+        $value = $value.Replace([string][char]13, "' + NCHAR(13) + N'").Replace([string][char]10, "' + NCHAR(10) + N'")
+        "DECLARE @$key nvarchar(max) = N'$value';"
+    }
+    $batch = (@($declare) + $Query) -join [char]10
+    Invoke-Sqlcmd -ServerInstance $server -Database master -Query $batch -DisableVariables -ErrorAction Stop
+}
+`
+
+// builtinRemediations are the fixes for the built-in rules, by rule id
+func builtinRemediations() map[string]*Remediation {
+	return map[string]*Remediation{
+		"vm.execution_policy": {
+			Description: "Set the PowerShell execution policy to RemoteSigned",
+			Guard:       "(Get-ExecutionPolicy -Scope LocalMachine) -eq 'Restricted'",
+			Script:      "Set-ExecutionPolicy -ExecutionPolicy RemoteSigned -Scope LocalMachine -Force",
+		},
+		"vm.disk_free_space": {
+			Description: "Free or add disk space so every fixed drive has at least {{.thresholds.min_free_disk_gb}} GB free",
+		},
+		"instance.service": {
+			Description: "Start SQL Server service {{service .name}} and set it to start automatically",
+			Guard:       "(Get-Service -Name {{psquote (service .name)}}).Status -ne 'Running'",
+			Script: "Set-Service -Name {{psquote (service .name)}} -StartupType Automatic\n" +
+				"Start-Service -Name {{psquote (service .name)}}",
+		},
+		"instance.connectivity": {
+			Description: "Check that instance {{.name}} accepts TCP connections and that the check login has access",
+		},
+		"instance.version": {
+			Description: "Upgrade instance {{.name}} to SQL Server major version {{.thresholds.min_sql_major_version}} or later",
+		},
+		"instance.database_count": {
+			Description: "Move or retire user databases on {{.name}} until at most {{.thresholds.max_user_databases}} remain",
+		},
+		"database.state": {
+			Description: "Bring database {{.name}} on {{.instance.name}} online",
+			Script: "Invoke-Sql -Instance {{psquote .instance.name}} -Variables @{ name = {{psquote .name}} } -Query @'\n" +
+				"IF EXISTS (SELECT 1 FROM sys.databases WHERE name = @name AND state_desc = 'OFFLINE')\n" +
+				"BEGIN\n" +
+				"    DECLARE @sql nvarchar(max) = N'ALTER DATABASE ' + QUOTENAME(@name) + N' SET ONLINE;';\n" +
+				"    EXEC (@sql);\n" +
+				"END\n" +
+				"'@",
+		},
+		"database.recovery_model": {
+			Description: "Switch database {{.name}} on {{.instance.name}} to the FULL recovery model and take a full backup",
+			Script: "Invoke-Sql -Instance {{psquote .instance.name}} -Variables @{ name = {{psquote .name}} } -Query @'\n" +
+				"IF EXISTS (SELECT 1 FROM sys.databases WHERE name = @name AND recovery_model_desc <> 'FULL')\n" +
+				"BEGIN\n" +
+				"    DECLARE @sql nvarchar(max) = N'ALTER DATABASE ' + QUOTENAME(@name) + N' SET RECOVERY FULL;';\n" +
+				"    EXEC (@sql);\n" +
+				"END\n" +
+				"'@\n" +
+				"# A full backup starts the log chain; point BACKUP DATABASE at your backup share.",
+		},
+		"database.size": {
+			Description: "Archive or purge data in {{.name}} on {{.instance.name}}, or raise max_database_size_mb in the profile",
+		},
+	}
+}
+
+// compile parses the remediation templates
+func (r *Remediation) compile(name string) error {
+	if r.Description == "" {
+		return fmt.Errorf("remediation needs a description")
+	}
+	var err error
+	parse := func(field, text string) *template.Template {
+		if err != nil || text == "" {
+			return nil
+		}
+		var t *template.Template
+		if t, err = template.New(name + " " + field).Funcs(remediationFuncs).Parse(text); err != nil {
+			err = fmt.Errorf("invalid remediation %s: %v", field, err)
+		}
+		return t
+	}
+	r.description = parse("description", r.Description)
+	r.guard = parse("guard", r.Guard)
+	r.script = parse("script", r.Script)
+	return err
+}
+
+// render produces the remediation step for one failing entity: an
+// Invoke-Step call, or a manual step comment when there is no script
+func (r *Remediation) render(env map[string]any) (string, error) {
+	execute := func(t *template.Template) (string, error) {
+		if t == nil {
+			return "", nil
+		}
+		var b strings.Builder
+		if err := t.Execute(&b, env); err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(b.String()), nil
+	}
+	description, err := execute(r.description)
+	if err != nil {
+		return "", err
+	}
+	guard, err := execute(r.guard)
+	if err != nil {
+		return "", err
+	}
+	script, err := execute(r.script)
+	if err != nil {
+		return "", err
+	}
+
+	if script == "" {
+		return "# MANUAL: " + commentText(description), nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Invoke-Step -Description %s", psQuote(description))
+	if guard != "" {
+		fmt.Fprintf(&b, " -Guard { %s }", guard)
+	}
+	fmt.Fprintf(&b, " -Fix {\n%s\n}", indent(script, "    "))
+	return b.String(), nil
+}
+
+// isAutomated reports whether a rendered remediation step can be applied by
+// the service, rather than being a manual step
+func isAutomated(step string) bool {
+	return strings.HasPrefix(step, "Invoke-Step ")
+}
+
+// commentText keeps text on one comment line; names from the host may hold
+// line breaks
+func commentText(text string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(text)
+}
+
+func indent(text, prefix string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		// Here-string bodies and terminators must stay at column 0
+		if line != "" && !strings.HasPrefix(line, "'@") && !insideHereString(lines[:i]) {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// insideHereString reports whether the lines so far leave a here-string open
+func insideHereString(lines []string) bool {
+	open := false
+	for _, line := range lines {
+		switch {
+		case strings.HasSuffix(line, "@'") || strings.HasSuffix(line, `@"`):
+			open = true
+		case strings.HasPrefix(line, "'@") || strings.HasPrefix(line, `"@`):
+			open = false
+		}
+	}
+	return open
+}
+
+// remediationScript consolidates the remediation steps for hostname's checks
+// that failed or warned in r, limited to those include accepts when it is not
+// nil. It returns the script and the number of checks it covers.
+func remediationScript(r *BatchResponse, hostname string, include func(entity string, check CheckResult) bool) (string, int) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Remediation for %s\n", hostname)
+	fmt.Fprintf(&b, "# Run %s, profile %s@%s, checked %s\n", r.RunID, r.Profile, r.ProfileVersion, r.Timestamp)
+	b.WriteString("# Review every step before running with -Apply. Steps are guarded and\n")
+	b.WriteString("# skip entities that are already fixed, so the script can be re-run.\n\n")
+	b.WriteString(remediationPreamble)
+
+	count := 0
+	section := func(entity string, checks []CheckResult) {
+		for _, check := range checks {
+			if check.Status != StatusFailed && check.Status != StatusWarning {
+				continue
+			}
+			if include != nil && !include(entity, check) {
+				continue
+			}
+			count++
+			fmt.Fprintf(&b, "\n# %s: %s [%s %s]\n", commentText(entity), check.Check, check.Status, check.Severity)
+			fmt.Fprintf(&b, "# Found: %s\n", commentText(check.Message))
+			if check.Remediation == "" {
+				b.WriteString("# No remediation template for this check.\n")
+				continue
+			}
+			b.WriteString(check.Remediation + "\n")
+		}
+	}
+
+	section(hostname, r.VMResults[hostname])
+	for _, key := range sortedKeys(r.InstanceResults) {
+		if strings.HasPrefix(key, hostname+"\\") {
+			section(key, r.InstanceResults[key])
+		}
+	}
+	for _, key := range sortedKeys(r.DatabaseResults) {
+		if strings.HasPrefix(key, hostname+"\\") {
+			section(key, r.DatabaseResults[key])
+		}
+	}
+	if count == 0 {
+		b.WriteString("\n# Nothing to remediate.\n")
+	}
+	return b.String(), count
+}
+
+func sortedKeys(m map[string][]CheckResult) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ===== API Handlers =====
+
+// handleRunRemediation returns a remediation script per host for a finished
+// run, limited to the hosts the caller may target. With ?host= it returns
+// that host's script as a .ps1 download.
+func handleRunRemediation(c *gin.Context) {
+	run := getRun(c.Param("id"))
+	if run == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Run not found"})
+		return
+	}
+	snap := run.snapshot()
+	if snap.FinishedAt == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Run has not finished"})
+		return
+	}
+	if run.results == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Run results not available"})
+		return
+	}
+
+	access := accessFor(currentPrincipal(c))
+	if host := c.Query("host"); host != "" {
+		host, ok := findFold(snap.Hostnames, host)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Host not in run"})
+			return
+		}
+		if !access.canTarget(host) {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Not permitted to remediate %s", host)})
+			return
+		}
+		script, _ := remediationScript(run.results, host, nil)
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="remediate-%s-%s.ps1"`, host, snap.ID))
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(script))
+		return
+	}
+
+	hosts := []HostRemediation{}
+	var omitted []string
+	for _, host := range snap.Hostnames {
+		if !access.canTarget(host) {
+			omitted = append(omitted, host)
+			continue
+		}
+		script, count := remediationScript(run.results, host, nil)
+		hosts = append(hosts, HostRemediation{Host: host, Checks: count, Script: script})
+	}
+	c.JSON(http.StatusOK, RemediationScriptsResponse{
+		RunID:        snap.ID,
+		Profile:      snap.Profile,
+		Hosts:        hosts,
+		OmittedHosts: omitted,
+	})
+}
+
+// findFold returns the item in list equal to s ignoring case
+func findFold(list []string, s string) (string, bool) {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return item, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Rerun modes
+const (
+	RerunFailedHosts  = "failed_hosts"
+	RerunErroredHosts = "errored_hosts"
+	RerunChecks       = "checks"
+)
+
+// Rerun describes a run that re-checks part of a previous run
+type Rerun struct {
+	Parent *Run
+	Mode   string
+	// CheckIDs limits a checks rerun to these rules
+	CheckIDs []string
+
+	// base is the state merged onto: the parent's latest known state when
+	// the rerun started, for picking hosts
+	base *BatchResponse
+}
+
+// latestState returns the latest known state of every host the run covers:
+// its results merged with those of the reruns and remediations of its
+// lineage, up to now for the run that started it and up to its own finish
+// for a rerun
+func (r *Run) latestState() *BatchResponse {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	if r.state != nil {
+		return r.state
+	}
+	return r.results
+}
+
+// only returns a copy of the profile limited to the rules with the given ids
+func (p *Profile) only(ids []string) (*Profile, error) {
+	out := *p
+	out.Rules = nil
+	for _, id := range ids {
+		found := false
+		for _, rule := range p.Rules {
+			if rule.ID == id {
+				out.Rules = append(out.Rules, rule)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("profile %s has no rule %q", p.Name, id)
+		}
+	}
+	return &out, nil
+}
+
+// resultHosts returns every host with results in r, sorted
+func resultHosts(r *BatchResponse) []string {
+	seen := make(map[string]bool)
+	for _, results := range []map[string][]CheckResult{r.VMResults, r.InstanceResults, r.DatabaseResults} {
+		for key := range results {
+			host, _, _ := strings.Cut(key, "\\")
+			seen[host] = true
+		}
+	}
+	hosts := make([]string, 0, len(seen))
+	for host := range seen {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// hostChecks calls fn with the entity key and checks of everything on hostname
+func hostChecks(r *BatchResponse, hostname string, fn func(results map[string][]CheckResult, key string)) {
+	for _, results := range []map[string][]CheckResult{r.VMResults, r.InstanceResults, r.DatabaseResults} {
+		for key := range results {
+			if key == hostname || strings.HasPrefix(key, hostname+"\\") {
+				fn(results, key)
+			}
+		}
+	}
+}
+
+// rerunHosts picks the hosts of base to check again: those with a failing
+// or errored check, or where any of checkIDs did not pass
+func rerunHosts(base *BatchResponse, mode string, checkIDs []string) []string {
+	var hosts []string
+	for _, host := range resultHosts(base) {
+		match := false
+		hostChecks(base, host, func(results map[string][]CheckResult, key string) {
+			for _, check := range results[key] {
+				switch mode {
+				case RerunFailedHosts:
+					match = match || check.Status.Fails()
+				case RerunErroredHosts:
+					match = match || check.Status == StatusError
+				case RerunChecks:
+					notPassed := check.Status != StatusSuccess && check.Status != StatusInfo
+					for _, id := range checkIDs {
+						match = match || (notPassed && check.CheckID == id)
+					}
+				}
+			}
+		})
+		if match {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// merge overlays the rerun's results on its base state. Host reruns replace
+// everything known about their hosts; checks reruns replace only the checks
+// they ran, keeping the rest of each entity's results.
+func (rr *Rerun) merge(results *BatchResponse, hostnames []string) *BatchResponse {
+	merged := &BatchResponse{
+		RunID:           results.RunID,
+		Profile:         results.Profile,
+		ProfileVersion:  results.ProfileVersion,
+		Timestamp:       results.Timestamp,
+		VMResults:       copyResults(rr.base.VMResults),
+		InstanceResults: copyResults(rr.base.InstanceResults),
+		DatabaseResults: copyResults(rr.base.DatabaseResults),
+	}
+
+	if rr.Mode != RerunChecks {
+		for _, host := range hostnames {
+			hostChecks(merged, host, func(results map[string][]CheckResult, key string) {
+				delete(results, key)
+			})
+		}
+	}
+	pairs := [][2]map[string][]CheckResult{
+		{merged.VMResults, results.VMResults},
+		{merged.InstanceResults, results.InstanceResults},
+		{merged.DatabaseResults, results.DatabaseResults},
+	}
+	for _, pair := range pairs {
+		into, from := pair[0], pair[1]
+		for key, checks := range from {
+			into[key] = mergeChecks(into[key], checks)
+		}
+	}
+
+	summarize(merged)
+	return merged
+}
+
+// fold merges a finished rerun's results onto the latest state of the run
+// its lineage started from, as that state is now rather than when the rerun
+// started, so reruns finishing in any order each add to it. The merge becomes
+// the latest state of both runs. It returns the merge and the state it
+// replaced.
+func (rr *Rerun) fold(run *Run, results *BatchResponse, hostnames []string) (merged, replaced *BatchResponse) {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	root := run
+	if run.root != nil {
+		root = run.root
+	}
+	replaced = root.state
+	if replaced == nil {
+		replaced = root.results
+	}
+	merged = (&Rerun{Mode: rr.Mode, CheckIDs: rr.CheckIDs, base: replaced}).merge(results, hostnames)
+	run.state, root.state = merged, merged
+	return merged, replaced
+}
+
+// mergeChecks replaces the checks in old that newer has a result for, by
+// check id, and appends the rest of newer
+func mergeChecks(old, newer []CheckResult) []CheckResult {
+	out := make([]CheckResult, 0, len(old)+len(newer))
+	used := make([]bool, len(newer))
+	for _, check := range old {
+		for i, n := range newer {
+			if !used[i] && n.CheckID == check.CheckID {
+				check, used[i] = n, true
+				break
+			}
+		}
+		out = append(out, check)
+	}
+	for i, n := range newer {
+		if !used[i] {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func copyResults(results map[string][]CheckResult) map[string][]CheckResult {
+	out := make(map[string][]CheckResult, len(results))
+	for key, checks := range results {
+		out[key] = append([]CheckResult(nil), checks...)
+	}
+	return out
+}
+
+// summarize recomputes the pass/fail counts and summary of r from its results
+func summarize(r *BatchResponse) {
+	hosts := resultHosts(r)
+	r.Total, r.Passed, r.Failed = len(hosts), 0, 0
+	for _, host := range hosts {
+		failed := false
+		hostChecks(r, host, func(results map[string][]CheckResult, key string) {
+			for _, check := range results[key] {
+				failed = failed || check.Status.Fails()
+			}
+		})
+		if failed {
+			r.Failed++
+		} else {
+			r.Passed++
+		}
+	}
+
+	r.Summary = SummaryStats{
+		TotalServers:   len(hosts),
+		TotalInstances: len(r.InstanceResults),
+		TotalDatabases: len(r.DatabaseResults),
+		PassedChecks:   countStatus(r, StatusSuccess),
+		FailedChecks:   countStatus(r, StatusFailed),
+		ErrorChecks:    countStatus(r, StatusError),
+		WarningChecks:  countStatus(r, StatusWarning),
+		InfoChecks:     countStatus(r, StatusInfo),
+		WaivedChecks:   countStatus(r, StatusWaived),
+	}
+	for _, results := range []map[string][]CheckResult{r.VMResults, r.InstanceResults, r.DatabaseResults} {
+		for _, checks := range results {
+			r.Summary.TotalChecks += len(checks)
+		}
+	}
+}
+
+// ===== API Handlers =====
+
+// handleRunState returns the latest known state of a finished run's hosts:
+// for a rerun, its results merged into those of the runs before it
+func handleRunState(c *gin.Context) {
+	run := getRun(c.Param("id"))
+	if run == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Run not found"})
+		return
+	}
+	snap := run.snapshot()
+	state := run.latestState()
+	if snap.FinishedAt == "" || state == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Run has not finished"})
+		return
+	}
+	c.JSON(http.StatusOK, RunStateResponse{RunID: snap.ID, ParentID: snap.ParentID, State: state})
+}
+
+// handleRerun starts a run that re-checks the failed or errored hosts of a
+// finished run, or only the given checks where they did not pass. Its
+// results are merged into the parent's latest known state as it is when the
+// rerun finishes.
+func handleRerun(c *gin.Context) {
+	parent := getRun(c.Param("id"))
+	if parent == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Run not found"})
+		return
+	}
+	snap := parent.snapshot()
+	base := parent.latestState()
+	if snap.FinishedAt == "" || base == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Run has not finished"})
+		return
+	}
+
+	var req RerunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	profile, ok := currentConfig().profile(snap.Profile)
+	if !ok {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Profile %q of the run is no longer loaded", snap.Profile)})
+		return
+	}
+	switch req.Mode {
+	case RerunFailedHosts, RerunErroredHosts:
+		req.CheckIDs = nil
+	case RerunChecks:
+		if len(req.CheckIDs) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "check_ids are required for mode checks"})
+			return
+		}
+		var err error
+		if profile, err = profile.only(req.CheckIDs); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be failed_hosts, errored_hosts or checks"})
+		return
+	}
+
+	hostnames := rerunHosts(base, req.Mode, req.CheckIDs)
+	if len(hostnames) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Nothing to rerun: no hosts match"})
+		return
+	}
+
+	access := accessFor(currentPrincipal(c))
+	if denied := access.deniedHosts(hostnames); len(denied) > 0 {
+		auditRequest(c, AuditEntry{
+			Action:  AuditRunStart,
+			Hosts:   hostnames,
+			Checks:  profile.checkIDs(),
+			Outcome: AuditOutcomeDenied,
+			Detail:  fmt.Sprintf("rerun of %s: not permitted to target %v", parent.ID, denied),
+		})
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Not permitted to run checks against %v", denied)})
+		return
+	}
+
+	if !beginWork() {
+		rejectShuttingDown(c)
+		return
+	}
+	rerun := &Rerun{Parent: parent, Mode: req.Mode, CheckIDs: req.CheckIDs, base: base}
+	response := launchRun(c, hostnames, profile, rerun)
+	c.JSON(http.StatusOK, RerunStartedResponse{
+		Status:    "started",
+		RunID:     response.RunID,
+		ParentID:  parent.ID,
+		Hostnames: hostnames,
+		Total:     len(hostnames),
+	})
+}
@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+// TestRerunsFinishingOutOfOrder checks reruns of one run all end up in its
+// latest state, and in the dashboards, whichever finishes first
+func TestRerunsFinishingOutOfOrder(t *testing.T) {
+	previousResults := latestResults()
+	t.Cleanup(func() { setLatestResults(previousResults) })
+
+	parent := startRun(vmBatch("parent", map[string]bool{"sql01": true, "sql02": true, "sql03": true}),
+		[]string{"sql01", "sql02", "sql03"}, "seed", builtinProfile(), nil)
+	finishRun(parent)
+	setLatestResults(parent.results)
+
+	rerun := func(of *Run, host string) (*Rerun, *Run) {
+		rr := &Rerun{Parent: of, Mode: RerunFailedHosts, base: of.latestState()}
+		return rr, startRun(vmBatch("rerun-"+host, map[string]bool{host: false}), []string{host}, "seed", builtinProfile(), rr)
+	}
+	finish := func(rr *Rerun, run *Run) *BatchResponse {
+		merged, replaced := rr.fold(run, run.results, run.Hostnames)
+		replaceLatestResults(replaced, merged)
+		return replaced
+	}
+	first, firstRun := rerun(parent, "sql01")
+	second, secondRun := rerun(parent, "sql02")
+
+	// The later rerun finishes first
+	if replaced := finish(second, secondRun); replaced != parent.results {
+		t.Errorf("first rerun to finish replaced %s, want the parent's results", replaced.RunID)
+	}
+	// A rerun of a rerun folds into the same lineage
+	third, thirdRun := rerun(secondRun, "sql03")
+	finish(first, firstRun)
+	if got := firstRun.latestState().Failed; got != 1 {
+		t.Errorf("state after the second rerun has %d failing, want only sql03", got)
+	}
+	finish(third, thirdRun)
+
+	for _, run := range []*Run{parent, thirdRun} {
+		if state := run.latestState(); state.Failed != 0 || state.Passed != 3 {
+			t.Errorf("%s state: %d passed, %d failed, want all 3 passing", run.ID, state.Passed, state.Failed)
+		}
+	}
+	if got := firstRun.latestState().Failed; got != 1 {
+		t.Errorf("a finished rerun's state changed: %d failing", got)
+	}
+	if got := latestResults(); got != parent.latestState() {
+		t.Errorf("dashboards show %d failing, want the state after every rerun", got.Failed)
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// Rule scopes: which level of facts a rule is evaluated against
+const (
+	ScopeVM       = "vm"
+	ScopeInstance = "instance"
+	ScopeDatabase = "database"
+)
+
+// HostFacts is the raw data collected from a host by checks.ps1 and the TDS
+// checks. Fact maps are keyed by snake_case fact names.
+type HostFacts struct {
+	VM        map[string]any  `json:"vm"`
+	Instances []InstanceFacts `json:"instances"`
+}
+
+// InstanceFacts holds the facts of one SQL Server instance
+type InstanceFacts struct {
+	Name      string          `json:"name"`
+	Facts     map[string]any  `json:"facts"`
+	Databases []DatabaseFacts `json:"databases"`
+}
+
+// DatabaseFacts holds the facts of one user database
+type DatabaseFacts struct {
+	Name  string         `json:"name"`
+	Facts map[string]any `json:"facts"`
+}
+
+// Rule turns facts into one check result. Expressions use expr syntax
+// (https://expr-lang.org) and see the scope's facts as top-level names,
+// plus thresholds, vm and, for databases, instance.
+type Rule struct {
+	// ID identifies the check across profiles and runs, e.g. for waivers;
+	// derived from scope and check name if empty
+	ID    string `yaml:"id" json:"id"`
+	Check string `yaml:"check" json:"check"`
+	Scope string `yaml:"scope" json:"scope"`
+	// When limits the rule to entities where it evaluates true, e.g. to
+	// skip rules whose facts were not collected; always applies if empty
+	When string `yaml:"when" json:"when,omitempty"`
+	// Pass must evaluate true for the check to succeed
+	Pass string `yaml:"pass" json:"pass"`
+	// Message is a text/template rendered with the same names as Pass
+	Message string `yaml:"message" json:"message,omitempty"`
+	// Severity of a failure, which the profile's severity policy turns into
+	// FAILED, WARNING or INFO; CRITICAL if empty
+	Severity Severity `yaml:"severity" json:"severity,omitempty"`
+	// Remediation is the fix offered when the check does not pass
+	Remediation *Remediation `yaml:"remediation" json:"remediation,omitempty"`
+
+	when    *vm.Program
+	pass    *vm.Program
+	message *template.Template
+}
+
+// builtinRules reproduce the checks the script used to decide on its own
+func builtinRules() []Rule {
+	rules := []Rule{
+		{
+			ID:       "vm.execution_policy",
+			Check:    "PowerShell Execution Policy",
+			Scope:    ScopeVM,
+			When:     "execution_policy != nil",
+			Pass:     `execution_policy != "Restricted"`,
+			Message:  "{{.execution_policy}}",
+			Severity: SeverityCritical,
+		},
+		{
+			ID:       "vm.disk_free_space",
+			Check:    "Disk Free Space",
+			Scope:    ScopeVM,
+			When:     "disks != nil",
+			Pass:     "all(disks, .free_gb >= thresholds.min_free_disk_gb)",
+			Message:  `{{range .disks}}{{.drive}} {{printf "%.1f" .free_gb}} GB free; {{end}}(minimum: {{.thresholds.min_free_disk_gb}} GB)`,
+			Severity: SeverityHigh,
+		},
+		{
+			ID:       "instance.service",
+			Check:    "SQL Server Service",
+			Scope:    ScopeInstance,
+			When:     "service_status != nil",
+			Pass:     `service_status == "Running"`,
+			Message:  "Service status: {{.service_status}}",
+			Severity: SeverityCritical,
+		},
+		{
+			ID:       "instance.connectivity",
+			Check:    "SQL Server Connectivity",
+			Scope:    ScopeInstance,
+			When:     "connected != nil",
+			Pass:     "connected",
+			Message:  "{{if .connected}}Connected to {{.name}}{{else}}{{.error}}{{end}}",
+			Severity: SeverityCritical,
+		},
+		{
+			ID:       "instance.version",
+			Check:    "SQL Server Version",
+			Scope:    ScopeInstance,
+			When:     "major_version != nil",
+			Pass:     "major_version >= thresholds.min_sql_major_version",
+			Message:  "SQL Server {{.version}} {{.edition}} ({{.product_level}})",
+			Severity: SeverityCritical,
+		},
+		{
+			ID:       "instance.database_count",
+			Check:    "Database Count Validation",
+			Scope:    ScopeInstance,
+			When:     "user_database_count != nil",
+			Pass:     "user_database_count <= thresholds.max_user_databases",
+			Message:  "User databases found: {{.user_database_count}} (limit: {{.thresholds.max_user_databases}})",
+			Severity: SeverityCritical,
+		},
+		{
+			ID:       "database.state",
+			Check:    "Database State",
+			Scope:    ScopeDatabase,
+			Pass:     `state == "ONLINE"`,
+			Message:  "Database state: {{.state}}",
+			Severity: SeverityCritical,
+		},
+		{
+			ID:       "database.recovery_model",
+			Check:    "Database Recovery Model",
+			Scope:    ScopeDatabase,
+			When:     "recovery_model != nil",
+			Pass:     `recovery_model != "SIMPLE"`,
+			Message:  "Recovery model: {{.recovery_model}}",
+			Severity: SeverityLow,
+		},
+		{
+			ID:       "database.size",
+			Check:    "Database Size",
+			Scope:    ScopeDatabase,
+			When:     "data_mb != nil",
+			Pass:     "data_mb + log_mb <= thresholds.max_database_size_mb",
+			Message:  `Data: {{printf "%.1f" .data_mb}} MB, Log: {{printf "%.1f" .log_mb}} MB`,
+			Severity: SeverityMedium,
+		},
+	}
+	remediations := builtinRemediations()
+	for i := range rules {
+		rules[i].Remediation = remediations[rules[i].ID]
+	}
+	return rules
+}
+
+// builtinThresholds are the limits rules use unless a profile overrides them
+func builtinThresholds() map[string]any {
+	return map[string]any{
+		"max_user_databases":    150,
+		"min_sql_major_version": 13, // SQL Server 2016
+		"min_free_disk_gb":      10,
+		"max_database_size_mb":  2 * 1024 * 1024,
+	}
+}
+
+// compile parses the rule's expressions and message template
+func (r *Rule) compile() error {
+	switch r.Scope {
+	case ScopeVM, ScopeInstance, ScopeDatabase:
+	default:
+		return fmt.Errorf("rule %q: scope must be vm, instance or database, got %q", r.Check, r.Scope)
+	}
+	if r.Check == "" || r.Pass == "" {
+		return fmt.Errorf("rules need check and pass")
+	}
+	if r.Severity != "" && !r.Severity.Valid() {
+		return fmt.Errorf("rule %q has unknown severity %q", r.Check, r.Severity)
+	}
+	if r.ID == "" {
+		r.ID = r.Scope + "." + strings.ToLower(strings.Join(strings.Fields(r.Check), "_"))
+	}
+
+	var err error
+	if r.When != "" {
+		if r.when, err = expr.Compile(r.When, expr.AsBool()); err != nil {
+			return fmt.Errorf("rule %q: invalid when: %v", r.Check, err)
+		}
+	}
+	if r.pass, err = expr.Compile(r.Pass, expr.AsBool()); err != nil {
+		return fmt.Errorf("rule %q: invalid pass: %v", r.Check, err)
+	}
+	if r.message, err = template.New(r.Check).Parse(r.Message); err != nil {
+		return fmt.Errorf("rule %q: invalid message: %v", r.Check, err)
+	}
+	if r.Remediation != nil {
+		if err := r.Remediation.compile(r.ID); err != nil {
+			return fmt.Errorf("rule %q: %v", r.Check, err)
+		}
+	}
+	return nil
+}
+
+// evaluate runs the rule against env. ok is false when When excludes it.
+func (r *Rule) evaluate(env map[string]any) (item CheckItem, ok bool) {
+	item = CheckItem{CheckID: r.ID, Check: r.Check, Severity: r.Severity}
+	if item.Severity == "" {
+		item.Severity = SeverityCritical
+	}
+
+	if r.when != nil {
+		applies, err := expr.Run(r.when, env)
+		if err != nil {
+			item.Status, item.Message = StatusError, fmt.Sprintf("rule condition failed: %v", err)
+			return item, true
+		}
+		if applies != true {
+			return item, false
+		}
+	}
+
+	passed, err := expr.Run(r.pass, env)
+	if err != nil {
+		item.Status, item.Message = StatusError, fmt.Sprintf("rule evaluation failed: %v", err)
+		return item, true
+	}
+
+	var msg strings.Builder
+	if err := r.message.Execute(&msg, env); err != nil {
+		msg.Reset()
+		fmt.Fprintf(&msg, "failed to render message: %v", err)
+	}
+	item.Message = msg.String()
+	if passed == true {
+		item.Status, item.Severity = StatusSuccess, SeverityInfo
+	} else {
+		item.Status = StatusFailed
+		if r.Remediation != nil {
+			if item.Remediation, err = r.Remediation.render(env); err != nil {
+				item.Remediation = fmt.Sprintf("# Failed to render remediation: %v", err)
+			}
+		}
+	}
+	return item, true
+}
+
+// evaluate replaces result's checks with those the rules derive from its
+// facts. sqlFacts, when collected over TDS, replace the script's instances.
+// Without facts (the script failed) the script's own error checks are kept.
+func (p *Profile) evaluate(result *ComprehensiveResult, sqlFacts []InstanceFacts) {
+	facts := &HostFacts{}
+	if result.Facts != nil {
+		facts = result.Facts
+		result.VMChecks, result.InstanceChecks, result.DatabaseChecks = nil, nil, nil
+	}
+	if sqlFacts != nil {
+		facts.Instances = sqlFacts
+	}
+	vmChecks, instances := p.evaluateFacts(facts)
+	result.VMChecks = append(result.VMChecks, vmChecks...)
+	result.Instances = instances
+	p.apply(result)
+}
+
+// evaluateFacts applies the profile's rules to facts, producing the VM checks
+// and per-instance results
+func (p *Profile) evaluateFacts(facts *HostFacts) ([]CheckItem, []InstanceResult) {
+	thresholds := p.Thresholds
+	vmFacts := facts.VM
+	if vmFacts == nil {
+		vmFacts = map[string]any{}
+	}
+
+	var vmChecks []CheckItem
+	if facts.VM != nil {
+		vmChecks = p.evaluateScope(ScopeVM, withEnv(vmFacts, thresholds, nil, nil))
+	}
+
+	instances := make([]InstanceResult, 0, len(facts.Instances))
+	for _, inst := range facts.Instances {
+		instFacts := withName(inst.Facts, inst.Name)
+		result := InstanceResult{
+			Name:      inst.Name,
+			Checks:    p.evaluateScope(ScopeInstance, withEnv(instFacts, thresholds, vmFacts, nil)),
+			Databases: make(map[string][]CheckItem),
+		}
+		for _, db := range inst.Databases {
+			dbFacts := withName(db.Facts, db.Name)
+			result.Databases[db.Name] = p.evaluateScope(ScopeDatabase, withEnv(dbFacts, thresholds, vmFacts, instFacts))
+		}
+		instances = append(instances, result)
+	}
+	return vmChecks, instances
+}
+
+func (p *Profile) evaluateScope(scope string, env map[string]any) []CheckItem {
+	var checks []CheckItem
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.Scope != scope {
+			continue
+		}
+		if item, ok := rule.evaluate(env); ok {
+			checks = append(checks, item)
+		}
+	}
+	return checks
+}
+
+func withName(facts map[string]any, name string) map[string]any {
+	out := make(map[string]any, len(facts)+1)
+	for k, v := range facts {
+		out[k] = v
+	}
+	out["name"] = name
+	return out
+}
+
+// withEnv builds the expression environment: the scope's facts at the top
+// level plus thresholds and the enclosing vm and instance facts
+func withEnv(facts, thresholds, vmFacts, instFacts map[string]any) map[string]any {
+	env := make(map[string]any, len(facts)+3)
+	for k, v := range facts {
+		env[k] = v
+	}
+	env["thresholds"] = thresholds
+	if vmFacts != nil {
+		env["vm"] = vmFacts
+	}
+	if instFacts != nil {
+		env["instance"] = instFacts
+	}
+	return env
+}
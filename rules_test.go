@@ -0,0 +1,378 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func builtinRule(t *testing.T, p *Profile, id string) *Rule {
+	t.Helper()
+	for i := range p.Rules {
+		if p.Rules[i].ID == id {
+			return &p.Rules[i]
+		}
+	}
+	t.Fatalf("profile %s has no rule %s", p.Name, id)
+	return nil
+}
+
+// ruleEnv builds the environment a rule of scope sees for facts; instance
+// and database rules run inside instance SQL01 on a VM with 16 GB
+func ruleEnv(p *Profile, scope string, facts map[string]any) map[string]any {
+	vm := map[string]any{"memory_gb": 16}
+	inst := map[string]any{"name": "SQL01", "connected": true}
+	switch scope {
+	case ScopeVM:
+		return withEnv(facts, p.Thresholds, nil, nil)
+	case ScopeInstance:
+		return withEnv(withName(facts, "SQL01"), p.Thresholds, vm, nil)
+	default:
+		return withEnv(withName(facts, "Sales"), p.Thresholds, vm, inst)
+	}
+}
+
+func disks(free ...float64) []any {
+	var out []any
+	for i, f := range free {
+		out = append(out, map[string]any{"drive": string(rune('C'+i)) + ":", "size_gb": 100.0, "free_gb": f})
+	}
+	return out
+}
+
+func TestBuiltinRules(t *testing.T) {
+	p := builtinProfile()
+	tests := []struct {
+		id      string
+		pass    map[string]any
+		fail    map[string]any
+		message string // of the failing check
+	}{
+		{"vm.execution_policy",
+			map[string]any{"execution_policy": "RemoteSigned"},
+			map[string]any{"execution_policy": "Restricted"},
+			"Restricted"},
+		{"vm.disk_free_space",
+			map[string]any{"disks": disks(50, 10)},
+			map[string]any{"disks": disks(50, 5)},
+			"C: 50.0 GB free; D: 5.0 GB free; (minimum: 10 GB)"},
+		{"instance.service",
+			map[string]any{"service_status": "Running"},
+			map[string]any{"service_status": "Stopped"},
+			"Service status: Stopped"},
+		{"instance.connectivity",
+			map[string]any{"connected": true},
+			map[string]any{"connected": false, "error": "Login failed for user 'svc'"},
+			"Login failed for user 'svc'"},
+		{"instance.version",
+			map[string]any{"major_version": 13, "version": "13.0.5026.0", "edition": "Standard", "product_level": "SP2"},
+			map[string]any{"major_version": 12, "version": "12.0.6024.0", "edition": "Standard", "product_level": "SP3"},
+			"SQL Server 12.0.6024.0 Standard (SP3)"},
+		{"instance.database_count",
+			map[string]any{"user_database_count": 150},
+			map[string]any{"user_database_count": 151},
+			"User databases found: 151 (limit: 150)"},
+		{"database.state",
+			map[string]any{"state": "ONLINE"},
+			map[string]any{"state": "OFFLINE"},
+			"Database state: OFFLINE"},
+		{"database.recovery_model",
+			map[string]any{"recovery_model": "FULL"},
+			map[string]any{"recovery_model": "SIMPLE"},
+			"Recovery model: SIMPLE"},
+		{"database.size",
+			map[string]any{"data_mb": 1024.0, "log_mb": 256.0},
+			map[string]any{"data_mb": 2.0 * 1024 * 1024, "log_mb": 1.0},
+			"Data: 2097152.0 MB, Log: 1.0 MB"},
+	}
+	if len(tests) != len(p.Rules) {
+		t.Errorf("%d built-in rules, %d covered", len(p.Rules), len(tests))
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			rule := builtinRule(t, p, tt.id)
+
+			item, ok := rule.evaluate(ruleEnv(p, rule.Scope, tt.pass))
+			if !ok || item.Status != StatusSuccess || item.Severity != SeverityInfo || item.Remediation != "" {
+				t.Errorf("pass facts: ok=%v %+v", ok, item)
+			}
+
+			item, ok = rule.evaluate(ruleEnv(p, rule.Scope, tt.fail))
+			if !ok || item.Status != StatusFailed || item.CheckID != tt.id || item.Check != rule.Check {
+				t.Errorf("fail facts: ok=%v %+v", ok, item)
+			}
+			if item.Severity != rule.Severity {
+				t.Errorf("severity = %s, want %s", item.Severity, rule.Severity)
+			}
+			if item.Message != tt.message {
+				t.Errorf("message = %q, want %q", item.Message, tt.message)
+			}
+			if item.Remediation == "" {
+				t.Error("failing check has no remediation")
+			}
+		})
+	}
+}
+
+func TestRuleWhenSkips(t *testing.T) {
+	p := builtinProfile()
+	for _, rule := range p.Rules {
+		if rule.When == "" {
+			continue
+		}
+		if item, ok := rule.evaluate(ruleEnv(p, rule.Scope, map[string]any{})); ok {
+			t.Errorf("%s applied without its facts: %+v", rule.ID, item)
+		}
+	}
+
+	// Facts collected over TDS have no service status; the script's do
+	_, instances := p.evaluateFacts(&HostFacts{Instances: []InstanceFacts{
+		{Name: "SQL01", Facts: map[string]any{"connected": true}},
+	}})
+	if checks := instances[0].Checks; len(checks) != 1 || checks[0].CheckID != "instance.connectivity" {
+		t.Errorf("instance checks = %+v, want only connectivity", checks)
+	}
+}
+
+func TestRuleMissingFactsError(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  Rule
+		error string
+	}{
+		{"pass", Rule{Check: "Memory", Scope: ScopeVM, Pass: "memory_gb >= 8"}, "rule evaluation failed"},
+		{"when", Rule{Check: "Memory", Scope: ScopeVM, When: "memory_gb > 0", Pass: "true"}, "rule condition failed"},
+		{"nested", Rule{Check: "Disks", Scope: ScopeVM, Pass: "all(disks, .free_gb > 1)"}, "rule evaluation failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rule.compile(); err != nil {
+				t.Fatal(err)
+			}
+			item, ok := tt.rule.evaluate(withEnv(map[string]any{}, builtinThresholds(), nil, nil))
+			if !ok || item.Status != StatusError || !strings.HasPrefix(item.Message, tt.error) {
+				t.Errorf("ok=%v %+v, want ERROR %q", ok, item, tt.error)
+			}
+			if item.CheckID != "vm."+strings.ToLower(tt.rule.Check) || item.Severity != SeverityCritical {
+				t.Errorf("id %q severity %q", item.CheckID, item.Severity)
+			}
+		})
+	}
+}
+
+func writeProfile(t *testing.T, yaml string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "profile.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadProfileOverrides(t *testing.T) {
+	p, err := loadProfile(writeProfile(t, `
+name: strict
+version: "2.0"
+thresholds:
+  min_free_disk_gb: 20
+  min_memory_gb: 32
+severity_policy:
+  LOW: FAILED
+rules:
+  # by id
+  - id: vm.execution_policy
+    check: PowerShell Execution Policy
+    scope: vm
+    pass: execution_policy == "AllSigned"
+    message: "policy is {{.execution_policy}}"
+  # by scope and check name
+  - scope: database
+    check: Database Recovery Model
+    pass: recovery_model == "FULL"
+    severity: HIGH
+  # new
+  - id: vm.memory
+    check: Memory
+    scope: vm
+    when: memory_gb != nil
+    pass: memory_gb >= thresholds.min_memory_gb
+    message: "{{.memory_gb}} GB (minimum: {{.thresholds.min_memory_gb}} GB)"
+    severity: MEDIUM
+    remediation:
+      description: Add memory to reach {{.thresholds.min_memory_gb}} GB
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	builtin := builtinProfile()
+	if len(p.Rules) != len(builtin.Rules)+1 {
+		t.Errorf("%d rules, want the %d built-in ones plus vm.memory", len(p.Rules), len(builtin.Rules))
+	}
+	if p.Thresholds["min_free_disk_gb"] != 20 || p.Thresholds["max_user_databases"] != 150 {
+		t.Errorf("thresholds = %v, want overrides merged into the built-in ones", p.Thresholds)
+	}
+	if p.SeverityPolicy.statusFor(SeverityLow) != StatusFailed || p.SeverityPolicy.statusFor(SeverityMedium) != StatusWarning {
+		t.Errorf("severity policy = %v", p.SeverityPolicy)
+	}
+
+	policy := builtinRule(t, p, "vm.execution_policy")
+	item, _ := policy.evaluate(ruleEnv(p, ScopeVM, map[string]any{"execution_policy": "RemoteSigned"}))
+	if item.Status != StatusFailed || item.Message != "policy is RemoteSigned" {
+		t.Errorf("overridden by id: %+v", item)
+	}
+	if policy.Remediation == nil || !strings.Contains(item.Remediation, "Set-ExecutionPolicy") {
+		t.Errorf("override without remediation should keep the built-in one: %q", item.Remediation)
+	}
+
+	recovery := builtinRule(t, p, "database.recovery_model")
+	item, _ = recovery.evaluate(ruleEnv(p, ScopeDatabase, map[string]any{"recovery_model": "BULK_LOGGED"}))
+	if item.Status != StatusFailed || item.Severity != SeverityHigh {
+		t.Errorf("overridden by scope and check: %+v", item)
+	}
+
+	memory := builtinRule(t, p, "vm.memory")
+	item, _ = memory.evaluate(ruleEnv(p, ScopeVM, map[string]any{"memory_gb": 16}))
+	if item.Status != StatusFailed || item.Message != "16 GB (minimum: 32 GB)" ||
+		item.Remediation != "# MANUAL: Add memory to reach 32 GB" {
+		t.Errorf("added rule: %+v", item)
+	}
+}
+
+func TestLoadProfileErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		yaml  string
+		error string
+	}{
+		{"no version", "name: p", "needs a version"},
+		{"builtin name", "name: default\nversion: '1'", "needs a name other than"},
+		{"bad scope", "name: p\nversion: '1'\nrules:\n  - {check: X, scope: host, pass: 'true'}", "scope must be vm, instance or database"},
+		{"bad expression", "name: p\nversion: '1'\nrules:\n  - {check: X, scope: vm, pass: 'memory_gb >='}", "invalid pass"},
+		{"bad message", "name: p\nversion: '1'\nrules:\n  - {check: X, scope: vm, pass: 'true', message: '{{.x'}", "invalid message"},
+		{"duplicate id", "name: p\nversion: '1'\nrules:\n  - {id: a, check: X, scope: vm, pass: 'true'}\n  - {id: a, check: Y, scope: vm, pass: 'true'}", "duplicate rule id"},
+		{"bad severity", "name: p\nversion: '1'\nrules:\n  - {check: X, scope: vm, pass: 'true', severity: URGENT}", "unknown severity"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadProfile(writeProfile(t, tt.yaml))
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("error = %v, want %q", err, tt.error)
+			}
+		})
+	}
+}
+
+func TestRuleRemediationRendering(t *testing.T) {
+	p := builtinProfile()
+	tests := []struct {
+		id       string
+		scope    map[string]any
+		contains []string
+	}{
+		{"database.state", map[string]any{"state": "OFFLINE", "name": "Sales]DB"}, []string{
+			"Invoke-Step -Description 'Bring database Sales]DB on SQL01 online' -Fix {",
+			"-Variables @{ name = 'Sales]DB' } -Query @'\n",
+			"WHERE name = @name AND state_desc = 'OFFLINE')",
+			"N'ALTER DATABASE ' + QUOTENAME(@name) + N' SET ONLINE;'",
+			"\n'@\n",
+		}},
+		// A name from the host can neither end the here-string nor the quotes
+		{"database.recovery_model", map[string]any{"recovery_model": "SIMPLE", "name": "x\n'@\nRemove-Item C:\\ # \u2019"}, []string{
+			"-Variables @{ name = ('x' + [char]10 + '''@' + [char]10 + 'Remove-Item C:\\ # \u2019\u2019') } -Query @'\n" +
+				"IF EXISTS (SELECT 1 FROM sys.databases WHERE name = @name AND recovery_model_desc <> 'FULL')\n",
+			"# A full backup starts the log chain",
+		}},
+		{"instance.service", map[string]any{"service_status": "Stopped", "name": "O'Brien"}, []string{
+			"-Description 'Start SQL Server service MSSQL$O''Brien and set it to start automatically'",
+			"-Guard { (Get-Service -Name 'MSSQL$O''Brien').Status -ne 'Running' }",
+			"    Start-Service -Name 'MSSQL$O''Brien'",
+		}},
+		{"vm.disk_free_space", map[string]any{"disks": disks(1)}, []string{
+			"# MANUAL: Free or add disk space so every fixed drive has at least 10 GB free",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			rule := builtinRule(t, p, tt.id)
+			env := ruleEnv(p, rule.Scope, nil)
+			for k, v := range tt.scope {
+				env[k] = v
+			}
+			item, _ := rule.evaluate(env)
+			for _, want := range tt.contains {
+				if !strings.Contains(item.Remediation, want) {
+					t.Errorf("remediation lacks %q:\n%s", want, item.Remediation)
+				}
+			}
+		})
+	}
+}
+
+func TestRemediationQuoting(t *testing.T) {
+	for in, want := range map[string]string{
+		"O'Brien \u2018a\u2019 \u201ab\u201b": "'O''Brien \u2018\u2018a\u2019\u2019 \u201a\u201ab\u201b\u201b'",
+		"a\r\n'@":                             "('a' + [char]13 + '' + [char]10 + '''@')",
+	} {
+		if got := psQuote(in); got != want {
+			t.Errorf("psQuote(%q) = %q, want %q", in, got, want)
+		}
+	}
+	for _, text := range []string{"{{sqlident .name}}", "{{sqlstring .name}}"} {
+		r := &Remediation{Description: "fix {{.name}}", Script: "Invoke-Sql -Instance 'I' -Query @'\n" + text + "\n'@"}
+		if err := r.compile("test"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.render(map[string]any{"name": "Sales]"}); err != nil {
+			t.Errorf("%s: %v", text, err)
+		}
+		for _, name := range []string{"a\n'@\nStop-Computer", "a\r'@"} {
+			if step, err := r.render(map[string]any{"name": name}); err == nil {
+				t.Errorf("%s rendered %q:\n%s", text, name, step)
+			}
+		}
+	}
+	r := &Remediation{Description: "fix {{.name}}"}
+	r.compile("test")
+	if step, _ := r.render(map[string]any{"name": "a\r\nStop-Computer"}); step != "# MANUAL: fix a  Stop-Computer" {
+		t.Errorf("manual step = %q, want one comment line", step)
+	}
+}
+
+func TestProfileApplyChecks(t *testing.T) {
+	disabled := false
+	p := builtinProfile()
+	p.Checks = map[string]ProfileRule{
+		"Database Recovery Model": {Enabled: &disabled},
+		"Database Size":           {Severity: SeverityCritical},
+	}
+	result := &ComprehensiveResult{Facts: &HostFacts{
+		VM: map[string]any{"execution_policy": "RemoteSigned"},
+		Instances: []InstanceFacts{{
+			Name:  "SQL01",
+			Facts: map[string]any{"connected": true},
+			Databases: []DatabaseFacts{{Name: "Big", Facts: map[string]any{
+				"state": "ONLINE", "recovery_model": "SIMPLE", "data_mb": 3.0 * 1024 * 1024, "log_mb": 0.0,
+			}}},
+		}},
+	}}
+	p.evaluate(result, nil)
+
+	checks := result.Instances[0].Databases["Big"]
+	byID := make(map[string]CheckItem)
+	for _, c := range checks {
+		byID[c.CheckID] = c
+	}
+	if _, ok := byID["database.recovery_model"]; ok {
+		t.Error("disabled check still reported")
+	}
+	if size := byID["database.size"]; size.Severity != SeverityCritical || size.Status != StatusFailed {
+		t.Errorf("database.size = %+v, want a CRITICAL failure", size)
+	}
+	if state := byID["database.state"]; state.Status != StatusSuccess {
+		t.Errorf("database.state = %+v", state)
+	}
+	if ids := p.checkIDs(); len(ids) != len(p.Rules)-1 {
+		t.Errorf("checkIDs = %v, want every rule but the disabled one", ids)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Run statuses
+const (
+	RunStatusRunning   = "running"
+	RunStatusCompleted = "completed"
+	RunStatusCancelled = "cancelled"
+	// RunStatusInterrupted runs were cut short by a service shutdown; only
+	// the hosts checked before it are saved
+	RunStatusInterrupted = "interrupted"
+)
+
+const maxRunHistory = 100
+
+// Run tracks one batch of checks started through POST /api/v1/check
+type Run struct {
+	ID          string   `json:"id"`
+	Status      string   `json:"status"`
+	StartedBy   string   `json:"started_by"`
+	Hostnames   []string `json:"hostnames"`
+	StartedAt   string   `json:"started_at"`
+	FinishedAt  string   `json:"finished_at,omitempty"`
+	CancelledBy string   `json:"cancelled_by,omitempty"`
+	// Profile and ProfileVersion record the rule profile the run applied
+	Profile        string `json:"profile"`
+	ProfileVersion string `json:"profile_version"`
+	// ParentID, RerunMode and CheckIDs describe a rerun of a previous run
+	ParentID  string   `json:"parent_id,omitempty"`
+	RerunMode string   `json:"rerun_mode,omitempty"`
+	CheckIDs  []string `json:"check_ids,omitempty"`
+	// Remediations are the automated remediation requests raised on the run
+	Remediations []*RemediationRequest `json:"remediations,omitempty"`
+
+	// results are the run's check results; written by the workers until
+	// FinishedAt is set
+	results *BatchResponse
+	// state is results merged with those of later reruns or remediations,
+	// and for a rerun into its parent's state; nil until there are any
+	state *BatchResponse
+	// root is the run a rerun's lineage started from
+	root   *Run
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// ===== Globals =====
+
+var runs = make(map[string]*Run)
+var runOrder []string
+var runsMu sync.Mutex
+
+// startRun registers a new running batch collecting into results and returns
+// it; rerun is nil unless it re-checks part of a previous run
+func startRun(results *BatchResponse, hostnames []string, startedBy string, profile *Profile, rerun *Rerun) *Run {
+	ctx, cancel := context.WithCancel(serviceCtx)
+	run := &Run{
+		ID:             results.RunID,
+		Status:         RunStatusRunning,
+		StartedBy:      startedBy,
+		Hostnames:      hostnames,
+		Profile:        profile.Name,
+		ProfileVersion: profile.Version,
+		StartedAt:      time.Now().UTC().Format(time.RFC3339),
+		results:        results,
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+	if rerun != nil {
+		run.ParentID, run.RerunMode, run.CheckIDs = rerun.Parent.ID, rerun.Mode, rerun.CheckIDs
+		run.root = rerun.Parent
+		if rerun.Parent.root != nil {
+			run.root = rerun.Parent.root
+		}
+	}
+
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	runs[run.ID] = run
+	runOrder = append(runOrder, run.ID)
+	if len(runOrder) > maxRunHistory {
+		delete(runs, runOrder[0])
+		runOrder = runOrder[1:]
+	}
+	return run
+}
+
+// finishRun marks run as done, keeping a cancelled or interrupted status
+func finishRun(run *Run) {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	if run.Status == RunStatusRunning {
+		run.Status = RunStatusCompleted
+	}
+	run.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+	run.cancel()
+}
+
+func getRun(id string) *Run {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	return runs[id]
+}
+
+// snapshot copies the exported fields under the lock for JSON responses
+func (r *Run) snapshot() Run {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	var remediations []*RemediationRequest
+	for _, req := range r.Remediations {
+		remediations = append(remediations, req.clone())
+	}
+	return Run{
+		ID:             r.ID,
+		Status:         r.Status,
+		StartedBy:      r.StartedBy,
+		Hostnames:      r.Hostnames,
+		StartedAt:      r.StartedAt,
+		FinishedAt:     r.FinishedAt,
+		CancelledBy:    r.CancelledBy,
+		Profile:        r.Profile,
+		ProfileVersion: r.ProfileVersion,
+		ParentID:       r.ParentID,
+		RerunMode:      r.RerunMode,
+		CheckIDs:       r.CheckIDs,
+		Remediations:   remediations,
+	}
+}
+
+// ===== API Handlers =====
+
+// handleListRuns returns recent runs, newest first
+func handleListRuns(c *gin.Context) {
+	runsMu.Lock()
+	ids := append([]string(nil), runOrder...)
+	runsMu.Unlock()
+
+	list := make([]Run, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		if run := getRun(ids[i]); run != nil {
+			list = append(list, run.snapshot())
+		}
+	}
+	c.JSON(http.StatusOK, RunListResponse{Runs: list})
+}
+
+// handleGetRun returns a single run's status
+func handleGetRun(c *gin.Context) {
+	run := getRun(c.Param("id"))
+	if run == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Run not found"})
+		return
+	}
+	c.JSON(http.StatusOK, RunResponse{Run: run.snapshot()})
+}
+
+// handleCancelRun stops a running batch; hosts not yet checked are skipped
+func handleCancelRun(c *gin.Context) {
+	run := getRun(c.Param("id"))
+	if run == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Run not found"})
+		return
+	}
+
+	access := accessFor(currentPrincipal(c))
+	if denied := access.deniedHosts(run.Hostnames); len(denied) > 0 {
+		auditRequest(c, AuditEntry{
+			Action:  AuditRunCancel,
+			RunID:   run.ID,
+			Hosts:   run.Hostnames,
+			Outcome: AuditOutcomeDenied,
+			Detail:  fmt.Sprintf("not permitted to target %v", denied),
+		})
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Not permitted to cancel runs targeting %v", denied)})
+		return
+	}
+
+	runsMu.Lock()
+	if run.Status != RunStatusRunning {
+		status := run.Status
+		runsMu.Unlock()
+		auditRequest(c, AuditEntry{
+			Action:  AuditRunCancel,
+			RunID:   run.ID,
+			Hosts:   run.Hostnames,
+			Outcome: AuditOutcomeFailure,
+			Detail:  "run already " + status,
+		})
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Run is already %s", status)})
+		return
+	}
+	run.Status = RunStatusCancelled
+	run.CancelledBy = currentPrincipal(c).Subject
+	runsMu.Unlock()
+
+	run.cancel()
+	auditRequest(c, AuditEntry{
+		Action:  AuditRunCancel,
+		RunID:   run.ID,
+		Hosts:   run.Hostnames,
+		Outcome: AuditOutcomeSuccess,
+	})
+	logrus.Infof("Run %s cancelled by %s", run.ID, run.CancelledBy)
+	c.JSON(http.StatusOK, RunResponse{Run: run.snapshot()})
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// Readiness tiers, from best to worst
+const (
+	ReadinessReady    = "Ready"
+	ReadinessWarnings = "Ready with warnings"
+	ReadinessUnknown  = "Unknown"
+	ReadinessBlocked  = "Blocked"
+)
+
+// severityPenalty is how many points a failed, errored or warning check of
+// each severity takes off an entity's score of 100
+var severityPenalty = map[Severity]int{
+	SeverityCritical: 40,
+	SeverityHigh:     20,
+	SeverityMedium:   10,
+	SeverityLow:      5,
+	SeverityInfo:     0,
+}
+
+// Fitment is the weighted score (0-100) and readiness tier of one entity
+type Fitment struct {
+	Score     int    `json:"score"`
+	Readiness string `json:"readiness"`
+	// BySeverity counts the checks that did not pass, by severity
+	BySeverity map[Severity]int `json:"by_severity"`
+}
+
+// fitmentOf scores a set of checks: each failure, error or warning costs its
+// severity penalty. A failure (as decided by the severity policy) blocks, an
+// error leaves readiness unknown, and warnings or waivers make an entity
+// ready with warnings.
+func fitmentOf(checkSets ...[]CheckResult) Fitment {
+	score := 100
+	total := 0
+	bySeverity := make(map[Severity]int, len(severities))
+	for _, sev := range severities {
+		bySeverity[sev] = 0
+	}
+	var blocked, errored, warned bool
+	for _, checks := range checkSets {
+		for _, check := range checks {
+			total++
+			switch check.Status {
+			case StatusFailed:
+				score -= severityPenalty[check.Severity]
+				blocked = true
+			case StatusError:
+				score -= severityPenalty[check.Severity]
+				errored = true
+			case StatusWarning:
+				score -= severityPenalty[check.Severity]
+				warned = true
+			case StatusWaived:
+				warned = true
+			}
+			if check.Status != StatusSuccess && check.Status != StatusInfo {
+				bySeverity[check.Severity]++
+			}
+		}
+	}
+	if score < 0 {
+		score = 0
+	}
+
+	f := Fitment{Score: score, Readiness: ReadinessReady, BySeverity: bySeverity}
+	switch {
+	case total == 0:
+		f.Score, f.Readiness = 0, ReadinessUnknown
+	case blocked:
+		f.Readiness = ReadinessBlocked
+	case errored:
+		f.Readiness = ReadinessUnknown
+	case warned:
+		f.Readiness = ReadinessWarnings
+	}
+	return f
+}
+
+// vmFitment scores a host together with its instances and databases
+func vmFitment(r *BatchResponse, hostname string) Fitment {
+	sets := [][]CheckResult{r.VMResults[hostname]}
+	for name, checks := range r.InstanceResults {
+		if strings.HasPrefix(name, hostname+"\\") {
+			sets = append(sets, checks)
+		}
+	}
+	for name, checks := range r.DatabaseResults {
+		if strings.HasPrefix(name, hostname+"\\") {
+			sets = append(sets, checks)
+		}
+	}
+	return fitmentOf(sets...)
+}
+
+// instanceFitment scores an instance together with its databases
+func instanceFitment(r *BatchResponse, instanceKey string) Fitment {
+	sets := [][]CheckResult{r.InstanceResults[instanceKey]}
+	for name, checks := range r.DatabaseResults {
+		if databaseInInstance(name, instanceKey) {
+			sets = append(sets, checks)
+		}
+	}
+	return fitmentOf(sets...)
+}
+
+// readinessRank orders tiers from best (0) to worst
+func readinessRank(tier string) int {
+	switch tier {
+	case ReadinessReady:
+		return 0
+	case ReadinessWarnings:
+		return 1
+	case ReadinessUnknown:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// GroupReadiness rolls VM fitment up to an inventory group
+type GroupReadiness struct {
+	Group        string         `json:"group"`
+	VMs          int            `json:"vms"`
+	AverageScore int            `json:"average_score"`
+	Readiness    string         `json:"readiness"`
+	Tiers        map[string]int `json:"tiers"`
+}
+
+// groupReadiness rolls host fitment up to inventory groups, most ready first.
+// A group's readiness is that of its least ready host.
+func groupReadiness(r *BatchResponse) []GroupReadiness {
+	byGroup := make(map[string]*GroupReadiness)
+	totals := make(map[string]int)
+	for hostname := range r.VMResults {
+		name := currentConfig().groupForHost(hostname)
+		g, ok := byGroup[name]
+		if !ok {
+			g = &GroupReadiness{Group: name, Readiness: ReadinessReady, Tiers: tierCounts()}
+			byGroup[name] = g
+		}
+		f := vmFitment(r, hostname)
+		g.VMs++
+		g.Tiers[f.Readiness]++
+		totals[name] += f.Score
+		if readinessRank(f.Readiness) > readinessRank(g.Readiness) {
+			g.Readiness = f.Readiness
+		}
+	}
+
+	groups := make([]GroupReadiness, 0, len(byGroup))
+	for name, g := range byGroup {
+		g.AverageScore = totals[name] / g.VMs
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].AverageScore != groups[j].AverageScore {
+			return groups[i].AverageScore > groups[j].AverageScore
+		}
+		return groups[i].Group < groups[j].Group
+	})
+	return groups
+}
+
+func tierCounts() map[string]int {
+	return map[string]int{
+		ReadinessReady:    0,
+		ReadinessWarnings: 0,
+		ReadinessUnknown:  0,
+		ReadinessBlocked:  0,
+	}
+}
+
+// readinessCounts counts VMs, instances and databases per readiness tier
+func readinessCounts(r *BatchResponse) (vms, instances, databases map[string]int) {
+	vms, instances, databases = tierCounts(), tierCounts(), tierCounts()
+	for hostname := range r.VMResults {
+		vms[vmFitment(r, hostname).Readiness]++
+	}
+	for name := range r.InstanceResults {
+		instances[instanceFitment(r, name).Readiness]++
+	}
+	for _, checks := range r.DatabaseResults {
+		databases[fitmentOf(checks).Readiness]++
+	}
+	return vms, instances, databases
+}
@@ -4,88 +4,31 @@ param (
 
 try {
     # Execute the checks remotely
-    $scriptBlock = {
-        param($ComputerName)
-        
-        # Initialize results
-        $vmChecks = @()
-        $instanceChecks = @()
-        $databaseChecks = @()
-        
-        # 1. VM Level Check - PowerShell Execution Policy
-        try {
-            $policy = Get-ExecutionPolicy
-            $vmChecks += @{
-                Check = "PowerShell Execution Policy"
-                Status = if ($policy.ToString() -eq 'Restricted') { 'FAILED' } else { 'SUCCESS' }
-                Message = $policy.ToString()
-                Severity = if ($policy.ToString() -eq 'Restricted') { 'CRITICAL' } else { 'INFO' }
-            }
-        }
-        catch {
-            $vmChecks += @{
-                Check = "PowerShell Execution Policy"
-                Status = "ERROR"
-                Message = $_.Exception.Message
-                Severity = "CRITICAL"
-            }
-        }
-        
-        # 2. Instance Level Check - Database Count (simulate for now)
-        try {
-            # For now, simulate instance check (you can add real SQL Server logic later)
-            $instanceChecks += @{
-                Check = "Database Count Validation"
-                Status = "SUCCESS"
-                Message = "User databases found: 5 (limit: 150)"
-                Severity = "INFO"
-            }
-        }
-        catch {
-            $instanceChecks += @{
-                Check = "Database Count Validation"
-                Status = "ERROR"
-                Message = $_.Exception.Message
-                Severity = "CRITICAL"
-            }
-        }
-        
-        # 3. Database Level Check - Database State (simulate for now)
-        try {
-            # For now, simulate database check (you can add real SQL Server logic later)
-            $databaseChecks += @{
-                Check = "Database State"
-                Status = "SUCCESS"
-                Message = "Database state: ONLINE"
-                Severity = "INFO"
-            }
-        }
-        catch {
-            $databaseChecks += @{
-                Check = "Database State"
-                Status = "ERROR"
-                Message = $_.Exception.Message
-                Severity = "CRITICAL"
-            }
-        }
-        
-        return @{
-            VMChecks = $vmChecks
-            InstanceChecks = $instanceChecks
-            DatabaseChecks = $databaseChecks
-            Success = $true
-        }
+    # The checks live in checks.ps1 so every executor runs the same code
+    $checksPath = Join-Path $PSScriptRoot 'checks.ps1'
+    $scriptBlock = [scriptblock]::Create((Get-Content -Raw -Path $checksPath))
+
+    $invokeParams = @{
+        ComputerName = $ComputerName
+        ScriptBlock = $scriptBlock
+        ArgumentList = $ComputerName
+        ErrorAction = 'Stop'
+    }
+
+    # Credentials are passed by the service through the environment, never on the command line
+    if ($env:NDB_PRECHECK_USERNAME) {
+        $securePassword = ConvertTo-SecureString $env:NDB_PRECHECK_PASSWORD -AsPlainText -Force
+        $invokeParams.Credential = New-Object System.Management.Automation.PSCredential($env:NDB_PRECHECK_USERNAME, $securePassword)
+        Remove-Item Env:\NDB_PRECHECK_PASSWORD -ErrorAction SilentlyContinue
     }
 
-    $result = Invoke-Command -ComputerName $ComputerName -ScriptBlock $scriptBlock -ArgumentList $ComputerName -ErrorAction Stop
+    $result = Invoke-Command @invokeParams
 
     # Create the output
     $output = @{
         Success = $true
         Target = $ComputerName
-        VMChecks = $result.VMChecks
-        InstanceChecks = $result.InstanceChecks
-        DatabaseChecks = $result.DatabaseChecks
+        Facts = $result.Facts
     }
 }
 catch {
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// vaultRefPrefix marks credential references served by Vault, e.g.
+// "vault:sql/wave-1" reads the KV v2 secret sql/wave-1
+const vaultRefPrefix = "vault:"
+
+// SecretProvider resolves a credential reference at execution time
+type SecretProvider interface {
+	Resolve(ctx context.Context, ref string) (*Credential, error)
+}
+
+// SecretsConfig configures external secret backends
+type SecretsConfig struct {
+	Vault *VaultConfig `yaml:"vault"`
+}
+
+// ===== Globals =====
+
+// vaultProvider is nil unless secrets.vault is configured; replaced on reload
+var vaultProvider atomic.Pointer[VaultProvider]
+
+// Resolve looks the reference up in the local encrypted store
+func (s *CredentialStore) Resolve(_ context.Context, ref string) (*Credential, error) {
+	cred, ok := s.Get(ref)
+	if !ok {
+		return nil, fmt.Errorf("credential %q does not exist", ref)
+	}
+	return &cred, nil
+}
+
+// initSecretProviders sets up the configured external backends
+func initSecretProviders() error {
+	vault := currentConfig().Secrets.Vault
+	if vault == nil {
+		vaultProvider.Store(nil)
+		return nil
+	}
+	p, err := newVaultProvider(*vault)
+	vaultProvider.Store(p)
+	return err
+}
+
+// providerFor picks the backend for ref and returns the backend-local key
+func providerFor(ref string) (SecretProvider, string, error) {
+	if strings.HasPrefix(ref, vaultRefPrefix) {
+		vault := vaultProvider.Load()
+		if vault == nil {
+			return nil, "", fmt.Errorf("credential %q needs Vault but secrets.vault is not configured", ref)
+		}
+		return vault, strings.TrimPrefix(ref, vaultRefPrefix), nil
+	}
+	if credentialStore == nil {
+		return nil, "", fmt.Errorf("credential %q referenced but the credential store is unavailable", ref)
+	}
+	return credentialStore, ref, nil
+}
+
+// resolveCredential resolves ref through whichever backend serves it
+func resolveCredential(ctx context.Context, ref string) (*Credential, error) {
+	provider, key, err := providerFor(ref)
+	if err != nil {
+		return nil, err
+	}
+	return provider.Resolve(ctx, key)
+}
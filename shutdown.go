@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultShutdownGracePeriod = 5 * time.Minute
+
+// shutdownFinishTimeout bounds saving the results of interrupted runs once
+// their hosts are cancelled
+const shutdownFinishTimeout = 30 * time.Second
+
+// ShutdownConfig controls how SIGTERM and SIGINT stop the service
+type ShutdownConfig struct {
+	// GracePeriod is how long running checks and remediations may take to
+	// finish before they are interrupted; 5m if zero. Keep it below the
+	// service manager's stop timeout.
+	GracePeriod time.Duration `yaml:"grace_period"`
+}
+
+// errInterrupted cancels the runs and remediations still going when the
+// grace period ends
+var errInterrupted = errors.New("service shutting down")
+
+// ===== Globals =====
+
+// serviceCtx is the parent of every run's context and of remediations;
+// stopService cancels what is left after the grace period
+var serviceCtx, stopService = context.WithCancelCause(context.Background())
+
+var shutdownMu sync.Mutex
+var shuttingDown bool
+
+// inflight counts the runs and remediations working in the background, and
+// the webhook deliveries and digests they send
+var inflight sync.WaitGroup
+
+// beginWork registers background work that shutdown waits for, or returns
+// false once the service is shutting down; pair it with inflight.Done
+func beginWork() bool {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	if shuttingDown {
+		return false
+	}
+	inflight.Add(1)
+	return true
+}
+
+// goWork runs fn in the background as part of work registered with
+// beginWork, e.g. a run's webhook deliveries, so shutdown waits for it too.
+// Call it only while that work still holds its registration.
+func goWork(fn func()) {
+	inflight.Add(1)
+	go func() {
+		defer inflight.Done()
+		fn()
+	}()
+}
+
+// rejectShuttingDown answers requests starting background work while the
+// service drains
+func rejectShuttingDown(c *gin.Context) {
+	c.Header("Retry-After", "60")
+	c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service is shutting down"})
+}
+
+// serve runs the server on addr until SIGTERM or SIGINT, then drains the
+// background work and stops. The API stays up while draining so progress
+// can be followed; a second signal exits at once.
+func serve(router *gin.Engine, addr string) {
+	server := &http.Server{Addr: addr, Handler: router}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Fatalf("Server failed: %v", err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	<-ctx.Done()
+	stop()
+
+	grace := currentConfig().Shutdown.GracePeriod
+	if grace <= 0 {
+		grace = defaultShutdownGracePeriod
+	}
+	drain(grace)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logrus.Errorf("Server shutdown: %v", err)
+	}
+	logrus.Info("NDB PreCheck Service stopped")
+}
+
+// drain stops new runs and remediations and waits up to grace for the
+// running ones. Runs still going are then marked interrupted and cancelled;
+// their unchecked hosts are skipped and what was checked is saved.
+func drain(grace time.Duration) {
+	shutdownMu.Lock()
+	shuttingDown = true
+	shutdownMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		inflight.Wait()
+		close(done)
+	}()
+
+	logrus.Infof("Shutting down: no new runs, waiting up to %s for running checks", grace)
+	select {
+	case <-done:
+		return
+	case <-time.After(grace):
+	}
+
+	runsMu.Lock()
+	for _, run := range runs {
+		if run.Status == RunStatusRunning {
+			run.Status = RunStatusInterrupted
+			logrus.Warnf("Interrupting run %s", run.ID)
+		}
+	}
+	runsMu.Unlock()
+	stopService(errInterrupted)
+
+	select {
+	case <-done:
+	case <-time.After(shutdownFinishTimeout):
+		logrus.Errorf("Interrupted runs did not finish within %s; their results are lost", shutdownFinishTimeout)
+	}
+}
+
+// withoutInterruptedHosts returns r without the hosts a shutdown stopped
+// before they were checked, or r itself if it has none
+func withoutInterruptedHosts(r *BatchResponse) *BatchResponse {
+	var hosts []string
+	for host, d := range r.HostDetails {
+		if d.interrupted {
+			hosts = append(hosts, host)
+		}
+	}
+	if len(hosts) == 0 {
+		return r
+	}
+
+	checked := &BatchResponse{
+		RunID:           r.RunID,
+		Profile:         r.Profile,
+		ProfileVersion:  r.ProfileVersion,
+		Timestamp:       r.Timestamp,
+		VMResults:       copyResults(r.VMResults),
+		InstanceResults: copyResults(r.InstanceResults),
+		DatabaseResults: copyResults(r.DatabaseResults),
+		HostDetails:     r.HostDetails,
+	}
+	for _, host := range hosts {
+		hostChecks(checked, host, func(results map[string][]CheckResult, key string) {
+			delete(results, key)
+		})
+	}
+	summarize(checked)
+	return checked
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestDrainWaitsForDeliveries checks shutdown waits for the webhook
+// deliveries of a run that has already finished
+func TestDrainWaitsForDeliveries(t *testing.T) {
+	received := make(chan struct{}, 1)
+	release := make(chan struct{})
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-release
+	}))
+	t.Cleanup(hook.Close)
+	t.Cleanup(func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	})
+	t.Cleanup(func() {
+		shutdownMu.Lock()
+		shuttingDown = false
+		shutdownMu.Unlock()
+	})
+	withConfig(t, &Config{
+		Webhooks:     []WebhookConfig{{Name: "hook", URL: hook.URL}},
+		WebhookRetry: RetryConfig{MaxAttempts: 1},
+	})
+
+	if !beginWork() {
+		t.Fatal("work refused before shutdown")
+	}
+	notifyWebhooks(EventRunCompleted, "run-1", nil)
+	inflight.Done() // the run is done; its delivery is not
+	<-received
+
+	drained := make(chan struct{})
+	go func() {
+		drain(time.Minute)
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		t.Fatal("drain returned while a delivery was still going")
+	case <-time.After(200 * time.Millisecond):
+	}
+	if beginWork() {
+		inflight.Done()
+		t.Error("work accepted while draining")
+	}
+
+	close(release)
+	select {
+	case <-drained:
+	case <-time.After(5 * time.Second):
+		t.Fatal("drain did not return once the delivery finished")
+	}
+}
+
+// TestDrainInterruptsRuns checks a run still going after the grace period is
+// interrupted, and that only the hosts it checked are saved
+func TestDrainInterruptsRuns(t *testing.T) {
+	keyPath, pub := writeClientKey(t, "")
+	s := startSSHStandIn(t, pub)
+	s.hangAfter = 1
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	configYAML := fmt.Sprintf(`executor:
+  default: ssh
+  ssh: {port: %d, insecure_ignore_host_key: true, user: keyuser, private_key: %s}
+audit: {path: %[3]s/audit.jsonl}
+history: {path: %[3]s/history.jsonl}
+state: {store_path: %[3]s/state.json}
+`, s.port, keyPath, dir)
+	if err := os.WriteFile(configFile, []byte(configYAML), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withConfig(t, cfg)
+	if stateStore, err = openStateStore(cfg.State.StorePath); err != nil {
+		t.Fatal(err)
+	}
+	previousResults := latestResults()
+	finished, finishedBefore := latestFinished()
+	t.Cleanup(func() {
+		stateStore = nil
+		setLatestResults(previousResults)
+		recordFinished(finished, finishedBefore, false)
+		shutdownMu.Lock()
+		shuttingDown = false
+		shutdownMu.Unlock()
+		serviceCtx, stopService = context.WithCancelCause(context.Background())
+	})
+	s.respond(`{"Success":true,"Facts":{"vm":{"execution_policy":"RemoteSigned"}}}`, "", 0)
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/check", nil)
+	c.Set(principalKey, &Principal{Subject: "ops", Kind: "token"})
+	profile, _ := cfg.profile("")
+	hosts := []string{"127.0.0.1", "localhost"}
+	if !beginWork() {
+		t.Fatal("work refused before shutdown")
+	}
+	run := getRun(launchRun(c, hosts, profile, nil).RunID)
+
+	// One host is answered; the other hangs until the run is interrupted
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		progressMu.Lock()
+		processed := processedVMs
+		progressMu.Unlock()
+		if processed == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no host was checked")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	drain(50 * time.Millisecond)
+
+	snap := run.snapshot()
+	if snap.Status != RunStatusInterrupted || snap.FinishedAt == "" {
+		t.Fatalf("run is %s, finished %q; want interrupted and finished", snap.Status, snap.FinishedAt)
+	}
+	var checked, unchecked string
+	for _, host := range hosts {
+		if run.results.HostDetails[host].interrupted {
+			unchecked = host
+		} else {
+			checked = host
+		}
+	}
+	if checked == "" || unchecked == "" {
+		t.Fatalf("checked %q, interrupted %q; want one of each", checked, unchecked)
+	}
+
+	for host, want := range map[string]bool{checked: true, unchecked: false} {
+		if got := len(stateStore.Query(StateFilter{Host: host}, time.Now())) > 0; got != want {
+			t.Errorf("%s in the latest state: %v, want %v", host, got, want)
+		}
+	}
+	var record *HistoryRecord
+	readHistory(time.Time{}, time.Time{}, func(r HistoryRecord, _ time.Time) {
+		if r.RunID == run.ID {
+			record = &r
+		}
+	})
+	if record == nil {
+		t.Fatal("interrupted run not in history")
+	}
+	if record.Status != RunStatusInterrupted {
+		t.Errorf("history status = %s", record.Status)
+	}
+	if _, ok := record.Results.VMResults[checked]; !ok {
+		t.Errorf("history lacks the checked host %s", checked)
+	}
+	if _, ok := record.Results.VMResults[unchecked]; ok {
+		t.Errorf("history has the interrupted host %s", unchecked)
+	}
+}
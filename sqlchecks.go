@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/microsoft/go-mssqldb"
+)
+
+const (
+	defaultSQLInstance     = "MSSQLSERVER"
+	defaultSQLCheckTimeout = 30 * time.Second
+)
+
+// SQLChecksConfig configures how instance and database facts are collected over TDS
+type SQLChecksConfig struct {
+	// Encrypt is passed to the driver: "true", "false", "strict" or "disable"
+	Encrypt                string        `yaml:"encrypt"`
+	TrustServerCertificate bool          `yaml:"trust_server_certificate"`
+	Timeout                time.Duration `yaml:"timeout"`
+}
+
+// HostSQLConfig lists the SQL Server instances on a host to check over TDS
+type HostSQLConfig struct {
+	// Credential for the SQL login; defaults to the host's credential.
+	// Use DOMAIN\user for Windows (NTLM) authentication.
+	Credential string              `yaml:"credential" json:"credential,omitempty"`
+	Instances  []SQLInstanceConfig `yaml:"instances" json:"instances"`
+}
+
+// SQLInstanceConfig addresses one instance; without a port, named instances
+// are resolved through the SQL Browser service
+type SQLInstanceConfig struct {
+	Name string `yaml:"name" json:"name"`
+	Port int    `yaml:"port" json:"port,omitempty"`
+}
+
+// InstanceResult holds the evaluated checks for one instance and its databases
+type InstanceResult struct {
+	Name      string
+	Checks    []CheckItem
+	Databases map[string][]CheckItem
+}
+
+// sqlConfigFor returns the TDS settings for hostname, or nil when none are configured
+func (c *Config) sqlConfigFor(hostname string) *HostSQLConfig {
+	if h := c.inventoryHost(hostname); h != nil && h.SQL != nil && len(h.SQL.Instances) > 0 {
+		return h.SQL
+	}
+	return nil
+}
+
+// collectSQLFacts connects to every configured instance on hostname and
+// gathers instance and database facts from live metadata
+func collectSQLFacts(ctx context.Context, hostname string, hostCfg *HostSQLConfig) []InstanceFacts {
+	var cred *Credential
+	var credErr error
+	if hostCfg.Credential != "" {
+		cred, credErr = resolveCredential(ctx, hostCfg.Credential)
+	} else {
+		cred, credErr = resolveHostCredential(ctx, hostname)
+	}
+
+	results := make([]InstanceFacts, 0, len(hostCfg.Instances))
+	for _, inst := range hostCfg.Instances {
+		name := inst.Name
+		if name == "" {
+			name = defaultSQLInstance
+		}
+		if credErr != nil {
+			results = append(results, connectionFailure(name, credErr))
+			continue
+		}
+		results = append(results, collectInstanceFacts(ctx, hostname, name, inst.Port, cred))
+	}
+	return results
+}
+
+func connectionFailure(instance string, err error) InstanceFacts {
+	return InstanceFacts{
+		Name:  instance,
+		Facts: map[string]any{"connected": false, "error": err.Error()},
+	}
+}
+
+func sqlConnString(hostname, instance string, port int, cred *Credential) string {
+	cfg := currentConfig().SQLChecks
+	u := &url.URL{Scheme: "sqlserver", Host: hostname}
+	if port > 0 {
+		u.Host = hostname + ":" + strconv.Itoa(port)
+	}
+	if cred != nil {
+		u.User = url.UserPassword(cred.Username, cred.Password)
+	}
+
+	q := url.Values{}
+	q.Set("database", "master")
+	q.Set("app name", "ndb-precheck")
+	if instance != defaultSQLInstance && port == 0 {
+		u.Path = instance
+	}
+	if cfg.Encrypt != "" {
+		q.Set("encrypt", cfg.Encrypt)
+	}
+	if cfg.TrustServerCertificate {
+		q.Set("TrustServerCertificate", "true")
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultSQLCheckTimeout
+	}
+	q.Set("dial timeout", strconv.Itoa(int(timeout.Seconds())))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// collectInstanceFacts queries one instance for its version and databases
+func collectInstanceFacts(ctx context.Context, hostname, instance string, port int, cred *Credential) InstanceFacts {
+	timeout := currentConfig().SQLChecks.Timeout
+	if timeout <= 0 {
+		timeout = defaultSQLCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	db, err := sql.Open("sqlserver", sqlConnString(hostname, instance, port, cred))
+	if err != nil {
+		return connectionFailure(instance, err)
+	}
+	defer db.Close()
+
+	var version, edition, level string
+	err = db.QueryRowContext(ctx, `SELECT
+		CAST(SERVERPROPERTY('ProductVersion') AS nvarchar(128)),
+		CAST(SERVERPROPERTY('Edition') AS nvarchar(128)),
+		CAST(SERVERPROPERTY('ProductLevel') AS nvarchar(128))`).Scan(&version, &edition, &level)
+	if err != nil {
+		return connectionFailure(instance, fmt.Errorf("failed to query %s\\%s: %v", hostname, instance, err))
+	}
+
+	databases, err := querySQLDatabases(ctx, db)
+	if err != nil {
+		return connectionFailure(instance, fmt.Errorf("failed to list databases on %s\\%s: %v", hostname, instance, err))
+	}
+
+	return instanceFacts(instance, version, edition, level, databases)
+}
+
+// instanceFacts maps the SERVERPROPERTY values of a connected instance to
+// the facts rules refer to
+func instanceFacts(instance, version, edition, level string, databases []DatabaseFacts) InstanceFacts {
+	major, _ := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+	return InstanceFacts{
+		Name: instance,
+		Facts: map[string]any{
+			"connected":           true,
+			"version":             version,
+			"major_version":       major,
+			"edition":             edition,
+			"product_level":       level,
+			"user_database_count": len(databases),
+		},
+		Databases: databases,
+	}
+}
+
+func querySQLDatabases(ctx context.Context, db *sql.DB) ([]DatabaseFacts, error) {
+	rows, err := db.QueryContext(ctx, `SELECT
+		d.name,
+		d.state_desc,
+		d.recovery_model_desc,
+		CAST(COALESCE(SUM(CASE WHEN mf.type = 0 THEN mf.size END), 0) * 8 / 1024.0 AS float),
+		CAST(COALESCE(SUM(CASE WHEN mf.type = 1 THEN mf.size END), 0) * 8 / 1024.0 AS float)
+	FROM sys.databases d
+	LEFT JOIN sys.master_files mf ON mf.database_id = d.database_id
+	WHERE d.database_id > 4
+	GROUP BY d.name, d.state_desc, d.recovery_model_desc`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dbs []DatabaseFacts
+	for rows.Next() {
+		var row sqlDatabaseRow
+		if err := rows.Scan(&row.Name, &row.State, &row.RecoveryModel, &row.DataMB, &row.LogMB); err != nil {
+			return nil, err
+		}
+		dbs = append(dbs, row.facts())
+	}
+	sort.Slice(dbs, func(i, j int) bool { return dbs[i].Name < dbs[j].Name })
+	return dbs, rows.Err()
+}
+
+// sqlDatabaseRow is one row of the sys.databases query
+type sqlDatabaseRow struct {
+	Name, State, RecoveryModel string
+	DataMB, LogMB              float64
+}
+
+func (r sqlDatabaseRow) facts() DatabaseFacts {
+	return DatabaseFacts{
+		Name: r.Name,
+		Facts: map[string]any{
+			"state":          r.State,
+			"recovery_model": r.RecoveryModel,
+			"data_mb":        r.DataMB,
+			"log_mb":         r.LogMB,
+		},
+	}
+}
+
+// splitDatabaseKey splits a DatabaseResults key into host, instance and
+// database. Legacy host\db keys belong to the default instance.
+func splitDatabaseKey(key string) (host, instance, database string) {
+	parts := strings.SplitN(key, "\\", 3)
+	switch len(parts) {
+	case 3:
+		return parts[0], parts[1], parts[2]
+	case 2:
+		return parts[0], defaultSQLInstance, parts[1]
+	default:
+		return "", defaultSQLInstance, key
+	}
+}
+
+// databaseInInstance reports whether a DatabaseResults key belongs to the
+// host\instance InstanceResults key
+func databaseInInstance(dbKey, instanceKey string) bool {
+	host, instance, _ := splitDatabaseKey(dbKey)
+	return strings.EqualFold(host+"\\"+instance, instanceKey)
+}
+
+// recordInstances adds TDS instance and database results to response under
+// host\instance and host\instance\database keys, reporting any failure
+func recordInstances(hostname string, instances []InstanceResult, response *BatchResponse,
+	totalChecks, passedChecks, failedChecks, errorChecks *int) bool {
+
+	hasFailure := false
+	record := func(checks []CheckItem) []CheckResult {
+		results := make([]CheckResult, 0, len(checks))
+		for _, check := range checks {
+			results = append(results, CheckResult(check))
+			*totalChecks++
+			switch check.Status {
+			case StatusSuccess:
+				*passedChecks++
+			case StatusFailed:
+				*failedChecks++
+				hasFailure = true
+			case StatusError:
+				*errorChecks++
+				hasFailure = true
+			}
+		}
+		return results
+	}
+
+	for _, inst := range instances {
+		instanceKey := hostname + "\\" + inst.Name
+		response.InstanceResults[instanceKey] = record(inst.Checks)
+		for dbName, checks := range inst.Databases {
+			response.DatabaseResults[instanceKey+"\\"+dbName] = record(checks)
+		}
+	}
+	return hasFailure
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withConfig runs the test with cfg as the running configuration
+func withConfig(t *testing.T, cfg *Config) {
+	t.Helper()
+	previous := currentConfig()
+	appConfig.Store(cfg)
+	t.Cleanup(func() { appConfig.Store(previous) })
+}
+
+func TestInstanceFacts(t *testing.T) {
+	dbs := []DatabaseFacts{
+		sqlDatabaseRow{Name: "Sales", State: "ONLINE", RecoveryModel: "FULL", DataMB: 120.5, LogMB: 8}.facts(),
+		sqlDatabaseRow{Name: "Scratch", State: "OFFLINE", RecoveryModel: "SIMPLE", DataMB: 1, LogMB: 1}.facts(),
+	}
+	inst := instanceFacts("SQL01", "15.0.2000.5", "Developer Edition (64-bit)", "RTM", dbs)
+
+	want := map[string]any{
+		"connected":           true,
+		"version":             "15.0.2000.5",
+		"major_version":       15,
+		"edition":             "Developer Edition (64-bit)",
+		"product_level":       "RTM",
+		"user_database_count": 2,
+	}
+	for k, v := range want {
+		if inst.Facts[k] != v {
+			t.Errorf("%s = %#v, want %#v", k, inst.Facts[k], v)
+		}
+	}
+	if got := dbs[0].Facts; got["state"] != "ONLINE" || got["recovery_model"] != "FULL" || got["data_mb"] != 120.5 || got["log_mb"] != float64(8) {
+		t.Errorf("database facts = %v", got)
+	}
+
+	if major := instanceFacts("SQL01", "", "", "", nil).Facts["major_version"]; major != 0 {
+		t.Errorf("major_version of an empty version = %v, want 0", major)
+	}
+}
+
+// TestInstanceFactsRules checks the facts mapped from TDS metadata carry the
+// names the built-in instance and database rules evaluate
+func TestInstanceFactsRules(t *testing.T) {
+	dbs := []DatabaseFacts{
+		sqlDatabaseRow{Name: "Sales", State: "ONLINE", RecoveryModel: "FULL", DataMB: 120.5, LogMB: 8}.facts(),
+		sqlDatabaseRow{Name: "Scratch", State: "OFFLINE", RecoveryModel: "SIMPLE", DataMB: 1, LogMB: 1}.facts(),
+	}
+	facts := &HostFacts{Instances: []InstanceFacts{
+		instanceFacts("NEW", "16.0.1000.6", "Enterprise Edition", "RTM", dbs),
+		instanceFacts("OLD", "12.0.6024.0", "Standard Edition", "SP3", nil),
+		connectionFailure("DOWN", net.ErrClosed),
+	}}
+	_, instances := builtinProfile().evaluateFacts(facts)
+
+	statuses := make(map[string]CheckStatus)
+	for _, inst := range instances {
+		for _, c := range inst.Checks {
+			statuses[inst.Name+" "+c.CheckID] = c.Status
+		}
+		for db, checks := range inst.Databases {
+			for _, c := range checks {
+				statuses[inst.Name+"\\"+db+" "+c.CheckID] = c.Status
+			}
+		}
+	}
+	want := map[string]CheckStatus{
+		"NEW instance.connectivity":            StatusSuccess,
+		"NEW instance.version":                 StatusSuccess,
+		"NEW instance.database_count":          StatusSuccess,
+		"NEW\\Sales database.state":            StatusSuccess,
+		"NEW\\Sales database.recovery_model":   StatusSuccess,
+		"NEW\\Sales database.size":             StatusSuccess,
+		"NEW\\Scratch database.state":          StatusFailed,
+		"NEW\\Scratch database.recovery_model": StatusFailed,
+		"OLD instance.version":                 StatusFailed,
+		"DOWN instance.connectivity":           StatusFailed,
+	}
+	for key, status := range want {
+		if statuses[key] != status {
+			t.Errorf("%s = %q, want %q", key, statuses[key], status)
+		}
+	}
+	if _, ok := statuses["DOWN instance.version"]; ok {
+		t.Error("version checked on an instance that could not be reached")
+	}
+	if _, ok := statuses["NEW instance.service"]; ok {
+		t.Error("service status is not collected over TDS and should be skipped")
+	}
+}
+
+func TestSQLConnString(t *testing.T) {
+	cred := &Credential{Username: `CORP\svc-ndb`, Password: "p@ss:w/rd"}
+	tests := []struct {
+		name     string
+		instance string
+		port     int
+		cfg      SQLChecksConfig
+		host     string
+		path     string
+		query    map[string]string
+	}{
+		{"default instance", defaultSQLInstance, 0, SQLChecksConfig{}, "sql01", "",
+			map[string]string{"database": "master", "dial timeout": "30"}},
+		{"named instance via browser", "REPORTING", 0, SQLChecksConfig{Encrypt: "strict"}, "sql01", "/REPORTING",
+			map[string]string{"encrypt": "strict"}},
+		{"named instance on a port", "REPORTING", 14331, SQLChecksConfig{TrustServerCertificate: true, Timeout: 5 * time.Second},
+			"sql01:14331", "", map[string]string{"TrustServerCertificate": "true", "dial timeout": "5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withConfig(t, &Config{SQLChecks: tt.cfg})
+			u, err := url.Parse(sqlConnString("sql01", tt.instance, tt.port, cred))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.Scheme != "sqlserver" || u.Host != tt.host || u.Path != tt.path {
+				t.Errorf("got %s://%s%s", u.Scheme, u.Host, u.Path)
+			}
+			if pass, _ := u.User.Password(); u.User.Username() != cred.Username || pass != cred.Password {
+				t.Errorf("credential not round-tripped: %v", u.User)
+			}
+			q := u.Query()
+			for k, v := range tt.query {
+				if q.Get(k) != v {
+					t.Errorf("%s = %q, want %q", k, q.Get(k), v)
+				}
+			}
+		})
+	}
+}
+
+func TestCollectSQLFactsFailures(t *testing.T) {
+	// A port nothing listens on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	withConfig(t, &Config{SQLChecks: SQLChecksConfig{Timeout: 5 * time.Second, Encrypt: "disable"}})
+
+	facts := collectSQLFacts(context.Background(), "127.0.0.1", &HostSQLConfig{
+		Credential: "missing",
+		Instances:  []SQLInstanceConfig{{}, {Name: "REPORTING"}},
+	})
+	if len(facts) != 2 || facts[0].Name != defaultSQLInstance || facts[1].Name != "REPORTING" {
+		t.Fatalf("facts = %+v", facts)
+	}
+	for _, f := range facts {
+		if f.Facts["connected"] != false || !strings.Contains(f.Facts["error"].(string), "missing") {
+			t.Errorf("%s: facts = %v, want the credential error", f.Name, f.Facts)
+		}
+	}
+
+	f := collectInstanceFacts(context.Background(), "127.0.0.1", defaultSQLInstance, port, &Credential{Username: "sa", Password: "x"})
+	if f.Facts["connected"] != false || !strings.Contains(f.Facts["error"].(string), "127.0.0.1\\MSSQLSERVER") {
+		t.Errorf("facts = %v, want a connection failure", f.Facts)
+	}
+	if _, ok := f.Facts["version"]; ok || len(f.Databases) != 0 {
+		t.Errorf("unreachable instance reported metadata: %+v", f)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const maxSSHOutput = 16 << 20
+
+// SSHConfig configures the PowerShell-over-SSH executor
+type SSHConfig struct {
+	Port int `yaml:"port"`
+	// Shell is the remote PowerShell binary: pwsh (default) or powershell
+	Shell string `yaml:"shell"`
+	// User is used with PrivateKey when a host has no credential
+	User                 string        `yaml:"user"`
+	PrivateKey           string        `yaml:"private_key"`
+	PrivateKeyPassphrase string        `yaml:"private_key_passphrase"`
+	KnownHosts           string        `yaml:"known_hosts"`
+	InsecureIgnoreHost   bool          `yaml:"insecure_ignore_host_key"`
+	Timeout              time.Duration `yaml:"timeout"`
+}
+
+func (s SSHConfig) validate() error {
+	switch s.Shell {
+	case "", "pwsh", "powershell", "powershell.exe", "pwsh.exe":
+	default:
+		return fmt.Errorf("executor.ssh.shell must be pwsh or powershell, got %q", s.Shell)
+	}
+	if s.KnownHosts == "" && !s.InsecureIgnoreHost {
+		return fmt.Errorf("executor.ssh needs known_hosts or insecure_ignore_host_key")
+	}
+	return nil
+}
+
+// sshExecutor runs checks.ps1 in a remote PowerShell over an SSH session
+type sshExecutor struct {
+	cfg SSHConfig
+}
+
+func (e sshExecutor) Run(ctx context.Context, hostname string, cred *Credential) (*ComprehensiveResult, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	script, err := remoteChecksScript(hostname)
+	if err != nil {
+		return nil, "", err
+	}
+	output, err := e.Exec(ctx, hostname, cred, script)
+	if err != nil {
+		return nil, "", err
+	}
+	logrus.Debugf("SSH raw output for %s: %s", hostname, output)
+	result, err := parseChecksOutput(hostname, output)
+	return result, output, err
+}
+
+// Exec runs script in a remote PowerShell and returns its standard output
+func (e sshExecutor) Exec(ctx context.Context, hostname string, cred *Credential, script string) (string, error) {
+	clientCfg, err := e.clientConfig(hostname, cred)
+	if err != nil {
+		return "", err
+	}
+
+	port := e.cfg.Port
+	if port == 0 {
+		port = 22
+	}
+	addr := net.JoinHostPort(hostname, strconv.Itoa(port))
+	dialer := net.Dialer{Timeout: clientCfg.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return "", fmt.Errorf("SSH connect to %s failed: %v", addr, err)
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, clientCfg)
+	if err != nil {
+		conn.Close()
+		return "", fmt.Errorf("SSH handshake with %s failed: %v", addr, err)
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+	defer client.Close()
+
+	// Tear the connection down if the run is cancelled or times out.
+	stop := context.AfterFunc(ctx, func() { client.Close() })
+	defer stop()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("SSH session to %s failed: %v", addr, err)
+	}
+	defer session.Close()
+
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return "", err
+	}
+
+	shell := e.cfg.Shell
+	if shell == "" {
+		shell = "pwsh"
+	}
+	if err := session.Start(stdinCommand(shell)); err != nil {
+		return "", fmt.Errorf("SSH command start on %s failed: %v", addr, err)
+	}
+	go func() {
+		io.WriteString(stdin, stdinPayload(script))
+		stdin.Close()
+	}()
+
+	output, readErr := io.ReadAll(io.LimitReader(stdout, maxSSHOutput))
+	waitErr := session.Wait()
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("SSH execution on %s aborted: %v", addr, ctx.Err())
+	}
+	if readErr != nil {
+		return "", fmt.Errorf("SSH read from %s failed: %v", addr, readErr)
+	}
+	if waitErr != nil {
+		return "", fmt.Errorf("SSH execution failed: %v: %s", waitErr, strings.TrimSpace(stderr.String()))
+	}
+
+	return string(output), nil
+}
+
+func (e sshExecutor) clientConfig(hostname string, cred *Credential) (*ssh.ClientConfig, error) {
+	cfg := &ssh.ClientConfig{Timeout: e.cfg.Timeout}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+
+	if e.cfg.InsecureIgnoreHost {
+		cfg.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+	} else {
+		cb, err := knownhosts.New(e.cfg.KnownHosts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load known_hosts %s: %v", e.cfg.KnownHosts, err)
+		}
+		cfg.HostKeyCallback = cb
+	}
+
+	if e.cfg.PrivateKey != "" {
+		key, err := os.ReadFile(e.cfg.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ssh private_key: %v", err)
+		}
+		var signer ssh.Signer
+		if e.cfg.PrivateKeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(e.cfg.PrivateKeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ssh private_key: %v", err)
+		}
+		cfg.Auth = append(cfg.Auth, ssh.PublicKeys(signer))
+	}
+
+	switch {
+	case cred != nil:
+		cfg.User = cred.Username
+		cfg.Auth = append(cfg.Auth, ssh.Password(cred.Password))
+	case e.cfg.PrivateKey != "" && e.cfg.User != "":
+		cfg.User = e.cfg.User
+	default:
+		return nil, fmt.Errorf("SSH to %s needs a credential, or executor.ssh.user with private_key", hostname)
+	}
+	return cfg, nil
+}
+
+// encodePowerShell encodes script for -EncodedCommand (base64 of UTF-16LE)
+func encodePowerShell(script string) string {
+	units := utf16.Encode([]rune(script))
+	buf := make([]byte, len(units)*2)
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(buf[i*2:], u)
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshStandIn is an in-process sshd that runs no shell: every exec request
+// gets stdout, stderr and exitStatus once its standard input ends, and the
+// script decoded from that input is recorded. Like cmd.exe behind Windows
+// OpenSSH, it refuses command lines over maxCommandLine.
+type sshStandIn struct {
+	port    int
+	hostKey ssh.PublicKey
+
+	stdout     string
+	stderr     string
+	exitStatus uint32
+	// hangAfter, when set, leaves exec requests after that many unanswered
+	// until their client goes away
+	hangAfter int
+
+	mu       sync.Mutex
+	users    []string
+	commands []string
+	scripts  []string
+}
+
+// startSSHStandIn accepts user svc with password pw, and user keyuser with
+// the authorized key
+func startSSHStandIn(t *testing.T, authorized ssh.PublicKey) *sshStandIn {
+	t.Helper()
+	_, hostPriv, _ := ed25519.GenerateKey(rand.Reader)
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == "svc" && string(pass) == "pw" {
+				return nil, nil
+			}
+			return nil, errors.New("access denied")
+		},
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if authorized != nil && c.User() == "keyuser" && string(key.Marshal()) == string(authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+	}
+	cfg.AddHostKey(hostSigner)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	s := &sshStandIn{port: l.Addr().(*net.TCPAddr).Port, hostKey: hostSigner.PublicKey(), stdout: "ok"}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, cfg)
+		}
+	}()
+	return s
+}
+
+func (s *sshStandIn) serve(conn net.Conn, cfg *ssh.ServerConfig) {
+	defer conn.Close()
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			newCh.Reject(ssh.UnknownChannelType, "sessions only")
+			continue
+		}
+		ch, chReqs, err := newCh.Accept()
+		if err != nil {
+			return
+		}
+		for req := range chReqs {
+			if req.Type != "exec" {
+				req.Reply(false, nil)
+				continue
+			}
+			var exec struct{ Command string }
+			ssh.Unmarshal(req.Payload, &exec)
+			req.Reply(true, nil)
+			if len(exec.Command) > maxCommandLine {
+				ch.Stderr().Write([]byte("The command line is too long.\r\n"))
+				ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{1}))
+				ch.Close()
+				break
+			}
+			input, _ := io.ReadAll(ch)
+			script, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(string(input)))
+
+			s.mu.Lock()
+			s.users = append(s.users, sconn.User())
+			s.commands = append(s.commands, exec.Command)
+			s.scripts = append(s.scripts, string(script))
+			stdout, stderr, status := s.stdout, s.stderr, s.exitStatus
+			hang := s.hangAfter > 0 && len(s.commands) > s.hangAfter
+			s.mu.Unlock()
+			if hang {
+				sconn.Wait()
+				return
+			}
+
+			ch.Write([]byte(stdout))
+			ch.Stderr().Write([]byte(stderr))
+			ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+			ch.Close()
+			break
+		}
+	}
+}
+
+// respond sets the output of the next exec requests
+func (s *sshStandIn) respond(stdout, stderr string, exitStatus uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stdout, s.stderr, s.exitStatus = stdout, stderr, exitStatus
+}
+
+// last returns the user, command and decoded script of the latest exec
+func (s *sshStandIn) last() (user, command, script string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.commands) - 1
+	if n < 0 {
+		return "", "", ""
+	}
+	return s.users[n], s.commands[n], s.scripts[n]
+}
+
+// writeClientKey writes a new OpenSSH private key, encrypted if passphrase
+// is set, and returns its path and public key
+func writeClientKey(t *testing.T, passphrase string) (string, ssh.PublicKey) {
+	t.Helper()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	var block *pem.Block
+	var err error
+	if passphrase != "" {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte(passphrase))
+	} else {
+		block, err = ssh.MarshalPrivateKey(priv, "")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sshPub, _ := ssh.NewPublicKey(pub)
+	return path, sshPub
+}
+
+func TestSSHExecutorPasswordAuth(t *testing.T) {
+	s := startSSHStandIn(t, nil)
+	s.respond("done\n", "", 0)
+	e := sshExecutor{cfg: SSHConfig{Port: s.port, InsecureIgnoreHost: true}}
+
+	script := "Write-Output 'ünïcode'\n"
+	out, err := e.Exec(context.Background(), "127.0.0.1", &Credential{Username: "svc", Password: "pw"}, script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "done\n" {
+		t.Errorf("output = %q", out)
+	}
+	user, command, got := s.last()
+	if user != "svc" || !strings.HasPrefix(command, "pwsh -NoLogo -NoProfile -NonInteractive -EncodedCommand ") {
+		t.Errorf("user %q ran %q", user, command)
+	}
+	if got != script {
+		t.Errorf("script = %q, want %q", got, script)
+	}
+
+	// Far past what -EncodedCommand could carry within maxCommandLine
+	large := strings.Repeat("Write-Output 'padding'\n", 1000)
+	if _, err := e.Exec(context.Background(), "127.0.0.1", &Credential{Username: "svc", Password: "pw"}, large); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, got := s.last(); got != large {
+		t.Errorf("large script arrived as %d bytes, want %d", len(got), len(large))
+	}
+
+	e.cfg.Shell = "powershell"
+	if _, err := e.Exec(context.Background(), "127.0.0.1", &Credential{Username: "svc", Password: "pw"}, script); err != nil {
+		t.Fatal(err)
+	}
+	if _, command, _ := s.last(); !strings.HasPrefix(command, "powershell -NoLogo") {
+		t.Errorf("command = %q, want the configured shell", command)
+	}
+
+	_, err = e.Exec(context.Background(), "127.0.0.1", &Credential{Username: "svc", Password: "wrong"}, script)
+	if err == nil || !strings.Contains(err.Error(), "SSH handshake") {
+		t.Errorf("wrong password: error = %v", err)
+	}
+}
+
+func TestSSHExecutorKeyAuth(t *testing.T) {
+	for _, passphrase := range []string{"", "correct horse"} {
+		t.Run("passphrase="+passphrase, func(t *testing.T) {
+			keyPath, pub := writeClientKey(t, passphrase)
+			s := startSSHStandIn(t, pub)
+
+			knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+			line := knownhosts.Line([]string{knownhosts.Normalize(net.JoinHostPort("127.0.0.1", strconv.Itoa(s.port)))}, s.hostKey)
+			os.WriteFile(knownHosts, []byte(line+"\n"), 0o600)
+
+			e := sshExecutor{cfg: SSHConfig{
+				Port:                 s.port,
+				User:                 "keyuser",
+				PrivateKey:           keyPath,
+				PrivateKeyPassphrase: passphrase,
+				KnownHosts:           knownHosts,
+			}}
+			if _, err := e.Exec(context.Background(), "127.0.0.1", nil, "hostname"); err != nil {
+				t.Fatal(err)
+			}
+			if user, _, _ := s.last(); user != "keyuser" {
+				t.Errorf("user = %q, want keyuser", user)
+			}
+
+			// A different host key must be refused
+			other, _ := writeClientKey(t, "")
+			otherSigner, _ := ssh.ParsePrivateKey(mustRead(t, other))
+			line = knownhosts.Line([]string{knownhosts.Normalize(net.JoinHostPort("127.0.0.1", strconv.Itoa(s.port)))}, otherSigner.PublicKey())
+			os.WriteFile(knownHosts, []byte(line+"\n"), 0o600)
+			_, err := e.Exec(context.Background(), "127.0.0.1", nil, "hostname")
+			if err == nil || !strings.Contains(err.Error(), "SSH handshake") {
+				t.Errorf("unknown host key: error = %v", err)
+			}
+		})
+	}
+}
+
+func TestSSHExecutorRun(t *testing.T) {
+	s := startSSHStandIn(t, nil)
+	e := sshExecutor{cfg: SSHConfig{Port: s.port, InsecureIgnoreHost: true}}
+	cred := &Credential{Username: "svc", Password: "pw"}
+
+	stdout := `{"Success":true,"Facts":{"vm":{"memory_gb":16},"instances":[{"name":"MSSQLSERVER","facts":{"connected":true},"databases":[{"name":"Sales","facts":{"state":"ONLINE"}}]}]}}` + "\r\n"
+	s.respond(stdout, "", 0)
+	result, output, err := e.Run(context.Background(), "127.0.0.1", cred)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != stdout {
+		t.Errorf("raw output = %q, want stdout as printed", output)
+	}
+	if result.Target != "127.0.0.1" || !result.Success || result.Facts == nil {
+		t.Fatalf("result = %+v", result)
+	}
+	if got := result.Facts.VM["memory_gb"]; got != float64(16) {
+		t.Errorf("memory_gb = %v", got)
+	}
+	if len(result.Facts.Instances) != 1 || result.Facts.Instances[0].Databases[0].Name != "Sales" {
+		t.Errorf("instances = %+v", result.Facts.Instances)
+	}
+	if _, _, script := s.last(); !strings.Contains(script, "ConvertTo-Json") || !strings.Contains(script, "'127.0.0.1'") {
+		t.Errorf("script does not wrap checks.ps1 for the host: %.200s", script)
+	}
+
+	s.respond("WARNING: not json", "", 0)
+	_, output, err = e.Run(context.Background(), "127.0.0.1", cred)
+	if err == nil || !strings.Contains(err.Error(), "failed to parse check output") {
+		t.Errorf("bad output: error = %v", err)
+	}
+	if output != "WARNING: not json" {
+		t.Errorf("bad output: raw output = %q, want it kept", output)
+	}
+
+	s.respond("", "The term 'Get-Foo' is not recognized", 1)
+	if _, _, err := e.Run(context.Background(), "127.0.0.1", cred); err == nil || !strings.Contains(err.Error(), "Get-Foo") {
+		t.Errorf("failed script: error = %v, want stderr", err)
+	}
+}
+
+func TestSSHExecutorNeedsIdentity(t *testing.T) {
+	e := sshExecutor{cfg: SSHConfig{InsecureIgnoreHost: true}}
+	_, err := e.Exec(context.Background(), "127.0.0.1", nil, "hostname")
+	if err == nil || !strings.Contains(err.Error(), "needs a credential") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func mustRead(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
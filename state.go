@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultStateStorePath = "./data/state.json"
+
+// Entity types in the state store
+const (
+	EntityVM       = "vm"
+	EntityInstance = "instance"
+	EntityDatabase = "database"
+)
+
+// StateConfig locates the latest-known-state store
+type StateConfig struct {
+	StorePath string `yaml:"store_path"`
+}
+
+// CheckState is the most recent result of one check on one entity
+type CheckState struct {
+	CheckResult
+	CheckedAt string `json:"checked_at"`
+	RunID     string `json:"run_id"`
+}
+
+// EntityState is the latest known result of every check ever run on one VM,
+// instance or database, whichever run it came from
+type EntityState struct {
+	// Key is the entity's result key: host, host\instance or host\instance\db
+	Key           string       `json:"key"`
+	Type          string       `json:"type"`
+	Host          string       `json:"host"`
+	LastCheckedAt string       `json:"last_checked_at"`
+	LastRunID     string       `json:"last_run_id"`
+	Checks        []CheckState `json:"checks"`
+}
+
+// failing reports whether any of the entity's latest check results fail it
+func (e *EntityState) failing() bool {
+	for _, check := range e.Checks {
+		if check.Status.Fails() {
+			return true
+		}
+	}
+	return false
+}
+
+// StateStore keeps the latest known state of every entity in a JSON file
+type StateStore struct {
+	mu       sync.Mutex
+	path     string
+	entities map[string]*EntityState
+}
+
+// StateFilter selects entities; zero fields match everything
+type StateFilter struct {
+	Type string
+	Host string
+	// Status is "passed" or "failed"
+	Status string
+	// StaleFor matches entities not checked for at least this long
+	StaleFor time.Duration
+	// CheckedWithin matches entities checked at most this long ago
+	CheckedWithin time.Duration
+}
+
+// ===== Globals =====
+
+var stateStore *StateStore
+
+// openStateStore loads the state at path, starting empty if it is missing
+func openStateStore(path string) (*StateStore, error) {
+	if path == "" {
+		path = defaultStateStorePath
+	}
+	store := &StateStore{path: path, entities: make(map[string]*EntityState)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state store %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, &store.entities); err != nil {
+		return nil, fmt.Errorf("failed to parse state store %s: %v", path, err)
+	}
+	return store, nil
+}
+
+// save writes the store atomically; callers hold s.mu
+func (s *StateStore) save() error {
+	data, err := json.Marshal(s.entities)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Record merges the results of a finished run into the store. Each check's
+// result replaces the previous one for the same entity and check id (or
+// check name, for results without an id).
+func (s *StateStore) Record(r *BatchResponse, at time.Time) error {
+	checkedAt := at.UTC().Format(time.RFC3339)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for entityType, results := range map[string]map[string][]CheckResult{
+		EntityVM:       r.VMResults,
+		EntityInstance: r.InstanceResults,
+		EntityDatabase: r.DatabaseResults,
+	} {
+		for key, checks := range results {
+			e, ok := s.entities[key]
+			if !ok {
+				host, _, _ := strings.Cut(key, "\\")
+				e = &EntityState{Key: key, Type: entityType, Host: host}
+				s.entities[key] = e
+			}
+			e.LastCheckedAt, e.LastRunID = checkedAt, r.RunID
+			for _, check := range checks {
+				state := CheckState{CheckResult: check, CheckedAt: checkedAt, RunID: r.RunID}
+				replaced := false
+				for i := range e.Checks {
+					if sameCheck(e.Checks[i].CheckResult, check) {
+						e.Checks[i], replaced = state, true
+						break
+					}
+				}
+				if !replaced {
+					e.Checks = append(e.Checks, state)
+				}
+			}
+		}
+	}
+	return s.save()
+}
+
+func sameCheck(a, b CheckResult) bool {
+	if a.CheckID != "" || b.CheckID != "" {
+		return a.CheckID == b.CheckID
+	}
+	return a.Check == b.Check
+}
+
+// Query returns copies of the matching entities, least recently checked first
+func (s *StateStore) Query(f StateFilter, now time.Time) []EntityState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := []EntityState{}
+	for _, e := range s.entities {
+		if f.Type != "" && e.Type != f.Type {
+			continue
+		}
+		if f.Host != "" && !strings.EqualFold(e.Host, f.Host) {
+			continue
+		}
+		if f.Status != "" && (f.Status == "failed") != e.failing() {
+			continue
+		}
+		checked, err := time.Parse(time.RFC3339, e.LastCheckedAt)
+		if err != nil {
+			continue
+		}
+		age := now.Sub(checked)
+		if f.StaleFor > 0 && age < f.StaleFor {
+			continue
+		}
+		if f.CheckedWithin > 0 && age > f.CheckedWithin {
+			continue
+		}
+		copied := *e
+		copied.Checks = append([]CheckState(nil), e.Checks...)
+		list = append(list, copied)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].LastCheckedAt != list[j].LastCheckedAt {
+			return list[i].LastCheckedAt < list[j].LastCheckedAt
+		}
+		return list[i].Key < list[j].Key
+	})
+	return list
+}
+
+// recordState stores a finished run's results as the latest known state
+func recordState(r *BatchResponse) {
+	if stateStore == nil {
+		return
+	}
+	if err := stateStore.Record(r, time.Now()); err != nil {
+		logrus.Errorf("Failed to save latest state for run %s: %v", r.RunID, err)
+	}
+}
+
+// parseAge parses a duration like 36h or 7d
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+func parseStateFilter(c *gin.Context) (StateFilter, error) {
+	filter := StateFilter{
+		Type:   c.Query("type"),
+		Host:   c.Query("host"),
+		Status: c.Query("status"),
+	}
+	switch filter.Type {
+	case "", EntityVM, EntityInstance, EntityDatabase:
+	default:
+		return filter, fmt.Errorf("type must be vm, instance or database")
+	}
+	switch filter.Status {
+	case "", "passed", "failed":
+	default:
+		return filter, fmt.Errorf("status must be passed or failed")
+	}
+	for name, dst := range map[string]*time.Duration{"stale_for": &filter.StaleFor, "checked_within": &filter.CheckedWithin} {
+		if v := c.Query(name); v != "" {
+			d, err := parseAge(v)
+			if err != nil {
+				return filter, fmt.Errorf("%s must be a duration such as 12h or 7d", name)
+			}
+			*dst = d
+		}
+	}
+	return filter, nil
+}
+
+// ===== API Handlers =====
+
+// handleStateQuery returns the latest known state of matching entities, e.g.
+// ?type=vm&stale_for=7d for VMs not checked in the last week
+func handleStateQuery(c *gin.Context) {
+	if stateStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "State store unavailable"})
+		return
+	}
+	filter, err := parseStateFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entities := stateStore.Query(filter, time.Now())
+	c.JSON(http.StatusOK, StateResponse{Entities: entities, Total: len(entities)})
+}
@@ -0,0 +1,121 @@
+package main
+
+import "fmt"
+
+// CheckStatus is the outcome of one check
+type CheckStatus string
+
+// Check outcomes. Only FAILED and ERROR fail an entity; the severity policy
+// decides which of FAILED, WARNING or INFO a check that did not pass reports.
+const (
+	StatusSuccess CheckStatus = "SUCCESS"
+	StatusInfo    CheckStatus = "INFO"
+	StatusWarning CheckStatus = "WARNING"
+	StatusFailed  CheckStatus = "FAILED"
+	StatusError   CheckStatus = "ERROR"
+	StatusWaived  CheckStatus = "WAIVED"
+)
+
+// Fails reports whether the status fails the entity it belongs to
+func (s CheckStatus) Fails() bool {
+	return s == StatusFailed || s == StatusError
+}
+
+// Severity ranks how much a check matters for migration
+type Severity string
+
+// Severities, from least to most important
+const (
+	SeverityInfo     Severity = "INFO"
+	SeverityLow      Severity = "LOW"
+	SeverityMedium   Severity = "MEDIUM"
+	SeverityHigh     Severity = "HIGH"
+	SeverityCritical Severity = "CRITICAL"
+)
+
+// severities lists every severity from most to least important
+var severities = []Severity{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityInfo}
+
+// Valid reports whether s is a known severity
+func (s Severity) Valid() bool {
+	for _, known := range severities {
+		if s == known {
+			return true
+		}
+	}
+	return false
+}
+
+// SeverityPolicy maps the severity of a check that did not pass to the
+// status it reports: FAILED, WARNING or INFO
+type SeverityPolicy map[Severity]CheckStatus
+
+func defaultSeverityPolicy() SeverityPolicy {
+	return SeverityPolicy{
+		SeverityCritical: StatusFailed,
+		SeverityHigh:     StatusFailed,
+		SeverityMedium:   StatusWarning,
+		SeverityLow:      StatusWarning,
+		SeverityInfo:     StatusInfo,
+	}
+}
+
+func (p SeverityPolicy) validate() error {
+	for sev, status := range p {
+		if !sev.Valid() {
+			return fmt.Errorf("severity_policy has unknown severity %q", sev)
+		}
+		switch status {
+		case StatusFailed, StatusWarning, StatusInfo:
+		default:
+			return fmt.Errorf("severity_policy for %s must be FAILED, WARNING or INFO, got %q", sev, status)
+		}
+	}
+	return nil
+}
+
+// statusFor returns the status a failing check of severity sev reports
+func (p SeverityPolicy) statusFor(sev Severity) CheckStatus {
+	if status, ok := p[sev]; ok {
+		return status
+	}
+	return StatusFailed
+}
+
+// severityBreakdown counts checks that did not pass by severity and status
+func severityBreakdown(results ...map[string][]CheckResult) map[Severity]map[CheckStatus]int {
+	breakdown := make(map[Severity]map[CheckStatus]int, len(severities))
+	for _, sev := range severities {
+		breakdown[sev] = map[CheckStatus]int{
+			StatusFailed:  0,
+			StatusError:   0,
+			StatusWarning: 0,
+			StatusWaived:  0,
+		}
+	}
+	for _, r := range results {
+		for _, checks := range r {
+			for _, check := range checks {
+				if counts, ok := breakdown[check.Severity]; ok && check.Status != StatusSuccess && check.Status != StatusInfo {
+					counts[check.Status]++
+				}
+			}
+		}
+	}
+	return breakdown
+}
+
+// countStatus returns the number of checks in response with status
+func countStatus(response *BatchResponse, status CheckStatus) int {
+	count := 0
+	for _, results := range []map[string][]CheckResult{response.VMResults, response.InstanceResults, response.DatabaseResults} {
+		for _, checks := range results {
+			for _, check := range checks {
+				if check.Status == status {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
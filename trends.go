@@ -0,0 +1,308 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Trend bucket sizes
+const (
+	BucketDay  = "day"
+	BucketWeek = "week"
+)
+
+const maxTrendBuckets = 366
+
+var errTooManyBuckets = fmt.Errorf("more than %d buckets; narrow since/until or use weekly buckets", maxTrendBuckets)
+
+// TrendFilter narrows the entities and checks a trend is computed over
+type TrendFilter struct {
+	Group    string
+	Tag      string
+	CheckIDs []string
+	// Level is vm, instance or database; empty counts every level and
+	// scores VMs
+	Level  string
+	Bucket string
+	Since  time.Time
+	Until  time.Time
+}
+
+// TrendPoint is the latest known state at the end of one bucket
+type TrendPoint struct {
+	Bucket string `json:"bucket"`
+	// Runs finished during the bucket
+	Runs         int            `json:"runs"`
+	Entities     int            `json:"entities"`
+	Passed       int            `json:"passed"`
+	Failed       int            `json:"failed"`
+	Errors       int            `json:"errors"`
+	Warnings     int            `json:"warnings"`
+	Waived       int            `json:"waived"`
+	AverageScore int            `json:"average_score"`
+	Readiness    map[string]int `json:"readiness"`
+}
+
+// bucketStart truncates t to the start of its day or ISO week (Monday), UTC
+func bucketStart(t time.Time, bucket string) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if bucket == BucketWeek {
+		offset := (int(day.Weekday()) + 6) % 7
+		day = day.AddDate(0, 0, -offset)
+	}
+	return day
+}
+
+func nextBucket(t time.Time, bucket string) time.Time {
+	if bucket == BucketWeek {
+		return t.AddDate(0, 0, 7)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// matchesHost reports whether hostname is in the filter's group and tag
+func (f TrendFilter) matchesHost(hostname string) bool {
+	if f.Group != "" && currentConfig().groupForHost(hostname) != f.Group {
+		return false
+	}
+	if f.Tag != "" {
+		for _, tag := range currentConfig().tagsForHost(hostname) {
+			if tag == f.Tag {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// filtered returns the part of state the filter covers
+func (f TrendFilter) filtered(state *BatchResponse) *BatchResponse {
+	keep := func(results map[string][]CheckResult) map[string][]CheckResult {
+		out := make(map[string][]CheckResult)
+		for key, checks := range results {
+			host, _, _ := strings.Cut(key, "\\")
+			if !f.matchesHost(host) {
+				continue
+			}
+			var selected []CheckResult
+			for _, check := range checks {
+				if len(f.CheckIDs) == 0 || containsString(f.CheckIDs, check.CheckID) {
+					selected = append(selected, check)
+				}
+			}
+			if len(selected) > 0 {
+				out[key] = selected
+			}
+		}
+		return out
+	}
+	return &BatchResponse{
+		VMResults:       keep(state.VMResults),
+		InstanceResults: keep(state.InstanceResults),
+		DatabaseResults: keep(state.DatabaseResults),
+	}
+}
+
+// point measures the filtered state at the end of a bucket
+func (f TrendFilter) point(state *BatchResponse) TrendPoint {
+	r := f.filtered(state)
+	counted := r
+	switch f.Level {
+	case EntityVM:
+		counted = &BatchResponse{VMResults: r.VMResults}
+	case EntityInstance:
+		counted = &BatchResponse{InstanceResults: r.InstanceResults}
+	case EntityDatabase:
+		counted = &BatchResponse{DatabaseResults: r.DatabaseResults}
+	}
+
+	p := TrendPoint{
+		Passed:    countStatus(counted, StatusSuccess),
+		Failed:    countStatus(counted, StatusFailed),
+		Errors:    countStatus(counted, StatusError),
+		Warnings:  countStatus(counted, StatusWarning),
+		Waived:    countStatus(counted, StatusWaived),
+		Readiness: tierCounts(),
+	}
+	var fitments []Fitment
+	switch f.Level {
+	case EntityInstance:
+		for key := range r.InstanceResults {
+			fitments = append(fitments, instanceFitment(r, key))
+		}
+	case EntityDatabase:
+		for _, checks := range r.DatabaseResults {
+			fitments = append(fitments, fitmentOf(checks))
+		}
+	default:
+		for _, host := range resultHosts(r) {
+			fitments = append(fitments, vmFitment(r, host))
+		}
+	}
+	total := 0
+	for _, fit := range fitments {
+		total += fit.Score
+		p.Readiness[fit.Readiness]++
+	}
+	p.Entities = len(fitments)
+	if len(fitments) > 0 {
+		p.AverageScore = total / len(fitments)
+	}
+	return p
+}
+
+// computeTrends replays the run history, carrying the latest result of
+// every check forward, and measures the state at the end of each bucket
+func computeTrends(f TrendFilter) ([]TrendPoint, error) {
+	state := &BatchResponse{
+		VMResults:       make(map[string][]CheckResult),
+		InstanceResults: make(map[string][]CheckResult),
+		DatabaseResults: make(map[string][]CheckResult),
+	}
+	points := []TrendPoint{}
+	var current, end time.Time
+	runs := 0
+	flush := func() {
+		p := f.point(state)
+		p.Bucket, p.Runs = current.Format("2006-01-02"), runs
+		points = append(points, p)
+	}
+
+	var tooMany bool
+	err := readHistory(time.Time{}, f.Until, func(rec HistoryRecord, finished time.Time) {
+		if tooMany {
+			return
+		}
+		// Runs before the window only seed the state
+		if !f.Since.IsZero() && finished.Before(f.Since) {
+			mergeInto(state, rec.Results)
+			return
+		}
+		if current.IsZero() {
+			current = bucketStart(finished, f.Bucket)
+			if !f.Since.IsZero() {
+				current = bucketStart(f.Since, f.Bucket)
+			}
+			end = nextBucket(current, f.Bucket)
+		}
+		for !finished.Before(end) {
+			flush()
+			current, end, runs = end, nextBucket(end, f.Bucket), 0
+			if len(points) >= maxTrendBuckets {
+				tooMany = true
+				return
+			}
+		}
+		mergeInto(state, rec.Results)
+		runs++
+	})
+	if err != nil {
+		return nil, err
+	}
+	if tooMany {
+		return nil, errTooManyBuckets
+	}
+	if !current.IsZero() {
+		flush()
+	}
+	return points, nil
+}
+
+// mergeInto overlays a run's results on state, check by check
+func mergeInto(state, results *BatchResponse) {
+	pairs := [][2]map[string][]CheckResult{
+		{state.VMResults, results.VMResults},
+		{state.InstanceResults, results.InstanceResults},
+		{state.DatabaseResults, results.DatabaseResults},
+	}
+	for _, pair := range pairs {
+		for key, checks := range pair[1] {
+			pair[0][key] = mergeChecks(pair[0][key], checks)
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTime accepts RFC 3339 or a date (YYYY-MM-DD, UTC)
+func parseTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t, err = time.Parse("2006-01-02", s)
+	}
+	return t, err
+}
+
+func parseTrendFilter(c *gin.Context) (TrendFilter, error) {
+	f := TrendFilter{
+		Group:  c.Query("group"),
+		Tag:    c.Query("tag"),
+		Level:  c.Query("level"),
+		Bucket: c.DefaultQuery("bucket", BucketDay),
+	}
+	for _, v := range c.QueryArray("check_id") {
+		for _, id := range strings.Split(v, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				f.CheckIDs = append(f.CheckIDs, id)
+			}
+		}
+	}
+	switch f.Level {
+	case "", EntityVM, EntityInstance, EntityDatabase:
+	default:
+		return f, fmt.Errorf("level must be vm, instance or database")
+	}
+	switch f.Bucket {
+	case BucketDay, BucketWeek:
+	default:
+		return f, fmt.Errorf("bucket must be day or week")
+	}
+	for name, dst := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		if v := c.Query(name); v != "" {
+			t, err := parseTime(v)
+			if err != nil {
+				return f, fmt.Errorf("%s must be RFC 3339 or YYYY-MM-DD", name)
+			}
+			*dst = t
+		}
+	}
+	return f, nil
+}
+
+// ===== API Handlers =====
+
+// handleTrends returns check counts and readiness over time, one point per
+// day or week, computed from the persisted run history
+func handleTrends(c *gin.Context) {
+	f, err := parseTrendFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	points, err := computeTrends(f)
+	if errors.Is(err, errTooManyBuckets) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		logrus.Errorf("Failed to read run history: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read run history"})
+		return
+	}
+	c.JSON(http.StatusOK, TrendsResponse{Bucket: f.Bucket, Level: f.Level, Points: points})
+}
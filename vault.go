@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultVaultCacheTTL = 5 * time.Minute
+
+// VaultConfig configures the Vault KV v2 secret backend
+type VaultConfig struct {
+	Address   string `yaml:"address"`
+	Namespace string `yaml:"namespace"`
+	// Token authenticates directly; falls back to VAULT_TOKEN
+	Token string `yaml:"token"`
+	// RoleID and SecretID authenticate with AppRole instead of a static token
+	RoleID      string        `yaml:"role_id"`
+	SecretID    string        `yaml:"secret_id"`
+	Mount       string        `yaml:"mount"`
+	UsernameKey string        `yaml:"username_key"`
+	PasswordKey string        `yaml:"password_key"`
+	CACert      string        `yaml:"ca_cert"`
+	CacheTTL    time.Duration `yaml:"cache_ttl"`
+	Timeout     time.Duration `yaml:"timeout"`
+}
+
+type vaultCacheEntry struct {
+	cred    Credential
+	expires time.Time
+}
+
+// VaultProvider reads credentials from a Vault KV v2 engine over HTTP.
+// Secrets and the AppRole login token are cached until their lease expires.
+type VaultProvider struct {
+	cfg    VaultConfig
+	client *http.Client
+
+	mu         sync.Mutex
+	cache      map[string]vaultCacheEntry
+	token      string
+	tokenUntil time.Time
+}
+
+func newVaultProvider(cfg VaultConfig) (*VaultProvider, error) {
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("secrets.vault.address is required")
+	}
+	if cfg.Mount == "" {
+		cfg.Mount = "secret"
+	}
+	if cfg.UsernameKey == "" {
+		cfg.UsernameKey = "username"
+	}
+	if cfg.PasswordKey == "" {
+		cfg.PasswordKey = "password"
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = defaultVaultCacheTTL
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.Token == "" && cfg.RoleID == "" {
+		cfg.Token = os.Getenv("VAULT_TOKEN")
+	}
+	if cfg.Token == "" && cfg.RoleID == "" {
+		return nil, fmt.Errorf("secrets.vault needs a token, VAULT_TOKEN, or role_id/secret_id")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.CACert != "" {
+		pem, err := os.ReadFile(cfg.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vault ca_cert: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("vault ca_cert %s has no certificates", cfg.CACert)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &VaultProvider{
+		cfg:    cfg,
+		client: &http.Client{Transport: transport, Timeout: cfg.Timeout},
+		cache:  make(map[string]vaultCacheEntry),
+	}, nil
+}
+
+// Resolve reads the KV v2 secret at path and maps it to a credential
+func (v *VaultProvider) Resolve(ctx context.Context, path string) (*Credential, error) {
+	path = strings.Trim(path, "/")
+
+	v.mu.Lock()
+	if e, ok := v.cache[path]; ok && time.Now().Before(e.expires) {
+		v.mu.Unlock()
+		cred := e.cred
+		return &cred, nil
+	}
+	v.mu.Unlock()
+
+	token, err := v.authToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		LeaseDuration int `json:"lease_duration"`
+		Data          struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	url := fmt.Sprintf("%s/v1/%s/data/%s", strings.TrimRight(v.cfg.Address, "/"), v.cfg.Mount, path)
+	if err := v.do(ctx, http.MethodGet, url, token, nil, &resp); err != nil {
+		return nil, fmt.Errorf("vault read of %s failed: %v", path, err)
+	}
+
+	username, _ := resp.Data.Data[v.cfg.UsernameKey].(string)
+	password, _ := resp.Data.Data[v.cfg.PasswordKey].(string)
+	if username == "" || password == "" {
+		return nil, fmt.Errorf("vault secret %s lacks %q or %q", path, v.cfg.UsernameKey, v.cfg.PasswordKey)
+	}
+	cred := Credential{Name: vaultRefPrefix + path, Username: username, Password: password}
+
+	ttl := v.cfg.CacheTTL
+	if resp.LeaseDuration > 0 && time.Duration(resp.LeaseDuration)*time.Second < ttl {
+		ttl = time.Duration(resp.LeaseDuration) * time.Second
+	}
+	v.mu.Lock()
+	v.cache[path] = vaultCacheEntry{cred: cred, expires: time.Now().Add(ttl)}
+	v.mu.Unlock()
+	return &cred, nil
+}
+
+// authToken returns the static token or a cached AppRole login token
+func (v *VaultProvider) authToken(ctx context.Context) (string, error) {
+	if v.cfg.RoleID == "" {
+		return v.cfg.Token, nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.token != "" && (v.tokenUntil.IsZero() || time.Now().Before(v.tokenUntil)) {
+		return v.token, nil
+	}
+
+	var resp struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int    `json:"lease_duration"`
+		} `json:"auth"`
+	}
+	body := map[string]string{"role_id": v.cfg.RoleID, "secret_id": v.cfg.SecretID}
+	url := strings.TrimRight(v.cfg.Address, "/") + "/v1/auth/approle/login"
+	if err := v.do(ctx, http.MethodPost, url, "", body, &resp); err != nil {
+		return "", fmt.Errorf("vault approle login failed: %v", err)
+	}
+
+	v.token = resp.Auth.ClientToken
+	// Renew a little before the lease runs out. Tokens without a lease
+	// (root and dev-mode tokens) never expire; tokenUntil stays zero.
+	v.tokenUntil = time.Time{}
+	if lease := time.Duration(resp.Auth.LeaseDuration) * time.Second; lease > 0 {
+		v.tokenUntil = time.Now().Add(lease * 9 / 10)
+	}
+	return v.token, nil
+}
+
+func (v *VaultProvider) do(ctx context.Context, method, url, token string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if v.cfg.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.cfg.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Vault error bodies only carry messages, never secret data.
+		var verr struct {
+			Errors []string `json:"errors"`
+		}
+		json.Unmarshal(data, &verr)
+		return fmt.Errorf("status %s: %s", resp.Status, strings.Join(verr.Errors, "; "))
+	}
+	return json.Unmarshal(data, out)
+}
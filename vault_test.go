@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeVault stands in for a dev-mode Vault server with a KV v2 engine
+// mounted at secret/ and AppRole auth enabled
+type fakeVault struct {
+	mu        sync.Mutex
+	secrets   map[string]map[string]any
+	lease     int
+	logins    int
+	reads     int
+	namespace string
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.namespace = r.Header.Get("X-Vault-Namespace")
+	fail := func(status int, msg string) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]any{"errors": []string{msg}})
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/auth/approle/login":
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["role_id"] != "role" || body["secret_id"] != "secret" {
+			fail(http.StatusBadRequest, "invalid role or secret ID")
+			return
+		}
+		f.logins++
+		json.NewEncoder(w).Encode(map[string]any{
+			"auth": map[string]any{"client_token": "approle-token", "lease_duration": f.lease},
+		})
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/secret/data/"):
+		if token := r.Header.Get("X-Vault-Token"); token != "root" && token != "approle-token" {
+			fail(http.StatusForbidden, "permission denied")
+			return
+		}
+		f.reads++
+		data, ok := f.secrets[strings.TrimPrefix(r.URL.Path, "/v1/secret/data/")]
+		if !ok {
+			fail(http.StatusNotFound, "")
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"data": data}})
+	default:
+		fail(http.StatusNotFound, "no handler for "+r.URL.Path)
+	}
+}
+
+func newFakeVault(t *testing.T) (*fakeVault, *httptest.Server) {
+	f := &fakeVault{secrets: map[string]map[string]any{
+		"sql/wave-1":  {"username": "CORP\\svc-ndb", "password": "s3cret"},
+		"sql/wave-2":  {"username": "CORP\\svc-ndb2", "password": "s3cret2"},
+		"sql/partial": {"username": "only-a-user"},
+	}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func TestVaultProviderToken(t *testing.T) {
+	f, srv := newFakeVault(t)
+	v, err := newVaultProvider(VaultConfig{Address: srv.URL + "/", Token: "root", Namespace: "ops"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cred, err := v.Resolve(context.Background(), "/sql/wave-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.Name != "vault:sql/wave-1" || cred.Username != "CORP\\svc-ndb" || cred.Password != "s3cret" {
+		t.Errorf("got %+v", cred)
+	}
+	if f.namespace != "ops" {
+		t.Errorf("namespace header = %q, want ops", f.namespace)
+	}
+
+	if _, err := v.Resolve(context.Background(), "sql/wave-1"); err != nil {
+		t.Fatal(err)
+	}
+	if f.reads != 1 {
+		t.Errorf("reads = %d, want the second resolve served from the cache", f.reads)
+	}
+}
+
+func TestVaultProviderErrors(t *testing.T) {
+	_, srv := newFakeVault(t)
+	tests := []struct {
+		name  string
+		cfg   VaultConfig
+		path  string
+		error string
+	}{
+		{"missing password", VaultConfig{Token: "root"}, "sql/partial", `lacks "username" or "password"`},
+		{"missing secret", VaultConfig{Token: "root"}, "sql/none", "404"},
+		{"denied", VaultConfig{Token: "wrong"}, "sql/wave-1", "permission denied"},
+		{"bad approle", VaultConfig{RoleID: "role", SecretID: "wrong"}, "sql/wave-1", "approle login failed"},
+		{"custom keys", VaultConfig{Token: "root", UsernameKey: "user"}, "sql/wave-1", `lacks "user"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Address = srv.URL
+			v, err := newVaultProvider(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = v.Resolve(context.Background(), tt.path)
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.error)
+			}
+		})
+	}
+}
+
+func TestVaultProviderAppRole(t *testing.T) {
+	tests := []struct {
+		name   string
+		lease  int
+		expire bool
+		logins int
+	}{
+		{"leased token is reused", 3600, false, 1},
+		{"expired token logs in again", 3600, true, 2},
+		{"token without lease never expires", 0, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, srv := newFakeVault(t)
+			f.lease = tt.lease
+			v, err := newVaultProvider(VaultConfig{Address: srv.URL, RoleID: "role", SecretID: "secret"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := v.Resolve(context.Background(), "sql/wave-1"); err != nil {
+				t.Fatal(err)
+			}
+			if tt.expire && !v.tokenUntil.IsZero() {
+				v.tokenUntil = time.Now().Add(-time.Second)
+			}
+			cred, err := v.Resolve(context.Background(), "sql/wave-2")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cred.Username != "CORP\\svc-ndb2" {
+				t.Errorf("username = %q", cred.Username)
+			}
+			if f.logins != tt.logins {
+				t.Errorf("logins = %d, want %d", f.logins, tt.logins)
+			}
+		})
+	}
+}
+
+func TestNewVaultProviderNeedsAuth(t *testing.T) {
+	t.Setenv("VAULT_TOKEN", "")
+	if _, err := newVaultProvider(VaultConfig{Address: "http://127.0.0.1:8200"}); err == nil {
+		t.Error("expected an error without token or AppRole")
+	}
+	if _, err := newVaultProvider(VaultConfig{Token: "root"}); err == nil {
+		t.Error("expected an error without address")
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiVersion is the current API namespace. Its response shapes are frozen by
+// contracts/v1.openapi.json (see checkContract); incompatible changes need
+// a new version.
+const apiVersion = "v1"
+
+// registerAPI serves the endpoints under /api/v1 and, as deprecated aliases,
+// under /api
+func registerAPI(router *gin.Engine) {
+	versioned := router.Group("/api/"+apiVersion, requireAuth())
+	legacy := router.Group("/api", deprecatedAPI(), requireAuth())
+	for _, api := range []*gin.RouterGroup{versioned, legacy} {
+		groups := map[Role]*gin.RouterGroup{
+			RoleNone:     api,
+			RoleViewer:   api.Group("", requireRole(RoleViewer)),
+			RoleOperator: api.Group("", requireRole(RoleOperator)),
+			RoleAdmin:    api.Group("", requireRole(RoleAdmin)),
+		}
+		for _, e := range apiEndpoints() {
+			groups[e.Role].Handle(e.Method, e.Path, e.Handler)
+		}
+	}
+	router.GET("/api/"+apiVersion+"/openapi.json", handleOpenAPI)
+	router.GET("/api/openapi.json", deprecatedAPI(), handleOpenAPI)
+}
+
+// deprecatedAPI marks responses of the unversioned /api routes as deprecated
+// (RFC 9745) and links the /api/v1 route replacing them
+func deprecatedAPI() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		successor := "/api/" + apiVersion + strings.TrimPrefix(c.Request.URL.Path, "/api")
+		c.Header("Deprecation", "true")
+		c.Header("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor))
+		c.Next()
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultWaiverStorePath = "./data/waivers.json"
+
+	AuditWaiverCreate = "waiver.create"
+	AuditWaiverDelete = "waiver.delete"
+)
+
+// WaiversConfig locates the waiver store
+type WaiversConfig struct {
+	StorePath string `yaml:"store_path"`
+}
+
+// Waiver accepts a known failure of one check. Host is required; an empty
+// Instance or Database covers every instance or database below it.
+type Waiver struct {
+	ID            string `json:"id"`
+	CheckID       string `json:"check_id"`
+	Host          string `json:"host"`
+	Instance      string `json:"instance,omitempty"`
+	Database      string `json:"database,omitempty"`
+	Justification string `json:"justification"`
+	ApprovedBy    string `json:"approved_by"`
+	CreatedAt     string `json:"created_at"`
+	ExpiresAt     string `json:"expires_at"`
+}
+
+// active reports whether the waiver still applies at now
+func (w Waiver) active(now time.Time) bool {
+	expires, err := time.Parse(time.RFC3339, w.ExpiresAt)
+	return err == nil && now.Before(expires)
+}
+
+// covers reports whether the waiver applies to checkID on the given entity.
+// instance and database are empty for VM and instance checks respectively.
+func (w Waiver) covers(checkID, host, instance, database string) bool {
+	if checkID == "" || w.CheckID != checkID || !strings.EqualFold(w.Host, host) {
+		return false
+	}
+	if w.Instance != "" && !strings.EqualFold(w.Instance, instance) {
+		return false
+	}
+	if w.Database != "" && !strings.EqualFold(w.Database, database) {
+		return false
+	}
+	return true
+}
+
+// WaiverStore keeps waivers in a JSON file
+type WaiverStore struct {
+	mu      sync.Mutex
+	path    string
+	waivers map[string]Waiver
+}
+
+// ===== Globals =====
+
+var waiverStore *WaiverStore
+
+// openWaiverStore loads the waivers at path, starting empty if it is missing
+func openWaiverStore(path string) (*WaiverStore, error) {
+	if path == "" {
+		path = defaultWaiverStorePath
+	}
+	store := &WaiverStore{path: path, waivers: make(map[string]Waiver)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read waiver store %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, &store.waivers); err != nil {
+		return nil, fmt.Errorf("failed to parse waiver store %s: %v", path, err)
+	}
+	return store, nil
+}
+
+// save writes the store atomically; callers hold s.mu
+func (s *WaiverStore) save() error {
+	data, err := json.MarshalIndent(s.waivers, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Add stores a new waiver
+func (s *WaiverStore) Add(w Waiver) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.waivers[w.ID] = w
+	if err := s.save(); err != nil {
+		delete(s.waivers, w.ID)
+		return err
+	}
+	return nil
+}
+
+// Delete removes a waiver, returning it if it existed
+func (s *WaiverStore) Delete(id string) (Waiver, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok := s.waivers[id]
+	if !ok {
+		return Waiver{}, false, nil
+	}
+	delete(s.waivers, id)
+	if err := s.save(); err != nil {
+		s.waivers[id] = old
+		return old, true, err
+	}
+	return old, true, nil
+}
+
+// List returns all waivers, soonest expiry first
+func (s *WaiverStore) List() []Waiver {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]Waiver, 0, len(s.waivers))
+	for _, w := range s.waivers {
+		list = append(list, w)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ExpiresAt < list[j].ExpiresAt })
+	return list
+}
+
+// Active returns the waivers that have not expired
+func (s *WaiverStore) Active() []Waiver {
+	now := time.Now()
+	var active []Waiver
+	for _, w := range s.List() {
+		if w.active(now) {
+			active = append(active, w)
+		}
+	}
+	return active
+}
+
+// applyWaivers marks failed or warning checks covered by an active waiver as
+// WAIVED. Expired waivers are ignored, so failures come back on the next run.
+func applyWaivers(hostname string, result *ComprehensiveResult) {
+	if waiverStore == nil {
+		return
+	}
+	waivers := waiverStore.Active()
+	if len(waivers) == 0 {
+		return
+	}
+
+	waive := func(checks []CheckItem, instance, database string) {
+		for i := range checks {
+			if checks[i].Status != StatusFailed && checks[i].Status != StatusWarning {
+				continue
+			}
+			for _, w := range waivers {
+				if w.covers(checks[i].CheckID, hostname, instance, database) {
+					checks[i].Status = StatusWaived
+					checks[i].Message += fmt.Sprintf(" [waived until %s by %s: %s]", w.ExpiresAt, w.ApprovedBy, w.Justification)
+					break
+				}
+			}
+		}
+	}
+
+	waive(result.VMChecks, "", "")
+	for _, inst := range result.Instances {
+		waive(inst.Checks, inst.Name, "")
+		for db, checks := range inst.Databases {
+			waive(checks, inst.Name, db)
+		}
+	}
+}
+
+// ===== API Handlers =====
+
+// handleListWaivers returns all waivers with whether each is still active
+func handleListWaivers(c *gin.Context) {
+	if waiverStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Waiver store unavailable"})
+		return
+	}
+	now := time.Now()
+	waivers := []WaiverStatus{}
+	for _, w := range waiverStore.List() {
+		waivers = append(waivers, WaiverStatus{Waiver: w, Active: w.active(now)})
+	}
+	c.JSON(http.StatusOK, WaiverListResponse{Waivers: waivers})
+}
+
+// handleCreateWaiver records a waiver approved by the calling admin
+func handleCreateWaiver(c *gin.Context) {
+	if waiverStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Waiver store unavailable"})
+		return
+	}
+	var req WaiverRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if req.CheckID == "" || req.Host == "" || strings.TrimSpace(req.Justification) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "check_id, host and justification are required"})
+		return
+	}
+	if req.Database != "" && req.Instance == "" {
+		req.Instance = defaultSQLInstance
+	}
+
+	expires, err := time.Parse(time.RFC3339, req.ExpiresAt)
+	if err != nil {
+		expires, err = time.Parse("2006-01-02", req.ExpiresAt)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be RFC 3339 or YYYY-MM-DD"})
+		return
+	}
+	if !expires.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	w := Waiver{
+		ID:            newID(),
+		CheckID:       req.CheckID,
+		Host:          req.Host,
+		Instance:      req.Instance,
+		Database:      req.Database,
+		Justification: strings.TrimSpace(req.Justification),
+		ApprovedBy:    currentPrincipal(c).Subject,
+		CreatedAt:     time.Now().UTC().Format(time.RFC3339),
+		ExpiresAt:     expires.UTC().Format(time.RFC3339),
+	}
+	entry := AuditEntry{
+		Action: AuditWaiverCreate,
+		Hosts:  []string{w.Host},
+		Checks: []string{w.CheckID},
+		Detail: fmt.Sprintf("waiver %s until %s: %s", w.ID, w.ExpiresAt, w.Justification),
+	}
+	if err := waiverStore.Add(w); err != nil {
+		logrus.Errorf("Failed to save waiver: %v", err)
+		entry.Outcome = AuditOutcomeFailure
+		auditRequest(c, entry)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save waiver"})
+		return
+	}
+	entry.Outcome = AuditOutcomeSuccess
+	auditRequest(c, entry)
+	c.JSON(http.StatusCreated, WaiverResponse{Waiver: w})
+}
+
+// handleDeleteWaiver revokes a waiver before it expires
+func handleDeleteWaiver(c *gin.Context) {
+	if waiverStore == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Waiver store unavailable"})
+		return
+	}
+	id := c.Param("id")
+	w, existed, err := waiverStore.Delete(id)
+	if !existed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Waiver not found"})
+		return
+	}
+	entry := AuditEntry{
+		Action: AuditWaiverDelete,
+		Hosts:  []string{w.Host},
+		Checks: []string{w.CheckID},
+		Detail: "waiver " + id,
+	}
+	if err != nil {
+		logrus.Errorf("Failed to delete waiver %s: %v", id, err)
+		entry.Outcome = AuditOutcomeFailure
+		auditRequest(c, entry)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete waiver"})
+		return
+	}
+	entry.Outcome = AuditOutcomeSuccess
+	auditRequest(c, entry)
+	c.JSON(http.StatusOK, StatusResponse{Status: "deleted"})
+}
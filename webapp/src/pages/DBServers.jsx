@@ -8,7 +8,7 @@ function DBServers() {
   useEffect(() => {
     const load = async () => {
       try {
-        const res = await fetch('/api/dbservers')
+        const res = await fetch('/api/v1/dbservers')
         if (!res.ok) throw new Error('Failed')
         const json = await res.json()
         setVms(json.vms || [])
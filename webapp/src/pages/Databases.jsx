@@ -8,7 +8,7 @@ function Databases() {
   useEffect(() => {
     const load = async () => {
       try {
-        const res = await fetch('/api/databases')
+        const res = await fetch('/api/v1/databases')
         if (!res.ok) throw new Error('Failed')
         const json = await res.json()
         setDatabases(json.databases || [])
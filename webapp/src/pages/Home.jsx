@@ -16,7 +16,7 @@ function Home() {
     if (processing) {
       const poll = async () => {
         try {
-          const res = await fetch("/api/progress");
+          const res = await fetch("/api/v1/progress");
           if (res.ok) {
             const json = await res.json();
             const total = json.total || 0;
@@ -50,7 +50,7 @@ function Home() {
     setProgressText("0%");
     setProgressPct(0);
     try {
-      const resp = await fetch("/api/check", {
+      const resp = await fetch("/api/v1/check", {
         method: "POST",
         headers: { "Content-Type": "application/json" },
         body: JSON.stringify({ hostnames: value }),
@@ -8,7 +8,7 @@ function Instances() {
   useEffect(() => {
     const load = async () => {
       try {
-        const res = await fetch("/api/instances");
+        const res = await fetch("/api/v1/instances");
         if (!res.ok) throw new Error("Failed");
         const json = await res.json();
         setInstances(json.instances || []);
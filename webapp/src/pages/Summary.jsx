@@ -7,7 +7,7 @@ function Summary() {
   useEffect(() => {
     const load = async () => {
       try {
-        const res = await fetch('/api/summary')
+        const res = await fetch('/api/v1/summary')
         if (!res.ok) throw new Error('Failed')
         const json = await res.json()
         setData(json)
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// Webhook event types
+const (
+	EventRunStarted      = "run.started"
+	EventRunCompleted    = "run.completed"
+	EventRunFailed       = "run.failed"
+	EventHostNewlyFailed = "host.newly_failing"
+	EventPing            = "ping"
+)
+
+const (
+	signatureHeader     = "X-NDB-Signature"
+	maxDeliveryLogSize  = 500
+	defaultHookTimeout  = 10 * time.Second
+	deliveryStatusOK    = "delivered"
+	deliveryStatusRetry = "retrying"
+	deliveryStatusFail  = "failed"
+)
+
+// WebhookConfig describes one outbound webhook subscription
+type WebhookConfig struct {
+	Name    string        `yaml:"name" json:"name"`
+	URL     string        `yaml:"url" json:"url"`
+	Secret  string        `yaml:"secret" json:"-"`
+	Events  []string      `yaml:"events" json:"events"`
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+}
+
+// WebhookPayload is the JSON body POSTed to subscribers
+type WebhookPayload struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	Timestamp string      `json:"timestamp"`
+	RunID     string      `json:"run_id,omitempty"`
+	Data      interface{} `json:"data"`
+}
+
+// WebhookDelivery is one entry of the delivery log
+type WebhookDelivery struct {
+	ID             string `json:"id"`
+	Webhook        string `json:"webhook"`
+	Event          string `json:"event"`
+	RunID          string `json:"run_id,omitempty"`
+	Status         string `json:"status"`
+	Attempts       int    `json:"attempts"`
+	LastStatusCode int    `json:"last_status_code,omitempty"`
+	LastError      string `json:"last_error,omitempty"`
+	CreatedAt      string `json:"created_at"`
+	UpdatedAt      string `json:"updated_at"`
+}
+
+// ===== Globals =====
+
+var deliveryLog []*WebhookDelivery
+var deliveryMu sync.Mutex
+
+var webhookClient = &http.Client{}
+
+func isKnownEvent(event string) bool {
+	switch event {
+	case EventRunStarted, EventRunCompleted, EventRunFailed, EventHostNewlyFailed:
+		return true
+	}
+	return false
+}
+
+func (wh WebhookConfig) subscribes(event string) bool {
+	if event == EventPing || len(wh.Events) == 0 {
+		return true
+	}
+	for _, ev := range wh.Events {
+		if ev == event {
+			return true
+		}
+	}
+	return false
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// signPayload returns the hex HMAC-SHA256 of body keyed by secret
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifyWebhooks queues event for every webhook subscribed to it.
+// Deliveries happen in the background so callers never block on subscribers;
+// they count as part of the caller's run, which shutdown waits for.
+func notifyWebhooks(event, runID string, data interface{}) {
+	for _, wh := range currentConfig().Webhooks {
+		if !wh.subscribes(event) {
+			continue
+		}
+		payload := WebhookPayload{
+			ID:        newID(),
+			Event:     event,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			RunID:     runID,
+			Data:      data,
+		}
+		retry := currentConfig().WebhookRetry
+		goWork(func() { deliverWebhook(wh, payload, retry) })
+	}
+}
+
+// deliverWebhook POSTs payload to wh, retrying with exponential backoff
+// until the service is interrupted
+func deliverWebhook(wh WebhookConfig, payload WebhookPayload, retry RetryConfig) *WebhookDelivery {
+	body, err := json.Marshal(payload)
+	now := time.Now().UTC().Format(time.RFC3339)
+	delivery := &WebhookDelivery{
+		ID:        payload.ID,
+		Webhook:   wh.Name,
+		Event:     payload.Event,
+		RunID:     payload.RunID,
+		Status:    deliveryStatusRetry,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	recordDelivery(delivery)
+
+	if err != nil {
+		updateDelivery(delivery, func(d *WebhookDelivery) {
+			d.Status = deliveryStatusFail
+			d.LastError = fmt.Sprintf("failed to encode payload: %v", err)
+		})
+		return delivery
+	}
+
+	backoff := retry.InitialBackoff
+	for attempt := 1; attempt <= retry.MaxAttempts; attempt++ {
+		code, err := postWebhook(wh, payload, body)
+		updateDelivery(delivery, func(d *WebhookDelivery) {
+			d.Attempts = attempt
+			d.LastStatusCode = code
+			d.LastError = ""
+			if err != nil {
+				d.LastError = err.Error()
+			}
+		})
+		if err == nil {
+			updateDelivery(delivery, func(d *WebhookDelivery) { d.Status = deliveryStatusOK })
+			return delivery
+		}
+
+		logrus.Warnf("Webhook %s delivery %s attempt %d/%d failed: %v",
+			wh.Name, payload.ID, attempt, retry.MaxAttempts, err)
+		if attempt == retry.MaxAttempts {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+		case <-serviceCtx.Done():
+			updateDelivery(delivery, func(d *WebhookDelivery) { d.Status = deliveryStatusFail })
+			logrus.Errorf("Webhook %s delivery %s abandoned after %d attempts: %v",
+				wh.Name, payload.ID, attempt, context.Cause(serviceCtx))
+			return delivery
+		}
+		backoff *= 2
+		if retry.MaxBackoff > 0 && backoff > retry.MaxBackoff {
+			backoff = retry.MaxBackoff
+		}
+	}
+
+	updateDelivery(delivery, func(d *WebhookDelivery) { d.Status = deliveryStatusFail })
+	logrus.Errorf("Webhook %s delivery %s gave up after %d attempts", wh.Name, payload.ID, retry.MaxAttempts)
+	return delivery
+}
+
+func postWebhook(wh WebhookConfig, payload WebhookPayload, body []byte) (int, error) {
+	timeout := wh.Timeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+
+	req, err := http.NewRequestWithContext(serviceCtx, http.MethodPost, wh.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ndb-precheck-webhook")
+	req.Header.Set("X-NDB-Event", payload.Event)
+	req.Header.Set("X-NDB-Delivery", payload.ID)
+	if wh.Secret != "" {
+		req.Header.Set(signatureHeader, signPayload(wh.Secret, body))
+	}
+
+	client := *webhookClient
+	client.Timeout = timeout
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func recordDelivery(d *WebhookDelivery) {
+	deliveryMu.Lock()
+	defer deliveryMu.Unlock()
+	deliveryLog = append(deliveryLog, d)
+	if len(deliveryLog) > maxDeliveryLogSize {
+		deliveryLog = deliveryLog[len(deliveryLog)-maxDeliveryLogSize:]
+	}
+}
+
+func updateDelivery(d *WebhookDelivery, fn func(*WebhookDelivery)) {
+	deliveryMu.Lock()
+	defer deliveryMu.Unlock()
+	fn(d)
+	d.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+}
+
+// ===== Run events =====
+
+// runEventData summarizes a batch for webhook payloads
+func runEventData(response *BatchResponse) gin.H {
+	return gin.H{
+		"timestamp": response.Timestamp,
+		"total":     response.Total,
+		"passed":    response.Passed,
+		"failed":    response.Failed,
+		"summary":   response.Summary,
+	}
+}
+
+// notifyRunFinished emits the completion events for a finished batch.
+// previous is the batch that was current before this one started, if any.
+func notifyRunFinished(response, previous *BatchResponse) {
+	data := runEventData(response)
+	notifyWebhooks(EventRunCompleted, response.RunID, data)
+	if response.Failed > 0 {
+		notifyWebhooks(EventRunFailed, response.RunID, data)
+	}
+
+	for _, hostname := range newlyFailingHosts(response, previous) {
+		notifyWebhooks(EventHostNewlyFailed, response.RunID, gin.H{
+			"hostname": hostname,
+			"checks":   response.VMResults[hostname],
+		})
+	}
+}
+
+// newlyFailingHosts lists hosts failing in current that were not failing in previous
+func newlyFailingHosts(current, previous *BatchResponse) []string {
+	var prev map[string][]CheckResult
+	if previous != nil {
+		prev = previous.VMResults
+	}
+	return newlyFailingEntities(current.VMResults, prev)
+}
+
+// newlyFailingEntities lists entities failing in current that were not failing in previous
+func newlyFailingEntities(current, previous map[string][]CheckResult) []string {
+	var names []string
+	for name, checks := range current {
+		if !hasFailedCheck(checks) {
+			continue
+		}
+		if hasFailedCheck(previous[name]) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func hasFailedCheck(checks []CheckResult) bool {
+	for _, check := range checks {
+		if check.Status.Fails() {
+			return true
+		}
+	}
+	return false
+}
+
+// ===== API Handlers =====
+
+// handleListWebhooks returns the configured webhooks without their secrets
+func handleListWebhooks(c *gin.Context) {
+	hooks := currentConfig().Webhooks
+	if hooks == nil {
+		hooks = []WebhookConfig{}
+	}
+	c.JSON(http.StatusOK, WebhookListResponse{Webhooks: hooks})
+}
+
+// handleWebhookDeliveries returns the delivery log, newest first
+func handleWebhookDeliveries(c *gin.Context) {
+	name := c.Query("webhook")
+
+	deliveryMu.Lock()
+	deliveries := make([]WebhookDelivery, 0, len(deliveryLog))
+	for i := len(deliveryLog) - 1; i >= 0; i-- {
+		if name != "" && deliveryLog[i].Webhook != name {
+			continue
+		}
+		deliveries = append(deliveries, *deliveryLog[i])
+	}
+	deliveryMu.Unlock()
+
+	c.JSON(http.StatusOK, WebhookDeliveriesResponse{Deliveries: deliveries})
+}
+
+// handleTestWebhook fires a ping event synchronously and reports the outcome
+func handleTestWebhook(c *gin.Context) {
+	name := c.Param("name")
+	for _, wh := range currentConfig().Webhooks {
+		if wh.Name != name {
+			continue
+		}
+		payload := WebhookPayload{
+			ID:        newID(),
+			Event:     EventPing,
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Data:      gin.H{"message": "Test delivery from NDB PreCheck Service"},
+		}
+		// A single attempt keeps the request snappy; the result lands in the log as usual.
+		delivery := deliverWebhook(wh, payload, RetryConfig{MaxAttempts: 1})
+
+		deliveryMu.Lock()
+		result := *delivery
+		deliveryMu.Unlock()
+
+		status := http.StatusOK
+		if result.Status != deliveryStatusOK {
+			status = http.StatusBadGateway
+		}
+		c.JSON(status, WebhookDeliveryResponse{Delivery: result})
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Webhook %q not found", name)})
+}
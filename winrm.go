@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/masterzen/winrm"
+	"github.com/sirupsen/logrus"
+)
+
+// WinRM authentication methods
+const (
+	WinRMAuthBasic    = "basic"
+	WinRMAuthNTLM     = "ntlm"
+	WinRMAuthKerberos = "kerberos"
+)
+
+// WinRMConfig configures the native WS-Management executor
+type WinRMConfig struct {
+	Port     int           `yaml:"port"`
+	HTTPS    bool          `yaml:"https"`
+	Insecure bool          `yaml:"insecure"`
+	CACert   string        `yaml:"ca_cert"`
+	Auth     string        `yaml:"auth"`
+	Timeout  time.Duration `yaml:"timeout"`
+	Kerberos struct {
+		Realm    string `yaml:"realm"`
+		Krb5Conf string `yaml:"krb5_conf"`
+		// CCache lets the service use its own ticket when a host has no credential
+		CCache string `yaml:"ccache"`
+		SPN    string `yaml:"spn"`
+	} `yaml:"kerberos"`
+}
+
+func (w WinRMConfig) validate() error {
+	switch w.Auth {
+	case "", WinRMAuthBasic, WinRMAuthNTLM, WinRMAuthKerberos:
+	default:
+		return fmt.Errorf("executor.winrm.auth must be basic, ntlm or kerberos, got %q", w.Auth)
+	}
+	if w.Auth == WinRMAuthBasic && !w.HTTPS {
+		logrus.Warn("WinRM basic auth over HTTP sends credentials in clear text; enable https")
+	}
+	return nil
+}
+
+// winRMExecutor runs checks.ps1 on the target over WinRM, without a local PowerShell
+type winRMExecutor struct {
+	cfg WinRMConfig
+}
+
+func (e winRMExecutor) Run(ctx context.Context, hostname string, cred *Credential) (*ComprehensiveResult, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	script, err := remoteChecksScript(hostname)
+	if err != nil {
+		return nil, "", err
+	}
+	stdout, err := e.Exec(ctx, hostname, cred, script)
+	if err != nil {
+		return nil, "", err
+	}
+
+	logrus.Debugf("WinRM raw output for %s: %s", hostname, stdout)
+	result, err := parseChecksOutput(hostname, stdout)
+	return result, stdout, err
+}
+
+// Exec runs script on the target over WinRM and returns its standard output
+func (e winRMExecutor) Exec(ctx context.Context, hostname string, cred *Credential, script string) (string, error) {
+	client, err := e.client(hostname, cred)
+	if err != nil {
+		return "", err
+	}
+	var stdout, stderr strings.Builder
+	code, err := client.RunWithContextWithInput(ctx, stdinCommand("powershell"), &stdout, &stderr,
+		strings.NewReader(stdinPayload(script)))
+	if err != nil {
+		return "", fmt.Errorf("WinRM execution failed: %v", err)
+	}
+	if code != 0 {
+		return "", fmt.Errorf("WinRM execution failed with exit code %d: %s", code, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+func (e winRMExecutor) client(hostname string, cred *Credential) (*winrm.Client, error) {
+	cfg := e.cfg
+	port := cfg.Port
+	if port == 0 {
+		port = 5985
+		if cfg.HTTPS {
+			port = 5986
+		}
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+
+	var caCert []byte
+	if cfg.CACert != "" {
+		var err error
+		if caCert, err = os.ReadFile(cfg.CACert); err != nil {
+			return nil, fmt.Errorf("failed to read winrm ca_cert: %v", err)
+		}
+	}
+	endpoint := winrm.NewEndpoint(hostname, port, cfg.HTTPS, cfg.Insecure, caCert, nil, nil, timeout)
+
+	var username, password string
+	if cred != nil {
+		username, password = cred.Username, cred.Password
+	}
+
+	params := *winrm.DefaultParameters
+	switch cfg.Auth {
+	case WinRMAuthKerberos:
+		proto := "http"
+		if cfg.HTTPS {
+			proto = "https"
+		}
+		if cred == nil && cfg.Kerberos.CCache == "" {
+			return nil, fmt.Errorf("WinRM kerberos for %s needs a credential or executor.winrm.kerberos.ccache", hostname)
+		}
+		settings := &winrm.Settings{
+			WinRMUsername: username,
+			WinRMPassword: password,
+			WinRMHost:     hostname,
+			WinRMPort:     port,
+			WinRMProto:    proto,
+			WinRMInsecure: cfg.Insecure,
+			KrbRealm:      cfg.Kerberos.Realm,
+			KrbConfig:     cfg.Kerberos.Krb5Conf,
+			KrbSpn:        cfg.Kerberos.SPN,
+			KrbCCache:     cfg.Kerberos.CCache,
+		}
+		params.TransportDecorator = func() winrm.Transporter { return winrm.NewClientKerberos(settings) }
+	case WinRMAuthBasic:
+		if cred == nil {
+			return nil, fmt.Errorf("WinRM basic auth for %s needs a credential", hostname)
+		}
+	default:
+		if cred == nil {
+			return nil, fmt.Errorf("WinRM NTLM auth for %s needs a credential", hostname)
+		}
+		if cfg.HTTPS {
+			params.TransportDecorator = func() winrm.Transporter { return &winrm.ClientNTLM{} }
+		} else {
+			// Plain HTTP listeners require NTLM message encryption.
+			enc, err := winrm.NewEncryption("ntlm")
+			if err != nil {
+				return nil, fmt.Errorf("failed to set up WinRM NTLM encryption for %s: %v", hostname, err)
+			}
+			params.TransportDecorator = func() winrm.Transporter { return enc }
+		}
+	}
+
+	client, err := winrm.NewClientWithParameters(endpoint, username, password, &params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create WinRM client for %s: %v", hostname, err)
+	}
+	return client, nil
+}
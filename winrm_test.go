@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// winRMStandIn is an in-process WS-Management listener that runs nothing:
+// each command gets stdout and exitCode once its standard input ends, and
+// the command line and the script decoded from that input are recorded.
+// Like cmd.exe on a real host, it refuses command lines over maxCommandLine.
+type winRMStandIn struct {
+	port int
+
+	stdout   string
+	exitCode int
+
+	mu       sync.Mutex
+	commands []string
+	scripts  []string
+	input    strings.Builder
+	ended    chan struct{}
+}
+
+const winRMEnvelope = `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:a="http://schemas.xmlsoap.org/ws/2004/08/addressing" xmlns:rsp="http://schemas.microsoft.com/wbem/wsman/1/windows/shell"><s:Header><a:Action>%s</a:Action></s:Header><s:Body>%s</s:Body></s:Envelope>`
+
+func startWinRMStandIn(t *testing.T) *winRMStandIn {
+	t.Helper()
+	s := &winRMStandIn{stdout: "ok"}
+	srv := httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(srv.Close)
+	s.port = srv.Listener.Addr().(*net.TCPAddr).Port
+	return s
+}
+
+func (s *winRMStandIn) serve(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Action  string `xml:"Header>Action"`
+		Command string `xml:"Body>CommandLine>Command"`
+		Streams []struct {
+			End  bool   `xml:"End,attr"`
+			Data string `xml:",chardata"`
+		} `xml:"Body>Send>Stream"`
+	}
+	body, _ := io.ReadAll(r.Body)
+	if user, pass, _ := r.BasicAuth(); user != "svc" || pass != "pw" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if err := xml.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/soap+xml")
+	reply := func(action, content string) {
+		fmt.Fprintf(w, winRMEnvelope, action, content)
+	}
+
+	const shellNS = "http://schemas.microsoft.com/wbem/wsman/1/windows/shell/"
+	switch {
+	case strings.HasSuffix(req.Action, "transfer/Create"):
+		reply("http://schemas.xmlsoap.org/ws/2004/09/transfer/CreateResponse", "<rsp:Shell><rsp:ShellId>shell-1</rsp:ShellId></rsp:Shell>")
+	case req.Action == shellNS+"Command":
+		if len(req.Command) > maxCommandLine {
+			w.WriteHeader(http.StatusInternalServerError)
+			reply("http://schemas.dmtf.org/wbem/wsman/1/wsman/fault", "<s:Fault><s:Reason><s:Text>The command line is too long.</s:Text></s:Reason></s:Fault>")
+			return
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, req.Command)
+		s.input.Reset()
+		s.ended = make(chan struct{})
+		s.mu.Unlock()
+		reply(shellNS+"CommandResponse", "<rsp:CommandResponse><rsp:CommandId>command-1</rsp:CommandId></rsp:CommandResponse>")
+	case req.Action == shellNS+"Send":
+		s.mu.Lock()
+		for _, stream := range req.Streams {
+			data, _ := base64.StdEncoding.DecodeString(stream.Data)
+			s.input.Write(data)
+			if stream.End {
+				script, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(s.input.String()))
+				s.scripts = append(s.scripts, string(script))
+				close(s.ended)
+			}
+		}
+		s.mu.Unlock()
+		reply(shellNS+"SendResponse", "")
+	case req.Action == shellNS+"Receive":
+		s.mu.Lock()
+		ended := s.ended
+		s.mu.Unlock()
+		select {
+		case <-ended:
+		case <-r.Context().Done():
+			return
+		}
+		reply(shellNS+"ReceiveResponse", fmt.Sprintf(
+			`<rsp:ReceiveResponse><rsp:Stream Name="stdout" CommandId="command-1">%s</rsp:Stream>`+
+				`<rsp:CommandState CommandId="command-1" State="%sCommandState/Done"><rsp:ExitCode>%d</rsp:ExitCode></rsp:CommandState></rsp:ReceiveResponse>`,
+			base64.StdEncoding.EncodeToString([]byte(s.stdout)), shellNS, s.exitCode))
+	default:
+		// Signal and Delete
+		reply(req.Action+"Response", "")
+	}
+}
+
+// last returns the command line and decoded script of the latest command
+func (s *winRMStandIn) last() (command, script string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.commands) == 0 || len(s.scripts) == 0 {
+		return "", ""
+	}
+	return s.commands[len(s.commands)-1], s.scripts[len(s.scripts)-1]
+}
+
+func TestWinRMExecutorSendsScriptOnStdin(t *testing.T) {
+	s := startWinRMStandIn(t)
+	e := winRMExecutor{cfg: WinRMConfig{Port: s.port, Auth: WinRMAuthBasic}}
+	cred := &Credential{Username: "svc", Password: "pw"}
+
+	checks, err := remoteChecksScript("sql01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Far past what -EncodedCommand could carry within maxCommandLine
+	script := checks + "# 'ünïcode' here-string: @'\n" + strings.Repeat("Write-Output 'padding'\n", 1000) + "'@\n"
+	out, err := e.Exec(context.Background(), "127.0.0.1", cred, script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "ok" {
+		t.Errorf("output = %q", out)
+	}
+	command, got := s.last()
+	if !strings.HasPrefix(command, "powershell -NoLogo -NoProfile -NonInteractive -EncodedCommand ") || len(command) > 1024 {
+		t.Errorf("command line is %d characters: %.100s", len(command), command)
+	}
+	if got != script {
+		t.Errorf("script on stdin is %d bytes, want the %d sent", len(got), len(script))
+	}
+}
+
+func TestWinRMExecutorExitCode(t *testing.T) {
+	s := startWinRMStandIn(t)
+	s.exitCode = 3
+	e := winRMExecutor{cfg: WinRMConfig{Port: s.port, Auth: WinRMAuthBasic}}
+	_, err := e.Exec(context.Background(), "127.0.0.1", &Credential{Username: "svc", Password: "pw"}, "exit 3")
+	if err == nil || !strings.Contains(err.Error(), "exit code 3") {
+		t.Errorf("err = %v, want the exit code", err)
+	}
+}
+
+func TestStdinCommandFitsCommandLine(t *testing.T) {
+	for _, shell := range []string{"powershell", "pwsh", `C:\Program Files\PowerShell\7\pwsh.exe`} {
+		if n := len(stdinCommand(shell)); n > maxCommandLine {
+			t.Errorf("%s command line is %d characters, over %d", shell, n, maxCommandLine)
+		}
+	}
+	script := "Write-Output 'ünïcode'\n"
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(stdinPayload(script)))
+	if err != nil || string(raw) != script {
+		t.Errorf("payload decodes to %q, %v", raw, err)
+	}
+}